@@ -1,6 +1,11 @@
 package root
 
-import "github.com/spf13/cobra"
+import (
+	"github.com/spf13/cobra"
+
+	"cryptool/internal/coinbase"
+	"cryptool/internal/config"
+)
 
 func NewCoinbaseCmd() *cobra.Command {
 	cmd := &cobra.Command{
@@ -9,5 +14,15 @@ func NewCoinbaseCmd() *cobra.Command {
 	}
 	cmd.AddCommand(newCoinbaseDataCmd())
 	cmd.AddCommand(newCoinbaseWalletCmd())
+	cmd.AddCommand(newCoinbaseOrderCmd())
+	cmd.AddCommand(newCoinbaseFillsCmd())
+	cmd.AddCommand(newCoinbaseStreamCmd())
+	cmd.AddCommand(newCoinbaseBookCmd())
 	return cmd
 }
+
+// newCoinbaseClient builds a Coinbase client from config.
+// Prefer JWT auth when configured; else fall back to HMAC headers.
+func newCoinbaseClient(cfg *config.Config) (*coinbase.Client, error) {
+	return coinbase.NewClientFromConfig(cfg)
+}
@@ -0,0 +1,232 @@
+package root
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/spf13/cobra"
+
+	"cryptool/internal/coinbase"
+	"cryptool/internal/coinbase/stream"
+	"cryptool/internal/config"
+	"cryptool/internal/exchange"
+	"cryptool/internal/orderbook"
+)
+
+// bookView is the printed state of an order book.
+type bookView struct {
+	ProductID string            `json:"product_id"`
+	Sequence  int64             `json:"sequence"`
+	Time      time.Time         `json:"time"`
+	Bids      []orderbook.Level `json:"bids"`
+	Asks      []orderbook.Level `json:"asks"`
+	Impact    *orderbook.Impact `json:"impact,omitempty"`
+}
+
+func newCoinbaseBookCmd() *cobra.Command {
+	var (
+		product       string
+		depth         int
+		side          string
+		size          string
+		watch         bool
+		interval      time.Duration
+		snapshotEvery time.Duration
+		snapshotDepth int
+		format        string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "book",
+		Short: "Show the live order book and estimate slippage",
+		Long: `Builds a local order book from the Coinbase level2 WebSocket channel and prints the top
+--depth levels of each side.
+
+With --size the command also estimates the price impact of a market order of that base size on
+--side: the average fill price, the worst level touched, and the slippage relative to the best price.
+
+The book is rebuilt from a fresh snapshot whenever the stream reports a sequence gap or reconnects.
+By default the command prints once the book is synced and exits; --watch keeps printing every
+--interval. --snapshot-every stores the top --snapshot-depth levels in the order_book_snapshots table
+at that interval (implies running until interrupted).`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg := config.FromContext(cmd.Context())
+			if product == "" {
+				return errors.New("--product is required, e.g. BTC-USD")
+			}
+			product = strings.ToUpper(product)
+			side = strings.ToUpper(side)
+			if side != coinbase.SideBuy && side != coinbase.SideSell {
+				return fmt.Errorf("--side must be buy or sell, got %q", side)
+			}
+			switch format {
+			case "table", "json":
+			default:
+				return fmt.Errorf("unsupported format %q, expected table or json", format)
+			}
+			var impactSize decimal.Decimal
+			if size != "" {
+				var err error
+				if impactSize, err = exchange.ParseDecimal("--size", size); err != nil {
+					return err
+				}
+			}
+
+			client, err := newCoinbaseClient(cfg)
+			if err != nil {
+				return err
+			}
+			defer client.Close()
+
+			ctx, cancel := context.WithCancel(cmd.Context())
+			defer cancel()
+
+			books := orderbook.NewBooks()
+			book := books.Book(product)
+			sub := stream.New([]string{product}, stream.ChannelLevel2, stream.ChannelHeartbeats)
+			sub.Token = client.StreamToken
+			sub.OnEvent = func(e stream.Event) {
+				books.HandleEvent(e)
+				switch e.Kind {
+				case stream.EventDisconnected:
+					fmt.Fprintf(os.Stderr, "Stream disconnected: %v (reconnecting in %s)\n", e.Err, e.RetryIn)
+				case stream.EventGap:
+					fmt.Fprintf(os.Stderr, "Stream sequence gap: expected %d, got %d; resyncing\n", e.Expected, e.Got)
+				}
+			}
+			go sub.Run(ctx)
+			go func() {
+				for range sub.Heartbeats {
+				}
+			}()
+			go books.Run(sub.Level2)
+
+			show := func() error {
+				bids, asks, err := book.Depth(depth)
+				if err != nil {
+					return err
+				}
+				seq, t := book.Sequence()
+				v := bookView{ProductID: product, Sequence: seq, Time: t, Bids: bids, Asks: asks}
+				if impactSize.IsPositive() {
+					imp, err := book.PriceImpact(side, impactSize)
+					if err != nil {
+						return err
+					}
+					v.Impact = &imp
+				}
+				return writeBook(os.Stdout, format, v)
+			}
+			store, err := openStore(cfg)
+			if err != nil {
+				return err
+			}
+			defer store.Close()
+			snapshot := func() error {
+				bids, asks, err := book.Depth(snapshotDepth)
+				if err != nil {
+					return err
+				}
+				seq, _ := book.Sequence()
+				return store.InsertOrderBookSnapshot(ctx, "coinbase", product, time.Now().UTC(), seq, bids, asks)
+			}
+
+			fmt.Fprintf(os.Stderr, "Waiting for %s order book snapshot...\n", product)
+			for !book.Synced() {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(100 * time.Millisecond):
+				}
+			}
+			if !watch && snapshotEvery <= 0 {
+				return show()
+			}
+
+			var printC, snapC <-chan time.Time
+			if watch {
+				t := time.NewTicker(interval)
+				defer t.Stop()
+				printC = t.C
+				if err := show(); err != nil {
+					return err
+				}
+			}
+			if snapshotEvery > 0 {
+				t := time.NewTicker(snapshotEvery)
+				defer t.Stop()
+				snapC = t.C
+			}
+			for {
+				select {
+				case <-ctx.Done():
+					return nil
+				case <-printC:
+					if err := show(); err != nil && !errors.Is(err, orderbook.ErrNotSynced) {
+						return err
+					}
+				case <-snapC:
+					if err := snapshot(); errors.Is(err, orderbook.ErrNotSynced) {
+						fmt.Fprintln(os.Stderr, "Skipping snapshot while the book resyncs")
+					} else if err != nil {
+						return fmt.Errorf("failed to store order book snapshot: %w", err)
+					}
+				}
+			}
+		},
+	}
+	cmd.Flags().StringVar(&product, "product", "", "product id, e.g. BTC-USD")
+	cmd.Flags().IntVar(&depth, "depth", 10, "number of levels to show per side")
+	cmd.Flags().StringVar(&side, "side", "buy", "order side for --size: buy or sell")
+	cmd.Flags().StringVar(&size, "size", "", "estimate the price impact of a market order of this base size")
+	cmd.Flags().BoolVar(&watch, "watch", false, "keep printing the book every --interval")
+	cmd.Flags().DurationVar(&interval, "interval", 5*time.Second, "print interval for --watch")
+	cmd.Flags().DurationVar(&snapshotEvery, "snapshot-every", 0, "store a book snapshot at this interval (0 disables)")
+	cmd.Flags().IntVar(&snapshotDepth, "snapshot-depth", 50, "levels per side stored in each snapshot")
+	cmd.Flags().StringVar(&format, "format", "table", "output format: table or json")
+	return cmd
+}
+
+func writeBook(out io.Writer, format string, v bookView) error {
+	if format == "json" {
+		return printJSON(out, v)
+	}
+	f := func(x decimal.Decimal) string { return x.String() }
+	pct := func(x decimal.Decimal) string { return x.Mul(decimal.NewFromInt(100)).StringFixed(4) }
+
+	fmt.Fprintf(out, "%s  seq %d  %s\n", v.ProductID, v.Sequence, v.Time.Format(time.RFC3339))
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "Bid Size\tBid\tAsk\tAsk Size\t")
+	for i := 0; i < len(v.Bids) || i < len(v.Asks); i++ {
+		var bs, bp, ap, as string
+		if i < len(v.Bids) {
+			bs, bp = f(v.Bids[i].Size), f(v.Bids[i].Price)
+		}
+		if i < len(v.Asks) {
+			ap, as = f(v.Asks[i].Price), f(v.Asks[i].Size)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t\n", bs, bp, ap, as)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if len(v.Bids) > 0 && len(v.Asks) > 0 {
+		spread := v.Asks[0].Price.Sub(v.Bids[0].Price)
+		fmt.Fprintf(out, "Spread: %s (%s%%)\n", f(spread), pct(spread.Div(v.Asks[0].Price)))
+	}
+	if imp := v.Impact; imp != nil {
+		fmt.Fprintf(out, "Market %s of %s: avg price %s, worst price %s, slippage %s%% over %d levels\n",
+			strings.ToLower(imp.Side), f(imp.Size), f(imp.AveragePrice), f(imp.WorstPrice), pct(imp.Slippage), imp.Levels)
+		if imp.Filled.LessThan(imp.Size) {
+			fmt.Fprintf(out, "Warning: the visible book only fills %s of %s\n", f(imp.Filled), f(imp.Size))
+		}
+	}
+	return nil
+}
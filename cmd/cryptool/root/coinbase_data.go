@@ -7,8 +7,10 @@ func newCoinbaseDataCmd() *cobra.Command {
 		Use:   "data",
 		Short: "Data related commands for Coinbase",
 	}
-	cmd.AddCommand(newCoinbaseDataFetchCmd())
-	cmd.AddCommand(newCoinbaseProductsSyncCmd())
-	cmd.AddCommand(newCoinbaseHistoryCmd())
+	cmd.AddCommand(newDataFetchCmd("coinbase"))
+	cmd.AddCommand(newProductsSyncCmd("coinbase"))
+	cmd.AddCommand(newHistoryCmd("coinbase"))
+	cmd.AddCommand(newRollupCmd("coinbase"))
+	cmd.AddCommand(newAuditCmd("coinbase"))
 	return cmd
 }
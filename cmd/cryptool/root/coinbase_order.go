@@ -0,0 +1,260 @@
+package root
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"cryptool/internal/coinbase"
+	"cryptool/internal/config"
+)
+
+func newCoinbaseOrderCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "order",
+		Short: "Place, cancel and inspect Coinbase orders",
+	}
+	cmd.AddCommand(newCoinbaseOrderPlaceCmd())
+	cmd.AddCommand(newCoinbaseOrderCancelCmd())
+	cmd.AddCommand(newCoinbaseOrderListCmd())
+	cmd.AddCommand(newCoinbaseOrderGetCmd())
+	return cmd
+}
+
+func newCoinbaseOrderPlaceCmd() *cobra.Command {
+	var (
+		product       string
+		side          string
+		orderType     string
+		baseSize      string
+		quoteSize     string
+		limitPrice    string
+		stopPrice     string
+		stopDirection string
+		postOnly      bool
+		endTime       string
+		clientOrderID string
+		preview       bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "place",
+		Short: "Place a market, limit or stop-limit order",
+		Long: `Places an order through the Coinbase Advanced Trade API.
+
+Market orders take either --base-size or --quote-size. Limit orders take --base-size and
+--limit-price; stop-limit orders additionally take --stop-price and --stop-direction.
+Passing --end-time turns a limit or stop-limit order into a good-til-date order.
+
+Use --preview to validate the order and show the expected totals without placing it.
+Pass the same --client-order-id when retrying so Coinbase does not place the order twice.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg := config.FromContext(cmd.Context())
+			if product == "" {
+				return errors.New("--product is required, e.g. BTC-USD")
+			}
+			side = strings.ToUpper(side)
+			if side != coinbase.SideBuy && side != coinbase.SideSell {
+				return fmt.Errorf("--side must be buy or sell, got %q", side)
+			}
+
+			var end time.Time
+			if endTime != "" {
+				t, err := ParseDate(endTime)
+				if err != nil {
+					return fmt.Errorf("invalid --end-time: %w", err)
+				}
+				end = t
+			}
+
+			var req coinbase.CreateOrderRequest
+			switch orderType {
+			case "market":
+				if (baseSize == "") == (quoteSize == "") {
+					return errors.New("market orders need exactly one of --base-size or --quote-size")
+				}
+				req = coinbase.NewMarketOrder(product, side, baseSize, quoteSize)
+			case "limit":
+				if baseSize == "" || limitPrice == "" {
+					return errors.New("limit orders need --base-size and --limit-price")
+				}
+				req = coinbase.NewLimitOrder(product, side, baseSize, limitPrice, postOnly, end)
+			case "stop-limit":
+				if baseSize == "" || limitPrice == "" || stopPrice == "" {
+					return errors.New("stop-limit orders need --base-size, --limit-price and --stop-price")
+				}
+				var dir string
+				switch stopDirection {
+				case "up":
+					dir = coinbase.StopDirectionUp
+				case "down":
+					dir = coinbase.StopDirectionDown
+				default:
+					return fmt.Errorf("--stop-direction must be up or down, got %q", stopDirection)
+				}
+				req = coinbase.NewStopLimitOrder(product, side, baseSize, limitPrice, stopPrice, dir, end)
+			default:
+				return fmt.Errorf("--type must be market, limit or stop-limit, got %q", orderType)
+			}
+
+			client, err := newCoinbaseClient(cfg)
+			if err != nil {
+				return err
+			}
+			defer client.Close()
+
+			if preview {
+				res, err := client.PreviewOrder(cmd.Context(), coinbase.PreviewOrderRequest{
+					ProductID:          req.ProductID,
+					Side:               req.Side,
+					OrderConfiguration: req.OrderConfiguration,
+				})
+				if err != nil {
+					return fmt.Errorf("failed to preview order: %w", err)
+				}
+				return printJSON(os.Stdout, res)
+			}
+
+			req.ClientOrderID = clientOrderID
+			if req.ClientOrderID == "" {
+				req.ClientOrderID = coinbase.NewClientOrderID()
+			}
+			fmt.Fprintf(os.Stderr, "Placing order with client order ID %s\n", req.ClientOrderID)
+			res, err := client.CreateOrder(cmd.Context(), req)
+			if err != nil {
+				return fmt.Errorf("failed to place order: %w", err)
+			}
+			return printJSON(os.Stdout, res)
+		},
+	}
+	cmd.Flags().StringVar(&product, "product", "", "product id, e.g. BTC-USD")
+	cmd.Flags().StringVar(&side, "side", "", "buy or sell")
+	cmd.Flags().StringVar(&orderType, "type", "market", "order type: market, limit or stop-limit")
+	cmd.Flags().StringVar(&baseSize, "base-size", "", "order size in base currency")
+	cmd.Flags().StringVar(&quoteSize, "quote-size", "", "order size in quote currency (market orders only)")
+	cmd.Flags().StringVar(&limitPrice, "limit-price", "", "limit price")
+	cmd.Flags().StringVar(&stopPrice, "stop-price", "", "stop price (stop-limit orders)")
+	cmd.Flags().StringVar(&stopDirection, "stop-direction", "down", "stop direction: up or down (stop-limit orders)")
+	cmd.Flags().BoolVar(&postOnly, "post-only", false, "only add liquidity (limit orders)")
+	cmd.Flags().StringVar(&endTime, "end-time", "", "expiry for good-til-date orders (YYYY-MM-DD or RFC3339)")
+	cmd.Flags().StringVar(&clientOrderID, "client-order-id", "", "idempotency key (default: random UUID)")
+	cmd.Flags().BoolVar(&preview, "preview", false, "preview the order without placing it")
+	return cmd
+}
+
+func newCoinbaseOrderCancelCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "cancel <order-id>...",
+		Short: "Cancel one or more orders",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, err := newCoinbaseClient(config.FromContext(cmd.Context()))
+			if err != nil {
+				return err
+			}
+			defer client.Close()
+			results, err := client.CancelOrders(cmd.Context(), args)
+			if err != nil {
+				return fmt.Errorf("failed to cancel orders: %w", err)
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "Order ID\tCancelled\tReason")
+			failed := 0
+			for _, r := range results {
+				if !r.Success {
+					failed++
+				}
+				fmt.Fprintf(w, "%s\t%t\t%s\n", r.OrderID, r.Success, r.FailureReason)
+			}
+			if err := w.Flush(); err != nil {
+				return err
+			}
+			if failed > 0 {
+				return fmt.Errorf("%d of %d orders could not be cancelled", failed, len(results))
+			}
+			return nil
+		},
+	}
+}
+
+func newCoinbaseOrderListCmd() *cobra.Command {
+	var (
+		product string
+		status  string
+		side    string
+		limit   int
+		format  string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List orders",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, err := newCoinbaseClient(config.FromContext(cmd.Context()))
+			if err != nil {
+				return err
+			}
+			defer client.Close()
+			params := coinbase.ListOrdersParams{Limit: limit, OrderSide: strings.ToUpper(side)}
+			if product != "" {
+				params.ProductIDs = strings.Split(product, ",")
+			}
+			if status != "" {
+				params.OrderStatus = strings.Split(strings.ToUpper(status), ",")
+			}
+			orders, err := client.ListOrders(cmd.Context(), params)
+			if err != nil {
+				return fmt.Errorf("failed to list orders: %w", err)
+			}
+			if format == "json" {
+				return printJSON(os.Stdout, orders)
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "Order ID\tProduct\tSide\tType\tStatus\tFilled\tAvg Price\tCreated")
+			for _, o := range orders {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", o.OrderID, o.ProductID, o.Side, o.OrderType, o.Status,
+					o.FilledSize, o.AverageFilledPrice, o.CreatedTime.Format(time.RFC3339))
+			}
+			return w.Flush()
+		},
+	}
+	cmd.Flags().StringVar(&product, "product", "", "comma-separated product ids to filter by")
+	cmd.Flags().StringVar(&status, "status", "", "comma-separated order statuses, e.g. OPEN,FILLED")
+	cmd.Flags().StringVar(&side, "side", "", "buy or sell")
+	cmd.Flags().IntVar(&limit, "limit", 100, "maximum number of orders to return (0 for all)")
+	cmd.Flags().StringVar(&format, "format", "table", "output format: table or json")
+	return cmd
+}
+
+func newCoinbaseOrderGetCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "get <order-id>",
+		Short: "Show a single order",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, err := newCoinbaseClient(config.FromContext(cmd.Context()))
+			if err != nil {
+				return err
+			}
+			defer client.Close()
+			order, err := client.GetOrder(cmd.Context(), args[0])
+			if err != nil {
+				return fmt.Errorf("failed to get order: %w", err)
+			}
+			return printJSON(os.Stdout, order)
+		},
+	}
+}
+
+func printJSON(out io.Writer, v interface{}) error {
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
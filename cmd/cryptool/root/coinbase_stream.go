@@ -0,0 +1,145 @@
+package root
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"cryptool/internal/coinbase"
+	"cryptool/internal/coinbase/stream"
+	"cryptool/internal/config"
+)
+
+// StreamOptions controls the live candle stream.
+type StreamOptions struct {
+	// Products to subscribe to, e.g. BTC-USD.
+	Products []string
+	// Flush is how often buffered candle updates are written to the database.
+	Flush time.Duration
+}
+
+func newCoinbaseStreamCmd() *cobra.Command {
+	var (
+		product string
+		opts    StreamOptions
+	)
+
+	cmd := &cobra.Command{
+		Use:   "stream",
+		Short: "Stream live 5-minute candles into the candles table",
+		Long: `Subscribes to the Coinbase Advanced Trade WebSocket candles channel and writes live 5-minute
+candles straight into the candles table, replacing polling with GetCandlesOnce.
+
+The candle of the current bucket is updated until it closes; updates are buffered and upserted every
+--flush interval. The connection is re-established with exponential backoff and sequence gaps are
+reported on stderr. Subscriptions are signed with the configured JWT key when available.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			for _, p := range strings.Split(product, ",") {
+				if p = strings.TrimSpace(p); p != "" {
+					opts.Products = append(opts.Products, strings.ToUpper(p))
+				}
+			}
+			return RunCoinbaseStream(cmd.Context(), config.FromContext(cmd.Context()), opts)
+		},
+	}
+	cmd.Flags().StringVar(&product, "product", "", "comma-separated product ids, e.g. BTC-USD,ETH-USD")
+	cmd.Flags().DurationVar(&opts.Flush, "flush", 10*time.Second, "how often to write buffered candles")
+	return cmd
+}
+
+// RunCoinbaseStream streams live 5-minute candles into the candles table until ctx is cancelled.
+func RunCoinbaseStream(ctx context.Context, cfg *config.Config, opts StreamOptions) error {
+	if len(opts.Products) == 0 {
+		return errors.New("at least one product is required, e.g. --product BTC-USD")
+	}
+	if opts.Flush <= 0 {
+		opts.Flush = 10 * time.Second
+	}
+
+	client, err := newCoinbaseClient(cfg)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	store, err := openStore(cfg)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	sub := stream.New(opts.Products, stream.ChannelCandles, stream.ChannelHeartbeats)
+	sub.Token = client.StreamToken
+	sub.OnEvent = func(e stream.Event) {
+		switch e.Kind {
+		case stream.EventConnected:
+			fmt.Fprintf(os.Stderr, "Streaming candles for %s\n", strings.Join(opts.Products, ", "))
+		case stream.EventDisconnected:
+			fmt.Fprintf(os.Stderr, "Stream disconnected: %v (reconnecting in %s)\n", e.Err, e.RetryIn)
+		case stream.EventGap:
+			fmt.Fprintf(os.Stderr, "Stream sequence gap: expected %d, got %d\n", e.Expected, e.Got)
+		}
+	}
+
+	// Stop the subscriber if writing to the database fails.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	runErr := make(chan error, 1)
+	go func() { runErr <- sub.Run(ctx) }()
+	// Heartbeats only keep the connection alive.
+	go func() {
+		for range sub.Heartbeats {
+		}
+	}()
+
+	// Latest update per product and bucket start.
+	pending := make(map[string]map[time.Time]coinbase.Candle)
+	flush := func(ctx context.Context) error {
+		for product, byTime := range pending {
+			candles := make([]coinbase.Candle, 0, len(byTime))
+			for _, c := range byTime {
+				candles = append(candles, c)
+			}
+			n, err := store.UpsertCandles(ctx, "coinbase", product, stream.CandleSeconds, candles)
+			if err != nil {
+				return fmt.Errorf("failed to store candles for %s: %w", product, err)
+			}
+			if cfg.App.Verbose {
+				fmt.Printf("Upserted %d candles for %s\n", n, product)
+			}
+			delete(pending, product)
+		}
+		return nil
+	}
+
+	ticker := time.NewTicker(opts.Flush)
+	defer ticker.Stop()
+	for {
+		select {
+		case c, ok := <-sub.Candles:
+			if !ok {
+				// Run has returned; write what is left before exiting.
+				if err := flush(context.Background()); err != nil {
+					return err
+				}
+				if err := <-runErr; !errors.Is(err, context.Canceled) {
+					return err
+				}
+				return nil
+			}
+			if pending[c.ProductID] == nil {
+				pending[c.ProductID] = make(map[time.Time]coinbase.Candle)
+			}
+			pending[c.ProductID][c.Time] = c.Candle
+		case <-ticker.C:
+			if err := flush(ctx); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -8,5 +8,7 @@ func newCoinbaseWalletCmd() *cobra.Command {
         Short: "Wallet related commands for Coinbase",
     }
     cmd.AddCommand(newCoinbaseWalletSyncDownCmd())
+    cmd.AddCommand(newCoinbaseWalletHistoryCmd())
+    cmd.AddCommand(newCoinbaseWalletValueCmd())
     return cmd
 }
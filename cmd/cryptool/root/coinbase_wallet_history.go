@@ -0,0 +1,113 @@
+package root
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/spf13/cobra"
+
+	"cryptool/internal/config"
+	"cryptool/internal/ingest"
+)
+
+func newCoinbaseWalletHistoryCmd() *cobra.Command {
+	var (
+		currency string
+		since    string
+		until    string
+		format   string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "history",
+		Short: "Show how wallet balances changed over time",
+		Long: `Reads the balance snapshots recorded by 'wallet syncdown' and shows, per currency, the
+available and held balance at every snapshot together with the change since the previous one.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg := config.FromContext(cmd.Context())
+
+			var start, end time.Time
+			var err error
+			if since != "" {
+				if start, err = ParseDate(since); err != nil {
+					return fmt.Errorf("invalid --since: %w", err)
+				}
+			}
+			end = time.Now().UTC().Add(time.Second)
+			if until != "" {
+				if end, err = ParseDate(until); err != nil {
+					return fmt.Errorf("invalid --until: %w", err)
+				}
+			}
+
+			store, err := openStore(cfg)
+			if err != nil {
+				return err
+			}
+			defer store.Close()
+			points, err := store.GetWalletHistory(cmd.Context(), "coinbase", currency, start, end)
+			if err != nil {
+				return fmt.Errorf("failed to load wallet history: %w", err)
+			}
+			return writeWalletHistory(os.Stdout, format, points)
+		},
+	}
+	cmd.Flags().StringVar(&currency, "currency", "", "currency to show, e.g. BTC (default: all)")
+	cmd.Flags().StringVar(&since, "since", "", "only snapshots at or after this date (YYYY-MM-DD or RFC3339)")
+	cmd.Flags().StringVar(&until, "until", "", "only snapshots before this date (YYYY-MM-DD or RFC3339)")
+	cmd.Flags().StringVar(&format, "format", "table", "output format: table, json or csv")
+	return cmd
+}
+
+// walletHistoryRow is a balance point with its change relative to the previous snapshot of the same currency.
+type walletHistoryRow struct {
+	ingest.WalletBalancePoint
+	Total  decimal.Decimal `json:"total"`
+	Change decimal.Decimal `json:"change"`
+}
+
+func writeWalletHistory(out io.Writer, format string, points []ingest.WalletBalancePoint) error {
+	rows := make([]walletHistoryRow, 0, len(points))
+	prev := make(map[string]decimal.Decimal)
+	for _, p := range points {
+		total := p.Available.Add(p.Hold)
+		row := walletHistoryRow{WalletBalancePoint: p, Total: total}
+		if last, ok := prev[p.Currency]; ok {
+			row.Change = total.Sub(last)
+		}
+		prev[p.Currency] = total
+		rows = append(rows, row)
+	}
+
+	f := func(v decimal.Decimal) string { return v.String() }
+
+	switch format {
+	case "json":
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(rows)
+	case "csv":
+		w := csv.NewWriter(out)
+		w.Write([]string{"currency", "taken_at", "available", "hold", "total", "change"})
+		for _, r := range rows {
+			w.Write([]string{r.Currency, r.TakenAt.Format(time.RFC3339), f(r.Available), f(r.Hold), f(r.Total), f(r.Change)})
+		}
+		w.Flush()
+		return w.Error()
+	case "table", "":
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "Currency\tTaken At\tAvailable\tHold\tTotal\tChange")
+		for _, r := range rows {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", r.Currency, r.TakenAt.Format(time.RFC3339), f(r.Available), f(r.Hold), f(r.Total), f(r.Change))
+		}
+		return w.Flush()
+	default:
+		return fmt.Errorf("unsupported format %q, expected table, json or csv", format)
+	}
+}
@@ -1,9 +1,15 @@
 package root
 
 import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"strconv"
 	"text/tabwriter"
+	"time"
 
 	"github.com/spf13/cobra"
 
@@ -11,38 +17,107 @@ import (
 	"cryptool/internal/config"
 )
 
+// WalletSyncDownOptions controls how fetched balances are stored and printed.
+type WalletSyncDownOptions struct {
+	// Persist upserts the balances into the wallets table.
+	Persist bool
+	// NoSnapshot skips recording the balances in wallet_snapshots.
+	NoSnapshot bool
+	// Format is one of table, json or csv.
+	Format string
+}
+
 func newCoinbaseWalletSyncDownCmd() *cobra.Command {
+	var opts WalletSyncDownOptions
+
 	cmd := &cobra.Command{
 		Use:   "syncdown",
 		Short: "Fetch and display account balances from Coinbase",
-		Long:  `Fetches all account balances from the Coinbase Advanced Trade API and displays them in a table.`,
+		Long: `Fetches all account balances from the Coinbase Advanced Trade API and displays them.
+
+Every run records a balance snapshot in the wallet_snapshots table (disable with --no-snapshot) so
+balance history can be queried with 'wallet history'. Use --persist to also upsert the latest
+balances into the wallets table, and --format to choose between table, json and csv output.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			cfg := config.FromContext(cmd.Context())
-
-			var client *coinbase.Client
-			if cfg.Coinbase.APIKeyName != "" && cfg.Coinbase.APIPrivateKey != "" {
-				jwtClient, err := coinbase.NewClientWithJWT(cfg.Coinbase.APIKeyName, cfg.Coinbase.APIPrivateKey)
-				if err != nil {
-					return fmt.Errorf("jwt client init: %w", err)
-				}
-				client = jwtClient
-			} else {
-				client = coinbase.NewClient(cfg.Coinbase.APIKey, cfg.Coinbase.APISecret, cfg.Coinbase.Passphrase)
-			}
-			client.Configure(cfg.Coinbase.RPM, cfg.Coinbase.MaxRetries, cfg.Coinbase.BackoffMS, cfg.App.Verbose)
-
-			accounts, err := client.ListAccounts(cmd.Context())
-			if err != nil {
-				return fmt.Errorf("failed to list accounts: %w", err)
-			}
-
-			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-			fmt.Fprintln(w, "UUID\tCurrency\tAvailable\tHold")
-			for _, acc := range accounts {
-				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", acc.UUID, acc.Currency, acc.AvailableBalance.Value, acc.Hold.Value)
-			}
-			return w.Flush()
+			return RunCoinbaseWalletSyncDown(cmd.Context(), config.FromContext(cmd.Context()), os.Stdout, opts)
 		},
 	}
+	cmd.Flags().BoolVar(&opts.Persist, "persist", false, "upsert balances into the wallets table")
+	cmd.Flags().BoolVar(&opts.NoSnapshot, "no-snapshot", false, "do not record a balance snapshot")
+	cmd.Flags().StringVar(&opts.Format, "format", "table", "output format: table, json or csv")
 	return cmd
 }
+
+// RunCoinbaseWalletSyncDown fetches all Coinbase account balances, optionally persists them, and writes them to out.
+func RunCoinbaseWalletSyncDown(ctx context.Context, cfg *config.Config, out io.Writer, opts WalletSyncDownOptions) error {
+	if opts.Format == "" {
+		opts.Format = "table"
+	}
+	switch opts.Format {
+	case "table", "json", "csv":
+	default:
+		return fmt.Errorf("unsupported format %q, expected table, json or csv", opts.Format)
+	}
+
+	client, err := newCoinbaseClient(cfg)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	accounts, err := client.ListAccounts(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list accounts: %w", err)
+	}
+
+	store, err := openStore(cfg)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	if !opts.NoSnapshot {
+		n, err := store.InsertWalletSnapshots(ctx, "coinbase", time.Now().UTC(), accounts)
+		if err != nil {
+			return fmt.Errorf("failed to record wallet snapshot: %w", err)
+		}
+		// Status goes to stderr so json/csv output stays machine readable.
+		fmt.Fprintf(os.Stderr, "Recorded snapshot of %d wallets.\n", n)
+	}
+	if opts.Persist {
+		n, err := store.UpsertWallets(ctx, "coinbase", accounts)
+		if err != nil {
+			return fmt.Errorf("failed to persist wallets: %w", err)
+		}
+		fmt.Fprintf(os.Stderr, "Persisted %d wallets.\n", n)
+	}
+
+	return writeAccounts(out, opts.Format, accounts)
+}
+
+func writeAccounts(out io.Writer, format string, accounts []coinbase.Account) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(accounts)
+	case "csv":
+		w := csv.NewWriter(out)
+		w.Write([]string{"uuid", "name", "currency", "available", "hold", "active", "default", "ready", "created_at", "updated_at", "deleted_at"})
+		for _, acc := range accounts {
+			w.Write([]string{
+				acc.UUID, acc.Name, acc.Currency, acc.AvailableBalance.Value.String(), acc.Hold.Value.String(),
+				strconv.FormatBool(acc.Active), strconv.FormatBool(acc.Default), strconv.FormatBool(acc.Ready),
+				acc.CreatedAt, acc.UpdatedAt, acc.DeletedAt,
+			})
+		}
+		w.Flush()
+		return w.Error()
+	default:
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "UUID\tCurrency\tAvailable\tHold")
+		for _, acc := range accounts {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", acc.UUID, acc.Currency, acc.AvailableBalance.Value, acc.Hold.Value)
+		}
+		return w.Flush()
+	}
+}
@@ -0,0 +1,174 @@
+package root
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/spf13/cobra"
+
+	"cryptool/internal/config"
+	"cryptool/internal/portfolio"
+)
+
+func newCoinbaseWalletValueCmd() *cobra.Command {
+	var (
+		quote       string
+		at          string
+		via         string
+		format      string
+		maxPriceAge time.Duration
+	)
+
+	cmd := &cobra.Command{
+		Use:   "value",
+		Short: "Value wallet balances in a quote currency",
+		Long: `Prices every wallet balance in the quote currency using the close of the most recent stored candle.
+
+Balances come from the latest snapshot of each account, as recorded by every wallet syncdown, and
+prices from the latest candle. With --at both are taken as of that time instead. When no snapshot
+exists yet, the balances persisted in the wallets table are used.
+
+A candle more than --max-price-age older than the valuation time is not used; the product is
+reported on stderr and the currency is priced through another route or left unpriced.
+
+Each currency is priced through <CUR>-<QUOTE> when that product has candles, else the inverse
+product, else through an intermediate currency (see --via), e.g. <CUR>-BTC then BTC-<QUOTE>.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg := config.FromContext(cmd.Context())
+			ctx := cmd.Context()
+			quote = strings.ToUpper(quote)
+
+			store, err := openStore(cfg)
+			if err != nil {
+				return err
+			}
+			defer store.Close()
+
+			priceAt := time.Now().UTC()
+			if at != "" {
+				if priceAt, err = ParseDate(at); err != nil {
+					return fmt.Errorf("invalid --at: %w", err)
+				}
+			}
+			balances, err := store.GetWalletBalancesAt(ctx, "coinbase", priceAt)
+			if err == nil && len(balances) == 0 && at == "" {
+				// Nothing snapshotted yet, e.g. only syncdown --persist --no-snapshot was run.
+				balances, err = store.GetWalletBalances(ctx, "coinbase")
+			}
+			if err != nil {
+				return fmt.Errorf("failed to load balances: %w", err)
+			}
+
+			holdings := make([]portfolio.Holding, 0, len(balances))
+			for _, b := range balances {
+				if amount := b.Available.Add(b.Hold); !amount.IsZero() {
+					holdings = append(holdings, portfolio.Holding{Currency: b.Currency, Amount: amount})
+				}
+			}
+
+			priceFn := candlePrices(ctx, store, priceAt, maxPriceAge, os.Stderr)
+
+			var intermediates []string
+			for _, v := range strings.Split(via, ",") {
+				if v = strings.ToUpper(strings.TrimSpace(v)); v != "" {
+					intermediates = append(intermediates, v)
+				}
+			}
+
+			vals, total, err := portfolio.Value(holdings, quote, intermediates, priceFn)
+			if err != nil {
+				return err
+			}
+			return writeValuation(os.Stdout, format, quote, vals, total)
+		},
+	}
+	cmd.Flags().StringVar(&quote, "quote", "USD", "quote currency to value balances in")
+	cmd.Flags().StringVar(&at, "at", "", "value balances and prices as of this time (YYYY-MM-DD or RFC3339)")
+	cmd.Flags().StringVar(&via, "via", "BTC", "comma-separated intermediate currencies used when no direct pair exists")
+	cmd.Flags().StringVar(&format, "format", "table", "output format: table, json or csv")
+	cmd.Flags().DurationVar(&maxPriceAge, "max-price-age", 24*time.Hour, "ignore candles older than this before the valuation time (0 accepts any age)")
+	return cmd
+}
+
+// closeStore looks up stored candle closes.
+type closeStore interface {
+	GetLatestClose(ctx context.Context, exchange, product string, at time.Time) (decimal.Decimal, time.Time, bool, error)
+}
+
+// cachedPrice memoizes a candle close lookup, including misses.
+type cachedPrice struct {
+	price decimal.Decimal
+	ok    bool
+}
+
+// candlePrices returns a PriceFunc that prices products from the latest stored close at or
+// before at. A close older than maxAge is reported to warn once and treated as unknown;
+// maxAge <= 0 accepts any age. Lookups, including misses, are cached.
+func candlePrices(ctx context.Context, store closeStore, at time.Time, maxAge time.Duration, warn io.Writer) portfolio.PriceFunc {
+	cache := make(map[string]cachedPrice)
+	return func(product string) (decimal.Decimal, bool, error) {
+		if c, hit := cache[product]; hit {
+			return c.price, c.ok, nil
+		}
+		p, t, ok, err := store.GetLatestClose(ctx, "coinbase", product, at)
+		if err != nil {
+			return decimal.Zero, false, err
+		}
+		if ok && maxAge > 0 && at.Sub(t) > maxAge {
+			fmt.Fprintf(warn, "Warning: ignoring %s close from %s, older than --max-price-age %s\n", product, t.Format(time.RFC3339), maxAge)
+			ok = false
+		}
+		cache[product] = cachedPrice{price: p, ok: ok}
+		return p, ok, nil
+	}
+}
+
+func writeValuation(out io.Writer, format, quote string, vals []portfolio.Valuation, total decimal.Decimal) error {
+	f := func(v decimal.Decimal) string { return v.String() }
+
+	switch format {
+	case "json":
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(map[string]interface{}{
+			"quote":  quote,
+			"assets": vals,
+			"total":  total,
+		})
+	case "csv":
+		w := csv.NewWriter(out)
+		w.Write([]string{"currency", "amount", "price", "value", "route"})
+		for _, v := range vals {
+			if !v.Priced {
+				w.Write([]string{v.Currency, f(v.Amount), "", "", ""})
+				continue
+			}
+			w.Write([]string{v.Currency, f(v.Amount), f(v.Price), f(v.Value), strings.Join(v.Route, " > ")})
+		}
+		w.Write([]string{"TOTAL", "", "", f(total), ""})
+		w.Flush()
+		return w.Error()
+	case "table", "":
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(w, "Currency\tAmount\tPrice (%s)\tValue (%s)\tRoute\n", quote, quote)
+		for _, v := range vals {
+			if !v.Priced {
+				fmt.Fprintf(w, "%s\t%s\t-\t-\tno price\n", v.Currency, f(v.Amount))
+				continue
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", v.Currency, f(v.Amount), f(v.Price), v.Value.StringFixed(2), strings.Join(v.Route, " > "))
+		}
+		fmt.Fprintf(w, "TOTAL\t\t\t%s\t\n", total.StringFixed(2))
+		return w.Flush()
+	default:
+		return fmt.Errorf("unsupported format %q, expected table, json or csv", format)
+	}
+}
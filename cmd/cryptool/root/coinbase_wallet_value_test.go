@@ -0,0 +1,52 @@
+package root
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// closes serves fixed candle closes keyed by product.
+type closes map[string]struct {
+	price string
+	at    time.Time
+}
+
+func (c closes) GetLatestClose(ctx context.Context, exchange, product string, at time.Time) (decimal.Decimal, time.Time, bool, error) {
+	v, ok := c[product]
+	if !ok || v.at.After(at) {
+		return decimal.Zero, time.Time{}, false, nil
+	}
+	return decimal.RequireFromString(v.price), v.at, true, nil
+}
+
+func TestCandlePricesRejectsStaleCloses(t *testing.T) {
+	now := time.Date(2025, 6, 30, 12, 0, 0, 0, time.UTC)
+	store := closes{
+		"BTC-USD": {"60000", now.Add(-time.Minute)},
+		"ETH-USD": {"1800", now.AddDate(0, -3, 0)},
+	}
+	var warn bytes.Buffer
+	price := candlePrices(context.Background(), store, now, 24*time.Hour, &warn)
+
+	if p, ok, err := price("BTC-USD"); err != nil || !ok || p.String() != "60000" {
+		t.Errorf("BTC-USD = %s, %v, %v; want the fresh close", p, ok, err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, ok, err := price("ETH-USD"); err != nil || ok {
+			t.Errorf("ETH-USD: ok = %v, err = %v; want the three-month-old close rejected", ok, err)
+		}
+	}
+	if n := strings.Count(warn.String(), "ignoring ETH-USD close"); n != 1 {
+		t.Errorf("warnings = %q, want one for ETH-USD", warn.String())
+	}
+
+	// Without a bound any close is used.
+	if _, ok, _ := candlePrices(context.Background(), store, now, 0, &warn)("ETH-USD"); !ok {
+		t.Error("ETH-USD should be priced when --max-price-age is 0")
+	}
+}
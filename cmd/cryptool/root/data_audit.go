@@ -0,0 +1,217 @@
+package root
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"cryptool/internal/audit"
+	"cryptool/internal/backfill"
+	"cryptool/internal/config"
+	"cryptool/internal/exchange"
+)
+
+// newAuditCmd returns the audit command for exchangeName, or with an --exchange flag when exchangeName is empty.
+func newAuditCmd(exchangeName string) *cobra.Command {
+	var opts AuditOptions
+
+	cmd := &cobra.Command{
+		Use:   "audit [start-date] [end-date]",
+		Short: "Check stored candles for anomalies",
+		Long: `Scans the stored candles of one product for impossible or suspicious values: high below low,
+open or close outside [low, high], zero or negative prices, timestamps off a bucket boundary or
+sharing a bucket, and highs or lows far from the mean close of the --window candles on either side.
+The distance is measured in the larger of those closes' standard deviation and the candles' mean
+high-low range, and --sigma of those counts as a spike.
+
+With --compare every audited bucket is requested from the exchange again and stored candles that
+differ from, or are missing in, the response are reported too. This costs one request per
+exchange page, so narrow the range for long 1m histories.
+
+--quarantine moves the flagged candles to the candles_quarantine table, so the next fetch sees
+them as missing. --refetch overwrites them with the exchange's candles. With both, flagged rows
+are quarantined first and the refetched candles take their place.
+
+If start-date and end-date are omitted the whole history from the product's launch date is audited.`,
+		Args: cobra.MaximumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.Exchange = exchangeName
+			var err error
+			if len(args) > 0 {
+				opts.Start, err = ParseDate(args[0])
+				if err != nil {
+					return fmt.Errorf("invalid start-date: %w", err)
+				}
+			}
+			if len(args) > 1 {
+				opts.End, err = ParseDate(args[1])
+				if err != nil {
+					return fmt.Errorf("invalid end-date: %w", err)
+				}
+			}
+			switch opts.Format {
+			case "table", "json":
+			default:
+				return fmt.Errorf("unsupported format %q, expected table or json", opts.Format)
+			}
+			return RunAudit(cmd.Context(), config.FromContext(cmd.Context()), os.Stdout, opts)
+		},
+	}
+	addExchangeFlag(cmd, &exchangeName)
+	cmd.Flags().StringVar(&opts.Product, "product", "", "product id, e.g. BTC-USD")
+	cmd.Flags().StringVar(&opts.Granularity, "granularity", "1m", "candle granularity, e.g., 1m, 5m, 15m, 30m, 1h, 2h, 6h, 1d")
+	cmd.Flags().Float64Var(&opts.Sigma, "sigma", audit.DefaultSpikeSigma, "distance from the neighbours' mean close that counts as a spike, in standard deviations or mean ranges")
+	cmd.Flags().IntVar(&opts.Window, "window", audit.DefaultSpikeWindow, "candles on each side the spike check compares against")
+	cmd.Flags().BoolVar(&opts.Compare, "compare", false, "re-fetch the range from the exchange and report differences")
+	cmd.Flags().BoolVar(&opts.Quarantine, "quarantine", false, "move flagged candles to candles_quarantine")
+	cmd.Flags().BoolVar(&opts.Refetch, "refetch", false, "overwrite flagged candles with a fresh copy from the exchange")
+	cmd.Flags().StringVar(&opts.Format, "format", "table", "output format: table or json")
+	return cmd
+}
+
+// AuditOptions describes a candle audit of one product.
+// An empty Exchange defaults to coinbase. A zero Start defaults to the product's launch date;
+// a zero End defaults to now.
+type AuditOptions struct {
+	Exchange    string
+	Product     string
+	Granularity string
+	Start       time.Time
+	End         time.Time
+	Sigma       float64
+	Window      int
+	Compare     bool
+	Quarantine  bool
+	Refetch     bool
+	Format      string
+}
+
+// RunAudit audits the stored candles of one product, applies the requested repairs and writes
+// the report to out.
+func RunAudit(ctx context.Context, cfg *config.Config, out io.Writer, opts AuditOptions) error {
+	if opts.Product == "" {
+		return errors.New("--product is required, e.g. BTC-USD")
+	}
+	if opts.Granularity == "" {
+		opts.Granularity = "1m"
+	}
+	name := opts.Exchange
+	if name == "" {
+		name = "coinbase"
+	}
+
+	// The exchange is only needed to compare or refetch; a plain audit works offline.
+	var x exchange.Exchange
+	if opts.Compare || opts.Refetch {
+		var err error
+		if x, err = openExchange(name, cfg); err != nil {
+			return err
+		}
+		defer exchange.Close(x)
+		if err := checkGranularity(x, opts.Granularity); err != nil {
+			return err
+		}
+		name = x.Name()
+	} else if !isExchangeName(name) {
+		return fmt.Errorf("unknown exchange %q (available: %s)", name, strings.Join(exchange.Names(), ", "))
+	} else if _, err := backfill.ParseGranularity(opts.Granularity); err != nil {
+		return err
+	}
+
+	store, err := openStore(cfg)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	start := opts.Start
+	if start.IsZero() {
+		start, err = store.GetProductNewAt(ctx, name, opts.Product)
+		if err != nil {
+			return fmt.Errorf("get product new_at: %w", err)
+		}
+	}
+	end := opts.End
+	if end.IsZero() {
+		end = time.Now()
+	}
+	if !end.After(start) {
+		return errors.New("end-date must be after start-date")
+	}
+
+	a := audit.New(name, store)
+	a.Options = audit.Options{SpikeSigma: opts.Sigma, SpikeWindow: opts.Window}
+	if opts.Compare {
+		a.Source = x
+	}
+	r, err := a.Run(ctx, opts.Product, opts.Granularity, start, end)
+	if err != nil {
+		return err
+	}
+
+	if opts.Quarantine {
+		if _, err := a.Quarantine(ctx, r); err != nil {
+			return fmt.Errorf("quarantine: %w", err)
+		}
+	}
+	if opts.Refetch {
+		a.Source = x
+		if _, err := a.Refetch(ctx, r); err != nil {
+			return fmt.Errorf("refetch: %w", err)
+		}
+	}
+
+	if opts.Format == "json" {
+		return printJSON(out, r)
+	}
+	return printAuditReport(out, r, opts)
+}
+
+func printAuditReport(out io.Writer, r *audit.Report, opts AuditOptions) error {
+	date := func(t time.Time) string { return t.UTC().Format(time.RFC3339) }
+	fmt.Fprintf(out, "Audited %d %s candles of %s on %s in [%s - %s)\n", r.Candles, r.Granularity, r.Product, r.Exchange, date(r.Start), date(r.End))
+	if len(r.Findings) == 0 {
+		fmt.Fprintln(out, "No anomalies found.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Time\tKind\tDetail")
+	for _, f := range r.Findings {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", date(f.Time), f.Kind, f.Detail)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Kind\tCount")
+	counts := r.Counts()
+	kinds := make([]string, 0, len(counts))
+	for k := range counts {
+		kinds = append(kinds, string(k))
+	}
+	sort.Strings(kinds)
+	for _, k := range kinds {
+		fmt.Fprintf(w, "%s\t%d\n", k, counts[audit.Kind(k)])
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if opts.Quarantine || opts.Refetch {
+		fmt.Fprintln(out)
+	}
+	if opts.Quarantine {
+		fmt.Fprintf(out, "Quarantined %d candles.\n", r.Quarantined)
+	}
+	if opts.Refetch {
+		fmt.Fprintf(out, "Refetched %d candles.\n", r.Refetched)
+	}
+	return nil
+}
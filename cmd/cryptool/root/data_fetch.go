@@ -0,0 +1,211 @@
+package root
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"cryptool/internal/backfill"
+	"cryptool/internal/config"
+	"cryptool/internal/db"
+	"cryptool/internal/exchange"
+	"cryptool/internal/ingest"
+)
+
+// newDataFetchCmd returns the fetch command for exchangeName, or with an --exchange flag when exchangeName is empty.
+func newDataFetchCmd(exchangeName string) *cobra.Command {
+	var (
+		product     string
+		granularity string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "fetch [start-date] [end-date]",
+		Short: "Fetch historical candles, filling any gaps",
+		Long: `Fetches historical candle data from the exchange for a given product.
+
+This command intelligently identifies and fills any gaps in the local database. If start-date and end-date are omitted, it will backfill all data from the product's launch date to the present.`,
+		Args: cobra.MaximumNArgs(2), 
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts := FetchOptions{Exchange: exchangeName, Product: product, Granularity: granularity}
+			var err error
+			if len(args) > 0 {
+				opts.Start, err = ParseDate(args[0])
+				if err != nil {
+					return fmt.Errorf("invalid start-date: %w", err)
+				}
+			}
+			if len(args) > 1 {
+				opts.End, err = ParseDate(args[1])
+				if err != nil {
+					return fmt.Errorf("invalid end-date: %w", err)
+				}
+			}
+			return RunFetch(cmd.Context(), config.FromContext(cmd.Context()), opts)
+		},
+	}
+	addExchangeFlag(cmd, &exchangeName)
+	cmd.Flags().StringVar(&product, "product", "", "product id, e.g. BTC-USD")
+	cmd.Flags().StringVar(&granularity, "granularity", "1h", "candle granularity, e.g., 1m, 5m, 15m, 30m, 1h, 2h, 6h, 1d")
+	return cmd
+}
+
+// FetchOptions describes a single-product candle backfill.
+// An empty Exchange defaults to coinbase. A zero Start defaults to the product's launch date;
+// a zero End defaults to now.
+type FetchOptions struct {
+	Exchange    string
+	Product     string
+	Granularity string
+	Start       time.Time
+	End         time.Time
+}
+
+// RunFetch fetches historical candles for one product, filling any gaps in the local database.
+func RunFetch(ctx context.Context, cfg *config.Config, opts FetchOptions) error {
+	product := opts.Product
+	granularity := opts.Granularity
+	if product == "" {
+		return errors.New("--product is required, e.g. BTC-USD")
+	}
+	if granularity == "" {
+		granularity = "1h"
+	}
+
+	x, err := openExchange(opts.Exchange, cfg)
+	if err != nil {
+		return err
+	}
+	defer exchange.Close(x)
+	if err := checkGranularity(x, granularity); err != nil {
+		return err
+	}
+	store, err := openStore(cfg)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	start := opts.Start
+	if start.IsZero() {
+		start, err = historyStart(ctx, x, store, product, granularity)
+		if err != nil {
+			return fmt.Errorf("get product start: %w", err)
+		}
+	}
+	end := opts.End
+	if end.IsZero() {
+		end = time.Now()
+	}
+	if !end.After(start) {
+		return errors.New("end-date must be after start-date")
+	}
+
+	// Validate product ID
+	products, err := x.GetProducts(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get products for validation: %w", err)
+	}
+	validProduct := false
+	for _, p := range products {
+		if p.ProductID == product {
+			validProduct = true
+			break
+		}
+	}
+	if !validProduct {
+		return fmt.Errorf("invalid product ID: %s", product)
+	}
+
+	bf := backfill.New(x, store)
+	bf.OnEvent = printBackfillEvent("")
+	totalInserted, err := bf.Fill(ctx, product, granularity, start, end)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Fetch complete. Inserted %d new candles.\n", totalInserted)
+
+	return nil
+}
+
+// ParseDate accepts RFC3339 or YYYY-MM-DD dates.
+func ParseDate(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("unsupported date format: %s", s)
+}
+
+// printBackfillEvent returns a progress printer for backfill events.
+// prefix is prepended to batch lines, e.g. to tag the product in multi-product runs.
+func printBackfillEvent(prefix string) func(backfill.Event) {
+	return func(ev backfill.Event) {
+		switch ev.Kind {
+		case backfill.EventBatch:
+			fmt.Printf("%sBatch %d: fetching %d potential gaps in [%s - %s]\n", prefix, ev.Batch, ev.Gaps, ev.Start.Format(time.RFC3339), ev.End.Format(time.RFC3339))
+		case backfill.EventInserted:
+			fmt.Printf("         -> inserted %d of %d candles\n", ev.Inserted, ev.Received)
+		case backfill.EventGapMarked:
+			fmt.Printf("         -> marking gap at %s as empty\n", ev.Time.Format(time.RFC3339))
+		case backfill.EventGapError:
+			fmt.Printf("         -> error marking gap for %s: %v\n", ev.Time.Format(time.RFC3339), ev.Err)
+		}
+	}
+}
+
+// listingStore looks up the stored listing date of a product.
+type listingStore interface {
+	GetProductNewAt(ctx context.Context, exchange, product string) (time.Time, error)
+}
+
+// historyStart returns where the candle history of product begins: its stored listing date or,
+// when the exchange reports none, the earliest start the adapter can serve at granularity.
+func historyStart(ctx context.Context, x exchange.Exchange, store listingStore, product, granularity string) (time.Time, error) {
+	start, err := store.GetProductNewAt(ctx, x.Name(), product)
+	if !errors.Is(err, ingest.ErrNoListingDate) {
+		return start, err
+	}
+	hs, ok := x.(exchange.HistoryStarter)
+	if !ok {
+		return time.Time{}, err
+	}
+	return hs.HistoryStart(ctx, product, granularity)
+}
+
+// openExchange opens the named exchange adapter, defaulting to coinbase.
+func openExchange(name string, cfg *config.Config) (exchange.Exchange, error) {
+	if name == "" {
+		name = "coinbase"
+	}
+	return exchange.Open(name, cfg)
+}
+
+// openStore opens the candle store with the pool settings from cfg. Callers must Close it.
+func openStore(cfg *config.Config) (*ingest.Store, error) {
+	return ingest.NewStore(cfg.Database.URL, db.PoolFromConfig(cfg))
+}
+
+// addExchangeFlag registers --exchange on cmd when the command is not bound to one exchange.
+func addExchangeFlag(cmd *cobra.Command, name *string) {
+	if *name != "" {
+		return
+	}
+	cmd.Flags().StringVar(name, "exchange", "coinbase", "exchange to use ("+strings.Join(exchange.Names(), ", ")+")")
+}
+
+// checkGranularity rejects a granularity that is unknown or that x cannot serve, so a command
+// fails when it starts instead of at its first fetch.
+func checkGranularity(x exchange.MarketData, granularity string) error {
+	if _, err := backfill.ParseGranularity(granularity); err != nil {
+		return err
+	}
+	return exchange.CheckGranularity(x, granularity)
+}
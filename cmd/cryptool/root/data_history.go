@@ -0,0 +1,142 @@
+package root
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"cryptool/internal/backfill"
+	"cryptool/internal/config"
+	"cryptool/internal/exchange"
+)
+
+// newHistoryCmd returns the history command for exchangeName, or with an --exchange flag when exchangeName is empty.
+func newHistoryCmd(exchangeName string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "history",
+		Short: "Fetch 1m candles for all products",
+		Long:  `Iterates through all known, tradable products and fetches their 1-minute candle history, filling any gaps.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return RunHistory(cmd.Context(), config.FromContext(cmd.Context()), exchangeName)
+		},
+	}
+	addExchangeFlag(cmd, &exchangeName)
+	return cmd
+}
+
+// RunHistory walks all tradable products of an exchange (default coinbase) day by day and fills their 1-minute candle history.
+func RunHistory(ctx context.Context, cfg *config.Config, exchangeName string) error {
+	x, err := openExchange(exchangeName, cfg)
+	if err != nil {
+		return err
+	}
+	defer exchange.Close(x)
+	store, err := openStore(cfg)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	return runHistory(ctx, x, store)
+}
+
+// historyStore is the subset of ingest.Store RunHistory needs.
+type historyStore interface {
+	backfill.Store
+	listingStore
+	GetAllProducts(ctx context.Context, exchange string) ([]string, error)
+}
+
+func runHistory(ctx context.Context, x exchange.Exchange, store historyStore) error {
+	granularity := "1m"
+
+	products, err := store.GetAllProducts(ctx, x.Name())
+	if err != nil {
+		return fmt.Errorf("failed to get products: %w", err)
+	}
+
+	fmt.Printf("Found %d products to sync\n", len(products))
+
+	// Helper to clamp to later of two times
+	maxTime := func(a, b time.Time) time.Time {
+		if a.After(b) { return a }
+		return b
+	}
+
+	// Pre-compute per-product start dates and global earliest product start
+	productStarts := make(map[string]time.Time, len(products))
+	globalMin := time.Now().UTC()
+	for _, p := range products {
+		s, err := historyStart(ctx, x, store, p, granularity)
+		if err != nil {
+			if errors.Is(err, exchange.ErrUnauthorized) {
+				return fmt.Errorf("stopping history for %s: %w", p, err)
+			}
+			fmt.Printf("SKIPPING: could not get start date for %s: %v\n", p, err)
+			continue
+		}
+		productStarts[p] = s
+		if s.Before(globalMin) {
+			globalMin = s
+		}
+	}
+
+	// Capture 'now' once for consistent clamping
+	nowUTC := time.Now().UTC().Truncate(time.Second)
+
+	bf := backfill.New(x, store)
+	bf.Now = func() time.Time { return nowUTC }
+
+	// Day-by-day across products: today back to earliest product start
+	now := time.Now().UTC()
+	todayEnd := now.Truncate(24 * time.Hour).Add(24 * time.Hour) // exclusive end-of-today
+	for dayEnd := todayEnd; dayEnd.After(globalMin); dayEnd = dayEnd.AddDate(0, 0, -1) {
+		dayStart := dayEnd.Add(-24 * time.Hour)
+		// Clamp current day's end to 'now' to avoid future timestamps
+		curEnd := dayEnd
+		if curEnd.After(nowUTC) { curEnd = nowUTC }
+		if dayStart.After(curEnd) {
+			// Entire window would be in the future; skip
+			continue
+		}
+		fmt.Printf("\n=== Day window: [%s - %s) ===\n", dayStart.Format("2006-01-02"), curEnd.Format("2006-01-02"))
+
+		for _, product := range products {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			pStart, ok := productStarts[product]
+			if !ok {
+				continue
+			}
+			if pStart.After(dayEnd) {
+				continue // product did not exist yet in this window
+			}
+			effStart := maxTime(pStart, dayStart)
+			if !effStart.Before(curEnd) {
+				continue
+			}
+			bf.OnEvent = printBackfillEvent(fmt.Sprintf("  [%s] ", product))
+			inserted, err := bf.Fill(ctx, product, granularity, effStart, curEnd)
+			if err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				// Bad credentials fail every product alike; stop instead of skipping through history.
+				if errors.Is(err, exchange.ErrUnauthorized) {
+					return fmt.Errorf("stopping history for %s: %w", product, err)
+				}
+				fmt.Printf("ERROR for %s in day [%s]: %v\n", product, dayStart.Format("2006-01-02"), err)
+				continue
+			}
+			if inserted > 0 {
+				fmt.Printf("Inserted %d candles for %s in %s\n", inserted, product, dayStart.Format("2006-01-02"))
+			}
+		}
+	}
+
+	fmt.Println("\n--- All day windows processed ---")
+	return nil
+}
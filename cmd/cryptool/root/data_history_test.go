@@ -0,0 +1,145 @@
+package root
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"cryptool/internal/exchange"
+	"cryptool/internal/ingest"
+	"cryptool/internal/kraken"
+)
+
+// memHistoryStore keeps candles in memory and has no listing dates, like a products table
+// synced from Kraken.
+type memHistoryStore struct {
+	mu       sync.Mutex
+	products []string
+	candles  map[string]map[time.Time]bool
+	gaps     map[string]map[time.Time]bool
+}
+
+func (s *memHistoryStore) GetAllProducts(ctx context.Context, x string) ([]string, error) {
+	return s.products, nil
+}
+
+func (s *memHistoryStore) GetProductNewAt(ctx context.Context, x, product string) (time.Time, error) {
+	return time.Time{}, fmt.Errorf("product %s: %w", product, ingest.ErrNoListingDate)
+}
+
+func (s *memHistoryStore) missing(product string, start, end time.Time, granularitySec int) []time.Time {
+	var out []time.Time
+	for t := start; t.Before(end); t = t.Add(time.Duration(granularitySec) * time.Second) {
+		if !s.candles[product][t] && !s.gaps[product][t] {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+func (s *memHistoryStore) CountGapsToFill(ctx context.Context, x, product string, start, end time.Time, granularitySec int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.missing(product, start, end, granularitySec)), nil
+}
+
+func (s *memHistoryStore) GetMissingCandleTimestamps(ctx context.Context, x, product string, start, end time.Time, granularitySec int) ([]time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.missing(product, start, end, granularitySec), nil
+}
+
+func (s *memHistoryStore) InsertCandles(ctx context.Context, x, product string, granularitySec int, candles []exchange.Candle) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, c := range candles {
+		if !s.candles[product][c.Time] {
+			s.candles[product][c.Time] = true
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (s *memHistoryStore) MarkGaps(ctx context.Context, x, product string, granularitySec int, times []time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range times {
+		s.gaps[product][t] = true
+	}
+	return len(times), nil
+}
+
+// krakenServer serves the Kraken AssetPairs fixture and one OHLC entry per minute since the
+// requested time, up to Kraken's 720-entry limit.
+func krakenServer(t *testing.T) (*httptest.Server, *[]time.Time) {
+	t.Helper()
+	var mu sync.Mutex
+	var since []time.Time
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/0/public/AssetPairs":
+			data, err := os.ReadFile(filepath.Join("..", "..", "..", "internal", "kraken", "testdata", "asset_pairs.json"))
+			if err != nil {
+				t.Errorf("read fixture: %v", err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.Write(data)
+		case "/0/public/OHLC":
+			sec, _ := strconv.ParseInt(r.URL.Query().Get("since"), 10, 64)
+			first := time.Unix(sec+1, 0).UTC().Truncate(time.Minute)
+			mu.Lock()
+			since = append(since, first)
+			mu.Unlock()
+			var rows [][]interface{}
+			for ts := first; !ts.After(time.Now()) && len(rows) < kraken.MaxCandlesPerRequest; ts = ts.Add(time.Minute) {
+				rows = append(rows, []interface{}{ts.Unix(), "1.0", "2.0", "0.5", "1.5", "1.2", "10", 3})
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error":  []string{},
+				"result": map[string]interface{}{r.URL.Query().Get("pair"): rows, "last": first.Unix()},
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &since
+}
+
+func TestRunHistoryWithoutListingDates(t *testing.T) {
+	srv, since := krakenServer(t)
+	products := []string{"BTC-USD", "ETH-EUR"}
+	store := &memHistoryStore{products: products, candles: map[string]map[time.Time]bool{}, gaps: map[string]map[time.Time]bool{}}
+	for _, p := range products {
+		store.candles[p] = map[time.Time]bool{}
+		store.gaps[p] = map[time.Time]bool{}
+	}
+
+	if err := runHistory(context.Background(), kraken.NewClient(srv.URL), store); err != nil {
+		t.Fatalf("runHistory: %v", err)
+	}
+
+	// Every product is backfilled from the oldest entry Kraken serves instead of being skipped.
+	oldest := time.Now().UTC().Truncate(time.Minute).Add(-(kraken.MaxCandlesPerRequest - 1) * time.Minute)
+	for _, p := range products {
+		if n := len(store.candles[p]); n < kraken.MaxCandlesPerRequest-2 {
+			t.Errorf("%s: stored %d candles, want about %d", p, n, kraken.MaxCandlesPerRequest)
+		}
+	}
+	for _, s := range *since {
+		if s.Before(oldest.Add(-time.Minute)) {
+			t.Errorf("requested OHLC since %s, before the oldest served entry %s", s, oldest)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package root
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"cryptool/internal/config"
+	"cryptool/internal/exchange"
+)
+
+// newProductsSyncCmd returns the sync-products command for exchangeName, or with an --exchange flag when exchangeName is empty.
+func newProductsSyncCmd(exchangeName string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "sync-products",
+		Short: "Sync all tradeable products from the exchange",
+		Long:  `Fetches all available tradeable products from the exchange API and upserts them into the local database.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return RunProductsSync(cmd.Context(), config.FromContext(cmd.Context()), exchangeName)
+		},
+	}
+	addExchangeFlag(cmd, &exchangeName)
+	return cmd
+}
+
+// RunProductsSync fetches all products of an exchange (default coinbase) and upserts them into the database.
+func RunProductsSync(ctx context.Context, cfg *config.Config, exchangeName string) error {
+	x, err := openExchange(exchangeName, cfg)
+	if err != nil {
+		return err
+	}
+	defer exchange.Close(x)
+
+	fmt.Printf("Fetching products from %s...\n", x.Name())
+	products, err := x.GetProducts(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get products: %w", err)
+	}
+	fmt.Printf("Found %d products.\n", len(products))
+
+	store, err := openStore(cfg)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	fmt.Println("Upserting products into database...")
+	rowsAffected, err := store.UpsertProducts(ctx, x.Name(), products)
+	if err != nil {
+		return fmt.Errorf("failed to upsert products: %w", err)
+	}
+
+	fmt.Printf("Sync complete. Upserted %d products.\n", rowsAffected)
+	return nil
+}
@@ -0,0 +1,123 @@
+package root
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"cryptool/internal/backfill"
+	"cryptool/internal/config"
+	"cryptool/internal/exchange"
+)
+
+// newRollupCmd returns the rollup command for exchangeName, or with an --exchange flag when exchangeName is empty.
+func newRollupCmd(exchangeName string) *cobra.Command {
+	var opts RollupOptions
+	var granularities string
+
+	cmd := &cobra.Command{
+		Use:   "rollup",
+		Short: "Derive higher-timeframe candles from stored 1m candles",
+		Long: `Aggregates stored 1-minute candles into 5m, 1h, 1d or other candles without calling the exchange.
+
+A bucket is only written once it has closed and every minute in it has a candle or lies in a gap
+the backfill gave up on. Each run recomputes only the buckets whose 1-minute data changed since the
+previous run; --full recomputes everything. With --view the rollups of all products are instead kept
+in one materialized view per granularity (candles_rollup_<seconds>), refreshed in full on every run.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.Exchange = exchangeName
+			opts.Granularities = strings.Split(granularities, ",")
+			return RunRollup(cmd.Context(), config.FromContext(cmd.Context()), opts)
+		},
+	}
+	addExchangeFlag(cmd, &exchangeName)
+	cmd.Flags().StringVar(&opts.Product, "product", "", "product id, e.g. BTC-USD (default all products)")
+	cmd.Flags().StringVar(&granularities, "granularity", "5m,15m,1h,1d", "comma-separated target granularities, e.g. 5m,1h,1d")
+	cmd.Flags().BoolVar(&opts.Full, "full", false, "recompute every bucket instead of only those touched since the last run")
+	cmd.Flags().BoolVar(&opts.View, "view", false, "refresh materialized views for all products instead of writing to candles")
+	return cmd
+}
+
+// RollupOptions describes a rollup run. An empty Exchange defaults to coinbase and an empty
+// Product to every product of the exchange.
+type RollupOptions struct {
+	Exchange      string
+	Product       string
+	Granularities []string
+	Full          bool
+	View          bool
+}
+
+// RunRollup derives candles of each target granularity from the stored 1-minute candles.
+func RunRollup(ctx context.Context, cfg *config.Config, opts RollupOptions) error {
+	name := opts.Exchange
+	if name == "" {
+		name = "coinbase"
+	}
+	if !isExchangeName(name) {
+		return fmt.Errorf("unknown exchange %q (available: %s)", name, strings.Join(exchange.Names(), ", "))
+	}
+	var seconds []int
+	for _, g := range opts.Granularities {
+		sec, err := backfill.ParseGranularity(strings.TrimSpace(g))
+		if err != nil {
+			return err
+		}
+		seconds = append(seconds, int(sec))
+	}
+
+	store, err := openStore(cfg)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	if opts.View {
+		for _, sec := range seconds {
+			view, err := store.RefreshRollupView(ctx, sec)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Refreshed %s\n", view)
+		}
+		return nil
+	}
+
+	products := []string{opts.Product}
+	if opts.Product == "" {
+		products, err = store.GetAllProducts(ctx, name)
+		if err != nil {
+			return fmt.Errorf("failed to get products: %w", err)
+		}
+	}
+
+	for _, product := range products {
+		for i, sec := range seconds {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			res, err := store.RollupCandles(ctx, name, product, sec, opts.Full)
+			if err != nil {
+				return fmt.Errorf("roll up %s to %s: %w", product, opts.Granularities[i], err)
+			}
+			if res.Candles > 0 {
+				fmt.Printf("Rolled up %d %s candles for %s (1m data through %s)\n",
+					res.Candles, strings.TrimSpace(opts.Granularities[i]), product, res.Watermark.UTC().Format(time.RFC3339))
+			}
+		}
+	}
+	return nil
+}
+
+// isExchangeName reports whether name is a registered exchange.
+func isExchangeName(name string) bool {
+	for _, n := range exchange.Names() {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
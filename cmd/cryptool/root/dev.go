@@ -0,0 +1,112 @@
+package root
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"cryptool/internal/coinbase/fakeserver"
+	"cryptool/internal/config"
+)
+
+// NewDevCmd groups tools for local development and CI.
+func NewDevCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "dev",
+		Short: "Development and testing tools",
+	}
+	cmd.AddCommand(newFakeCoinbaseCmd())
+	return cmd
+}
+
+func newFakeCoinbaseCmd() *cobra.Command {
+	var (
+		addr      string
+		products  string
+		listedAt  string
+		verifyJWT bool
+		opts      fakeserver.Options
+	)
+
+	cmd := &cobra.Command{
+		Use:   "fake-coinbase",
+		Short: "Serve a fake Coinbase Advanced Trade API for offline testing",
+		Long: `Serves products, candles, accounts and the order endpoints of the Coinbase Advanced Trade REST API
+from deterministic generated data. Point the tool at it with COINBASE_BASE_URL=http://<addr>.
+
+Candles are generated from --listed-at until now; the same --seed always yields the same prices.
+--hole-rate and --error-every inject missing candle buckets and failing requests to exercise gap
+marking and retries. With --verify-jwt every private endpoint requires a JWT signed with the
+configured CDP API key.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg := config.FromContext(cmd.Context())
+			for _, p := range strings.Split(products, ",") {
+				if p = strings.TrimSpace(p); p != "" {
+					opts.Products = append(opts.Products, strings.ToUpper(p))
+				}
+			}
+			if listedAt != "" {
+				t, err := time.Parse("2006-01-02", listedAt)
+				if err != nil {
+					return fmt.Errorf("invalid --listed-at %q, want YYYY-MM-DD: %w", listedAt, err)
+				}
+				opts.ListedAt = t
+			}
+			if verifyJWT {
+				if cfg.Coinbase.APIKeyName == "" || cfg.Coinbase.APIPrivateKey == "" {
+					return errors.New("--verify-jwt needs COINBASE_API_KEY_NAME and COINBASE_API_PRIVATE_KEY (or --coinbase-creds)")
+				}
+				key, err := fakeserver.ParsePublicKey(cfg.Coinbase.APIPrivateKey)
+				if err != nil {
+					return err
+				}
+				opts.Keys = map[string]*ecdsa.PublicKey{cfg.Coinbase.APIKeyName: key}
+			}
+			return runFakeCoinbase(cmd.Context(), addr, opts)
+		},
+	}
+	cmd.Flags().StringVar(&addr, "addr", "127.0.0.1:8089", "address to listen on")
+	cmd.Flags().StringVar(&products, "products", strings.Join(fakeserver.DefaultProducts, ","), "comma-separated product ids to serve")
+	cmd.Flags().Int64Var(&opts.Seed, "seed", 1, "seed for generated prices and holes")
+	cmd.Flags().StringVar(&listedAt, "listed-at", "", "listing date of every product, YYYY-MM-DD (default: two days ago)")
+	cmd.Flags().Float64Var(&opts.HoleRate, "hole-rate", 0, "fraction of candle buckets with no data, e.g. 0.05")
+	cmd.Flags().IntVar(&opts.ErrorEvery, "error-every", 0, "fail every n-th request (0 disables)")
+	cmd.Flags().IntVar(&opts.ErrorStatus, "error-status", http.StatusTooManyRequests, "HTTP status of injected failures")
+	cmd.Flags().BoolVar(&verifyJWT, "verify-jwt", false, "require JWTs signed with the configured CDP API key")
+	return cmd
+}
+
+// runFakeCoinbase serves the fake API on addr until ctx is cancelled or the process is interrupted.
+func runFakeCoinbase(ctx context.Context, addr string, opts fakeserver.Options) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("listen on %s: %w", addr, err)
+	}
+	srv := &http.Server{Handler: fakeserver.New(opts), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	fmt.Fprintf(os.Stderr, "Fake Coinbase API listening on http://%s\n", ln.Addr())
+	fmt.Fprintf(os.Stderr, "Use it with COINBASE_BASE_URL=http://%s\n", ln.Addr())
+	if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -8,5 +8,20 @@ func NewExchangeCmd() *cobra.Command {
 		Short: "Exchange related commands",
 	}
 	cmd.AddCommand(NewCoinbaseCmd())
+	cmd.AddCommand(newDataCmd())
+	return cmd
+}
+
+// newDataCmd groups the exchange-neutral data commands, which take an --exchange flag.
+func newDataCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "data",
+		Short: "Candle and product data commands for any supported exchange",
+	}
+	cmd.AddCommand(newDataFetchCmd(""))
+	cmd.AddCommand(newProductsSyncCmd(""))
+	cmd.AddCommand(newHistoryCmd(""))
+	cmd.AddCommand(newRollupCmd(""))
+	cmd.AddCommand(newAuditCmd(""))
 	return cmd
 }
@@ -0,0 +1,8 @@
+package root
+
+// Exchange adapters register themselves with internal/exchange when imported.
+// Coinbase is imported directly by the Coinbase commands.
+import (
+	_ "cryptool/internal/binance"
+	_ "cryptool/internal/kraken"
+)
@@ -28,7 +28,7 @@ func NewMigrateCmd(migrationsFS embed.FS) *cobra.Command {
 		Long:  `Displays a table of all discovered migrations and indicates whether each one has been applied to the database.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			cfg := getConfig(cmd)
-			if err := migrate.Status(cmd.Context(), cfg.Database.URL, migrationsFS); err != nil {
+			if err := migrate.Status(cmd.Context(), cmd.OutOrStdout(), cfg.Database.URL, migrationsFS); err != nil {
 				return fmt.Errorf("status failed: %w", err)
 			}
 			return nil
@@ -42,7 +42,7 @@ func NewMigrateCmd(migrationsFS embed.FS) *cobra.Command {
 		Long:  `Applies all available 'up' migrations that have not yet been run on the database.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			cfg := getConfig(cmd)
-			if err := migrate.Up(cmd.Context(), cfg.Database.URL, migrationsFS); err != nil {
+			if err := migrate.Up(cmd.Context(), cmd.OutOrStdout(), cfg.Database.URL, migrationsFS); err != nil {
 				return fmt.Errorf("up failed: %w", err)
 			}
 			return nil
@@ -57,7 +57,7 @@ func NewMigrateCmd(migrationsFS embed.FS) *cobra.Command {
 		Long:  `Rolls back the most recent migration. Use the --step flag to roll back multiple migrations.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			cfg := getConfig(cmd)
-			if err := migrate.Down(cmd.Context(), cfg.Database.URL, steps, migrationsFS); err != nil {
+			if err := migrate.Down(cmd.Context(), cmd.OutOrStdout(), cfg.Database.URL, steps, migrationsFS); err != nil {
 				return fmt.Errorf("down failed: %w", err)
 			}
 			return nil
@@ -72,7 +72,7 @@ func NewMigrateCmd(migrationsFS embed.FS) *cobra.Command {
 		Long:  `Rolls back all existing migrations to version 0 and then applies all migrations again. This is a destructive operation.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			cfg := getConfig(cmd)
-			if err := migrate.Reset(cmd.Context(), cfg.Database.URL, migrationsFS); err != nil {
+			if err := migrate.Reset(cmd.Context(), cmd.OutOrStdout(), cfg.Database.URL, migrationsFS); err != nil {
 				return fmt.Errorf("reset failed: %w", err)
 			}
 			return nil
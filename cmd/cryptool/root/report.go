@@ -0,0 +1,13 @@
+package root
+
+import "github.com/spf13/cobra"
+
+// NewReportCmd groups reports computed from locally stored data.
+func NewReportCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "report",
+		Short: "Reports computed from stored trades and candles",
+	}
+	cmd.AddCommand(newReportPnLCmd())
+	return cmd
+}
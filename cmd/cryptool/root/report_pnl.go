@@ -0,0 +1,275 @@
+package root
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/spf13/cobra"
+
+	"cryptool/internal/accounting"
+	"cryptool/internal/coinbase"
+	"cryptool/internal/config"
+	"cryptool/internal/ingest"
+)
+
+// pnlReport is the output of 'report pnl'.
+type pnlReport struct {
+	Method    accounting.Method        `json:"method"`
+	Quote     string                   `json:"quote"`
+	Year      int                      `json:"year,omitempty"`
+	AsOf      time.Time                `json:"as_of"`
+	Summary   []accounting.YearSummary `json:"summary"`
+	Disposals []accounting.Disposal    `json:"disposals"`
+	Positions []accounting.Position    `json:"positions"`
+	Skipped   map[string]int           `json:"skipped_fills,omitempty"` // by the currency that had no price
+}
+
+func newReportPnLCmd() *cobra.Command {
+	var (
+		method string
+		year   int
+		quote  string
+		format string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "pnl",
+		Short: "Realized and unrealized P&L from stored fills",
+		Long: `Builds tax lots per asset from the fills table (see 'exchange coinbase fills sync') and
+reports realized gains for every disposal plus unrealized gains on the remaining lots.
+
+--method picks the lots a sale consumes: fifo, lifo, hifo (highest cost first) or average.
+Buy fees are added to the cost basis and sell fees are deducted from the proceeds.
+
+With --year the report lists the disposals of that calendar year (UTC) and marks open positions at
+the end of that year; without it every disposal is listed and positions are marked now. Prices come
+from the latest stored <ASSET>-<QUOTE> candle close.
+
+A fill quoted in another currency, e.g. ETH-BTC with --quote USD, is a disposal of one asset and an
+acquisition of the other. Both are valued at the last stored BTC-USD close at the trade time, and
+the fee is deducted once, from the disposal's proceeds. Fills without such a close are skipped with
+a warning per currency.
+
+--format csv writes one row per disposal, suitable for tax software.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg := config.FromContext(cmd.Context())
+			ctx := cmd.Context()
+			m, err := accounting.ParseMethod(method)
+			if err != nil {
+				return err
+			}
+			switch format {
+			case "table", "json", "csv":
+			default:
+				return fmt.Errorf("unsupported format %q, expected table, json or csv", format)
+			}
+			quote = strings.ToUpper(quote)
+
+			asOf := time.Now().UTC()
+			if year != 0 {
+				if end := time.Date(year+1, 1, 1, 0, 0, 0, 0, time.UTC).Add(-time.Nanosecond); end.Before(asOf) {
+					asOf = end
+				}
+			}
+
+			store, err := openStore(cfg)
+			if err != nil {
+				return err
+			}
+			defer store.Close()
+			fills, err := store.GetFills(ctx, "coinbase", asOf)
+			if err != nil {
+				return fmt.Errorf("failed to load fills: %w", err)
+			}
+
+			ledger := accounting.NewLedger(m)
+			skipped := make(map[string]int)
+			rate := quoteRate(ctx, store, quote)
+			for _, f := range fills {
+				trades, missing, err := tradesFromFill(f, quote, rate)
+				if err != nil {
+					return err
+				}
+				if missing != "" {
+					skipped[missing]++
+					continue
+				}
+				for _, t := range trades {
+					if err := ledger.Apply(t); err != nil {
+						return err
+					}
+				}
+			}
+			currencies := make([]string, 0, len(skipped))
+			for c := range skipped {
+				currencies = append(currencies, c)
+			}
+			sort.Strings(currencies)
+			for _, c := range currencies {
+				fmt.Fprintf(os.Stderr, "Skipped %d fills quoted in %s: no stored %s-%s candle at their trade time.\n", skipped[c], c, c, quote)
+			}
+
+			positions, err := ledger.Positions(func(asset string) (decimal.Decimal, bool, error) {
+				p, _, ok, err := store.GetLatestClose(ctx, "coinbase", asset+"-"+quote, asOf)
+				return p, ok, err
+			})
+			if err != nil {
+				return fmt.Errorf("failed to price positions: %w", err)
+			}
+
+			disposals := ledger.Disposals()
+			if year != 0 {
+				disposals = accounting.DisposalsInYear(disposals, year)
+			}
+			return writePnLReport(os.Stdout, format, pnlReport{
+				Method:    m,
+				Quote:     quote,
+				Year:      year,
+				AsOf:      asOf,
+				Summary:   accounting.SummarizeByYear(disposals),
+				Disposals: disposals,
+				Positions: positions,
+				Skipped:   skipped,
+			})
+		},
+	}
+	cmd.Flags().StringVar(&method, "method", "fifo", "cost-basis method: fifo, lifo, hifo or average")
+	cmd.Flags().IntVar(&year, "year", 0, "report disposals of this calendar year only")
+	cmd.Flags().StringVar(&quote, "quote", "USD", "reporting currency; fills in other quote currencies are converted at stored candle closes")
+	cmd.Flags().StringVar(&format, "format", "table", "output format: table, json or csv")
+	return cmd
+}
+
+// rateFunc returns the value of one unit of currency in the report's quote at a time.
+// ok is false when no price is known.
+type rateFunc func(currency string, at time.Time) (price decimal.Decimal, ok bool, err error)
+
+// quoteRate prices currencies at the last stored <CURRENCY>-<QUOTE> close at or before the given time.
+func quoteRate(ctx context.Context, store closeStore, quote string) rateFunc {
+	return func(currency string, at time.Time) (decimal.Decimal, bool, error) {
+		p, _, ok, err := store.GetLatestClose(ctx, "coinbase", currency+"-"+quote, at)
+		return p, ok, err
+	}
+}
+
+// tradesFromFill converts a stored fill into accounting trades priced in quote.
+//
+// A fill of <ASSET>-<QUOTE> is a single trade. Any other pair swaps one asset for another: a buy
+// of ETH-BTC disposes of BTC and acquires ETH, a sell does the reverse. Both legs are valued with
+// rate of the pair's quote currency at the trade time, and the fee is deducted once, from the
+// disposal's proceeds, so the acquired lot's cost basis equals what the disposal realized. Legs in
+// quote itself are not assets and are left out. missing names the currency rate had no price for;
+// the fill then yields no trades.
+func tradesFromFill(f ingest.StoredFill, quote string, rate rateFunc) (trades []accounting.Trade, missing string, err error) {
+	parts := strings.SplitN(f.ProductID, "-", 2)
+	if len(parts) != 2 || !f.Price.IsPositive() {
+		return nil, "", fmt.Errorf("fill %s: cannot interpret product %q at price %s", f.EntryID, f.ProductID, f.Price)
+	}
+	base, counter := parts[0], parts[1]
+	buy := f.Side == coinbase.SideBuy
+	size := f.Size
+	if f.SizeInQuote {
+		size = f.Size.Div(f.Price)
+	}
+	trade := func(asset string, buy bool, size, price, fee decimal.Decimal) accounting.Trade {
+		return accounting.Trade{ID: f.EntryID, Time: f.TradeTime, Asset: asset, Quote: quote, Buy: buy, Size: size, Price: price, Fee: fee}
+	}
+	if counter == quote {
+		return []accounting.Trade{trade(base, buy, size, f.Price, f.Commission)}, "", nil
+	}
+
+	// r is the value of one counter unit in quote.
+	var r decimal.Decimal
+	if base == quote {
+		r = decimal.NewFromInt(1).Div(f.Price)
+	} else {
+		var ok bool
+		if r, ok, err = rate(counter, f.TradeTime); err != nil {
+			return nil, "", fmt.Errorf("fill %s: price %s in %s: %w", f.EntryID, counter, quote, err)
+		}
+		if !ok || !r.IsPositive() {
+			return nil, counter, nil
+		}
+	}
+	funds := size.Mul(f.Price) // in counter
+	fee := f.Commission.Mul(r)
+	if buy {
+		if base != quote {
+			trades = append(trades, trade(base, true, size, f.Price.Mul(r), decimal.Zero))
+		}
+		return append(trades, trade(counter, false, funds.Add(f.Commission), r, fee)), "", nil
+	}
+	if base != quote {
+		trades = append(trades, trade(base, false, size, f.Price.Mul(r), fee))
+	}
+	if net := funds.Sub(f.Commission); net.IsPositive() {
+		trades = append(trades, trade(counter, true, net, r, decimal.Zero))
+	}
+	return trades, "", nil
+}
+
+func writePnLReport(out io.Writer, format string, r pnlReport) error {
+	f := func(v decimal.Decimal) string { return v.String() }
+	c := func(v decimal.Decimal) string { return v.StringFixed(2) }
+	date := func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.UTC().Format(time.RFC3339)
+	}
+	term := func(d accounting.Disposal) string {
+		switch {
+		case d.Unmatched:
+			return "unmatched"
+		case d.LongTerm:
+			return "long"
+		default:
+			return "short"
+		}
+	}
+
+	switch format {
+	case "json":
+		return printJSON(out, r)
+	case "csv":
+		w := csv.NewWriter(out)
+		w.Write([]string{"asset", "size", "acquired", "disposed", "proceeds", "cost_basis", "gain", "term", "trade_id", "lot_trade_id"})
+		for _, d := range r.Disposals {
+			w.Write([]string{d.Asset, f(d.Size), date(d.Acquired), date(d.Disposed), f(d.Proceeds), f(d.CostBasis), f(d.Gain), term(d), d.TradeID, d.LotTradeID})
+		}
+		w.Flush()
+		return w.Error()
+	default:
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(w, "Disposals (%s, %s)\n", r.Method, r.Quote)
+		fmt.Fprintln(w, "Asset\tSize\tAcquired\tDisposed\tProceeds\tCost Basis\tGain\tTerm")
+		for _, d := range r.Disposals {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", d.Asset, f(d.Size), date(d.Acquired), date(d.Disposed),
+				c(d.Proceeds), c(d.CostBasis), c(d.Gain), term(d))
+		}
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "Year\tDisposals\tProceeds\tCost Basis\tGain\tShort Term\tLong Term")
+		for _, s := range r.Summary {
+			fmt.Fprintf(w, "%d\t%d\t%s\t%s\t%s\t%s\t%s\n", s.Year, s.Disposals, c(s.Proceeds), c(s.CostBasis), c(s.Gain), c(s.ShortTermGain), c(s.LongTermGain))
+		}
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "Open positions as of %s\n", date(r.AsOf))
+		fmt.Fprintln(w, "Asset\tSize\tCost Basis\tPrice\tValue\tUnrealized")
+		for _, p := range r.Positions {
+			if !p.Priced {
+				fmt.Fprintf(w, "%s\t%s\t%s\t-\t-\tno price\n", p.Asset, f(p.Size), c(p.CostBasis))
+				continue
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", p.Asset, f(p.Size), c(p.CostBasis), f(p.MarketPrice), c(p.MarketValue), c(p.Unrealized))
+		}
+		return w.Flush()
+	}
+}
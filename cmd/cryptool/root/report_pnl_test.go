@@ -0,0 +1,81 @@
+package root
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"cryptool/internal/accounting"
+	"cryptool/internal/coinbase"
+	"cryptool/internal/ingest"
+)
+
+func TestTradesFromFillCryptoToCrypto(t *testing.T) {
+	t0 := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := closes{
+		"BTC-USD": {"50000", t0},
+		"ETH-USD": {"3000", t0},
+	}
+	rate := quoteRate(context.Background(), store, "USD")
+	fill := func(id, product, side, price, size, fee string, at time.Time) ingest.StoredFill {
+		return ingest.StoredFill{EntryID: id, ProductID: product, Side: side, TradeTime: at,
+			Price: dec(price), Size: dec(size), Commission: dec(fee)}
+	}
+	fills := []ingest.StoredFill{
+		fill("1", "BTC-USD", coinbase.SideBuy, "40000", "1", "0", t0),
+		// 0.5 BTC buys 10 ETH plus 0.01 BTC in fees: BTC worth 25500 USD is disposed of.
+		fill("2", "ETH-BTC", coinbase.SideBuy, "0.05", "10", "0.01", t0.Add(time.Hour)),
+		// 10 ETH sell for 0.6 BTC less a 0.02 BTC fee.
+		fill("3", "ETH-BTC", coinbase.SideSell, "0.06", "10", "0.02", t0.Add(2*time.Hour)),
+	}
+
+	ledger := accounting.NewLedger(accounting.FIFO)
+	for _, f := range fills {
+		trades, missing, err := tradesFromFill(f, "USD", rate)
+		if err != nil || missing != "" {
+			t.Fatalf("fill %s: missing %q, err %v", f.EntryID, missing, err)
+		}
+		for _, tr := range trades {
+			if err := ledger.Apply(tr); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	want := []struct{ asset, size, proceeds, basis string }{
+		{"BTC", "0.51", "25000", "20400"}, // 0.51 BTC at cost 40000, fee deducted from proceeds
+		{"ETH", "10", "29000", "25000"},   // 0.6 BTC less 0.02 BTC fee at 50000
+	}
+	got := ledger.Disposals()
+	if len(got) != len(want) {
+		t.Fatalf("got %d disposals, want %d: %+v", len(got), len(want), got)
+	}
+	for i, w := range want {
+		d := got[i]
+		if d.Asset != w.asset || !d.Size.Equal(dec(w.size)) || !d.Proceeds.Equal(dec(w.proceeds)) || !d.CostBasis.Equal(dec(w.basis)) {
+			t.Errorf("disposal %d = %s %s proceeds %s basis %s, want %+v", i, d.Asset, d.Size, d.Proceeds, d.CostBasis, w)
+		}
+	}
+
+	positions, err := ledger.Positions(func(string) (decimal.Decimal, bool, error) { return decimal.Zero, false, nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(positions) != 1 || positions[0].Asset != "BTC" || !positions[0].Size.Equal(dec("1.07")) {
+		t.Errorf("positions = %+v, want 1.07 BTC", positions)
+	}
+}
+
+func TestTradesFromFillMissingRate(t *testing.T) {
+	rate := quoteRate(context.Background(), closes{}, "USD")
+	f := ingest.StoredFill{EntryID: "1", ProductID: "ETH-BTC", Side: coinbase.SideBuy, TradeTime: time.Now(),
+		Price: dec("0.05"), Size: dec("1")}
+	trades, missing, err := tradesFromFill(f, "USD", rate)
+	if err != nil || missing != "BTC" || len(trades) != 0 {
+		t.Errorf("got %v, %q, %v; want no trades and BTC missing", trades, missing, err)
+	}
+}
+
+func dec(s string) decimal.Decimal { return decimal.RequireFromString(s) }
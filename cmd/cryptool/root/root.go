@@ -17,6 +17,8 @@ var (
 	appCfg       *config.Config
 	verbose      bool
 	coinbaseCreds string
+	recordDir     string
+	replayDir     string
 )
 
 var rootCmd = &cobra.Command{
@@ -30,6 +32,8 @@ var rootCmd = &cobra.Command{
 		}
 		appCfg = c
 		appCfg.App.Verbose = verbose
+		appCfg.Coinbase.RecordDir = recordDir
+		appCfg.Coinbase.ReplayDir = replayDir
 		// Pass down via context
 		ctx := config.WithConfig(cmd.Context(), appCfg)
 		cmd.SetContext(ctx)
@@ -41,6 +45,8 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&cfgPath, "config", "", "path to config file (default: reads .env from current directory, then uses CRYPTO_CONFIG_FILE variable)")
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "enable verbose output")
 	rootCmd.PersistentFlags().StringVar(&coinbaseCreds, "coinbase-creds", "", "path to coinbase credentials json file")
+	rootCmd.PersistentFlags().StringVar(&recordDir, "record", "", "record every Coinbase API request and response to cassette files in this directory")
+	rootCmd.PersistentFlags().StringVar(&replayDir, "replay", "", "answer Coinbase API requests from the cassette files in this directory instead of the network")
 }
 
 func Execute(migrationsFS embed.FS) error {
@@ -50,6 +56,8 @@ func Execute(migrationsFS embed.FS) error {
 	rootCmd.AddCommand(NewClientCmd())
 	rootCmd.AddCommand(NewServerCmd())
 	rootCmd.AddCommand(NewJobsCmd())
+	rootCmd.AddCommand(NewReportCmd())
+	rootCmd.AddCommand(NewDevCmd())
 	return rootCmd.Execute()
 }
 
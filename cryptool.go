@@ -1,13 +1,19 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
@@ -18,6 +24,9 @@ import (
 
 	"cryptool/cmd/cryptool/root"
 	"cryptool/internal/config"
+	"cryptool/internal/db"
+	"cryptool/internal/ingest"
+	"cryptool/internal/migrate"
 )
 
 //go:embed migrations/*.sql
@@ -55,6 +64,7 @@ type Daemon struct {
 	ctx         context.Context
 	cancel      context.CancelFunc
 	config      *config.Config
+	store       *ingest.Store // nil when no database is configured
 	// Job tracking
 	jobs      map[string]*Job
 	jobsMutex sync.RWMutex
@@ -68,13 +78,16 @@ type Job struct {
 	StartedAt time.Time `json:"started_at"`
 	Status    string    `json:"status"` // running, stopping, done, error
 	Error     string    `json:"error,omitempty"`
-	cancel    context.CancelFunc
+	// Output is what the job wrote to its output, e.g. the balances of wallet:syncdown. It is
+	// set when the job ends.
+	Output string `json:"output,omitempty"`
+	cancel context.CancelFunc
 }
 
 // NewDaemon creates a new daemon instance
-func NewDaemon(port string, cfg *config.Config) *Daemon {
+func NewDaemon(port string, cfg *config.Config) (*Daemon, error) {
 	ctx, cancel := context.WithCancel(context.Background())
-	return &Daemon{
+	d := &Daemon{
 		port:        port,
 		connections: make(map[*Connection]bool),
 		commandChan: make(chan Command, 100),
@@ -83,6 +96,15 @@ func NewDaemon(port string, cfg *config.Config) *Daemon {
 		config:      cfg,
 		jobs:        make(map[string]*Job),
 	}
+	if cfg.Database.URL != "" {
+		store, err := ingest.NewStore(cfg.Database.URL, db.PoolFromConfig(cfg))
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		d.store = store
+	}
+	return d, nil
 }
 
 // Start starts the daemon server
@@ -123,6 +145,9 @@ func (d *Daemon) Stop() {
 	for conn := range d.connections {
 		conn.conn.Close()
 	}
+	if d.store != nil {
+		d.store.Close()
+	}
 }
 
 // handleWebSocket handles websocket connections
@@ -150,31 +175,57 @@ func (d *Daemon) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	wsConn.reader()
 }
 
-// handleHealth provides health check endpoint
+// handleHealth provides health check endpoint. It reports 503 when the database is
+// configured but does not answer a ping.
 func (d *Daemon) handleHealth(w http.ResponseWriter, r *http.Request) {
+	status, code := "healthy", http.StatusOK
+	database := d.databaseHealth(r.Context())
+	if database["status"] == "error" {
+		status, code = "degraded", http.StatusServiceUnavailable
+	}
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
+	w.WriteHeader(code)
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"status":      "healthy",
+		"status":      status,
 		"timestamp":   time.Now().Format(time.RFC3339),
 		"connections": len(d.connections),
+		"database":    database,
 	})
 }
 
+// databaseHealth pings the store and reports its pool statistics
+func (d *Daemon) databaseHealth(ctx context.Context) map[string]interface{} {
+	if d.store == nil {
+		return map[string]interface{}{"status": "not configured"}
+	}
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	start := time.Now()
+	err := d.store.Ping(ctx)
+	stats := d.store.Stats()
+	health := map[string]interface{}{
+		"status":           "ok",
+		"latency_ms":       time.Since(start).Milliseconds(),
+		"open_connections": stats.OpenConnections,
+		"in_use":           stats.InUse,
+		"idle":             stats.Idle,
+		"wait_count":       stats.WaitCount,
+	}
+	if err != nil {
+		health["status"] = "error"
+		health["error"] = err.Error()
+	}
+	return health
+}
+
 // handleStatus returns daemon status and active jobs
 func (d *Daemon) handleStatus(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	d.jobsMutex.RLock()
-	jobs := make([]*Job, 0, len(d.jobs))
-	for _, j := range d.jobs {
-		jobs = append(jobs, &Job{ID: j.ID, Command: j.Command, Args: j.Args, StartedAt: j.StartedAt, Status: j.Status, Error: j.Error})
-	}
-	d.jobsMutex.RUnlock()
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"status":      "ok",
 		"timestamp":   time.Now().Format(time.RFC3339),
 		"connections": len(d.connections),
-		"jobs":        jobs,
+		"jobs":        d.listJobs(),
 	})
 }
 
@@ -185,24 +236,238 @@ func (d *Daemon) handleJobsKill(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "missing id", http.StatusBadRequest)
 		return
 	}
-	d.jobsMutex.RLock()
-	j, ok := d.jobs[id]
-	d.jobsMutex.RUnlock()
+	j, ok := d.killJob(id)
 	if !ok {
 		http.Error(w, "job not found", http.StatusNotFound)
 		return
 	}
-	if j.cancel != nil {
-		j.Status = "stopping"
-		j.cancel()
-	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"status": "stopping",
+		"status": j.Status,
 		"id":     id,
 	})
 }
 
+// listJobs returns a copy of all tracked jobs, safe to serialize
+func (d *Daemon) listJobs() []*Job {
+	d.jobsMutex.RLock()
+	defer d.jobsMutex.RUnlock()
+	jobs := make([]*Job, 0, len(d.jobs))
+	for _, j := range d.jobs {
+		jobs = append(jobs, &Job{ID: j.ID, Command: j.Command, Args: j.Args, StartedAt: j.StartedAt, Status: j.Status, Error: j.Error, Output: j.Output})
+	}
+	return jobs
+}
+
+// killJob cancels a running job and marks it as stopping. It returns a copy of the job.
+func (d *Daemon) killJob(id string) (*Job, bool) {
+	d.jobsMutex.Lock()
+	defer d.jobsMutex.Unlock()
+	j, ok := d.jobs[id]
+	if !ok {
+		return nil, false
+	}
+	if j.Status == "running" && j.cancel != nil {
+		j.Status = "stopping"
+		j.cancel()
+	}
+	return &Job{ID: j.ID, Command: j.Command, Args: j.Args, StartedAt: j.StartedAt, Status: j.Status, Error: j.Error, Output: j.Output}, true
+}
+
+// jobFunc is the body of a background job. Anything it writes to out becomes the job's Output.
+type jobFunc func(ctx context.Context, out io.Writer) error
+
+// startJob registers a job and runs fn in the background with a cancellable context.
+// The job moves from running (or stopping, once killed) to done or error when fn returns.
+// A killed job that returns context.Canceled is done, not failed.
+func (d *Daemon) startJob(command string, args []string, fn jobFunc) *Job {
+	ctx, cancel := context.WithCancel(d.ctx)
+	job := &Job{
+		ID:        newJobID(),
+		Command:   command,
+		Args:      args,
+		StartedAt: time.Now(),
+		Status:    "running",
+		cancel:    cancel,
+	}
+
+	d.jobsMutex.Lock()
+	d.jobs[job.ID] = job
+	d.jobsMutex.Unlock()
+
+	go func() {
+		defer cancel()
+		log.Printf("Job %s started: %s %v", job.ID, command, args)
+
+		var out bytes.Buffer
+		err := func() (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					err = fmt.Errorf("panic: %v", r)
+				}
+			}()
+			return fn(ctx, &out)
+		}()
+
+		d.jobsMutex.Lock()
+		if job.Status == "stopping" && errors.Is(err, context.Canceled) {
+			err = nil
+		}
+		job.Output = out.String()
+		if err != nil {
+			job.Status = "error"
+			job.Error = err.Error()
+		} else {
+			job.Status = "done"
+		}
+		d.jobsMutex.Unlock()
+
+		if err != nil {
+			log.Printf("Job %s failed: %v", job.ID, err)
+		} else {
+			log.Printf("Job %s finished", job.ID)
+		}
+	}()
+
+	return job
+}
+
+// newJobID returns a short random hex identifier for a job
+func newJobID() string {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// dataString reads an optional string field from a command's data payload
+func dataString(data map[string]interface{}, key string) string {
+	if v, ok := data[key]; ok {
+		if s, ok := v.(string); ok {
+			return s
+		}
+	}
+	return ""
+}
+
+// dataExchange returns the exchange a data command targets: coinbase for coinbase:* commands,
+// else the "exchange" field, defaulting to coinbase.
+func dataExchange(cmd Command) string {
+	if strings.HasPrefix(cmd.Command, "coinbase:") {
+		return "coinbase"
+	}
+	if name := dataString(cmd.Data, "exchange"); name != "" {
+		return name
+	}
+	return "coinbase"
+}
+
+// dataInt reads an optional numeric field from a command's data payload
+func dataInt(data map[string]interface{}, key string, def int) int {
+	if v, ok := data[key]; ok {
+		if f, ok := v.(float64); ok {
+			return int(f)
+		}
+	}
+	return def
+}
+
+// jobForCommand maps a websocket command to the job body that implements it.
+// It returns ok=false for commands that are not background jobs.
+func (d *Daemon) jobForCommand(cmd Command) (fn jobFunc, args []string, ok bool, err error) {
+	cfg := d.config
+	switch cmd.Command {
+	case "coinbase:fetch", "data:fetch":
+		opts := root.FetchOptions{
+			Exchange:    dataExchange(cmd),
+			Product:     dataString(cmd.Data, "product"),
+			Granularity: dataString(cmd.Data, "granularity"),
+		}
+		if opts.Product == "" {
+			return nil, nil, true, fmt.Errorf("missing product")
+		}
+		if s := dataString(cmd.Data, "start"); s != "" {
+			if opts.Start, err = root.ParseDate(s); err != nil {
+				return nil, nil, true, fmt.Errorf("invalid start: %w", err)
+			}
+		}
+		if s := dataString(cmd.Data, "end"); s != "" {
+			if opts.End, err = root.ParseDate(s); err != nil {
+				return nil, nil, true, fmt.Errorf("invalid end: %w", err)
+			}
+		}
+		args = []string{"exchange=" + opts.Exchange, "product=" + opts.Product}
+		if opts.Granularity != "" {
+			args = append(args, "granularity="+opts.Granularity)
+		}
+		return func(ctx context.Context, out io.Writer) error { return root.RunFetch(ctx, cfg, opts) }, args, true, nil
+
+	case "coinbase:history", "data:history":
+		name := dataExchange(cmd)
+		return func(ctx context.Context, out io.Writer) error { return root.RunHistory(ctx, cfg, name) }, []string{"exchange=" + name}, true, nil
+
+	case "coinbase:sync-products", "data:sync-products":
+		name := dataExchange(cmd)
+		return func(ctx context.Context, out io.Writer) error { return root.RunProductsSync(ctx, cfg, name) }, []string{"exchange=" + name}, true, nil
+
+	case "coinbase:rollup", "data:rollup":
+		opts := root.RollupOptions{
+			Exchange:      dataExchange(cmd),
+			Product:       dataString(cmd.Data, "product"),
+			Granularities: strings.Split(dataString(cmd.Data, "granularity"), ","),
+		}
+		if opts.Granularities[0] == "" {
+			opts.Granularities = []string{"5m", "15m", "1h", "1d"}
+		}
+		opts.Full, _ = cmd.Data["full"].(bool)
+		opts.View, _ = cmd.Data["view"].(bool)
+		args = []string{"exchange=" + opts.Exchange, "granularity=" + strings.Join(opts.Granularities, ",")}
+		if opts.Product != "" {
+			args = append(args, "product="+opts.Product)
+		}
+		return func(ctx context.Context, out io.Writer) error { return root.RunRollup(ctx, cfg, opts) }, args, true, nil
+
+	case "coinbase:stream":
+		product := dataString(cmd.Data, "product")
+		if product == "" {
+			return nil, nil, true, fmt.Errorf("missing product")
+		}
+		opts := root.StreamOptions{Products: strings.Split(product, ",")}
+		return func(ctx context.Context, out io.Writer) error { return root.RunCoinbaseStream(ctx, cfg, opts) }, []string{"product=" + product}, true, nil
+
+	case "wallet:syncdown":
+		// JSON by default, so clients can parse the balances from the job output.
+		opts := root.WalletSyncDownOptions{Format: dataString(cmd.Data, "format")}
+		if opts.Format == "" {
+			opts.Format = "json"
+		}
+		if v, ok := cmd.Data["persist"].(bool); ok {
+			opts.Persist = v
+		}
+		if v, ok := cmd.Data["no_snapshot"].(bool); ok {
+			opts.NoSnapshot = v
+		}
+		args = []string{fmt.Sprintf("persist=%t", opts.Persist)}
+		return func(ctx context.Context, out io.Writer) error { return root.RunCoinbaseWalletSyncDown(ctx, cfg, out, opts) }, args, true, nil
+
+	case "migrate:status":
+		return func(ctx context.Context, out io.Writer) error { return migrate.Status(ctx, out, cfg.Database.URL, migrationsFS) }, nil, true, nil
+
+	case "migrate:up":
+		return func(ctx context.Context, out io.Writer) error { return migrate.Up(ctx, out, cfg.Database.URL, migrationsFS) }, nil, true, nil
+
+	case "migrate:down":
+		steps := dataInt(cmd.Data, "step", 1)
+		args = []string{fmt.Sprintf("step=%d", steps)}
+		return func(ctx context.Context, out io.Writer) error { return migrate.Down(ctx, out, cfg.Database.URL, steps, migrationsFS) }, args, true, nil
+
+	case "migrate:reset":
+		return func(ctx context.Context, out io.Writer) error { return migrate.Reset(ctx, out, cfg.Database.URL, migrationsFS) }, nil, true, nil
+	}
+	return nil, nil, false, nil
+}
+
 // reader handles incoming messages
 func (c *Connection) reader() {
 	defer func() {
@@ -328,60 +593,50 @@ func (d *Daemon) executeCommand(cmd Command) Response {
 		Success: true,
 	}
 
+	if fn, args, ok, err := d.jobForCommand(cmd); ok {
+		if err != nil {
+			response.Success = false
+			response.Error = err.Error()
+			return response
+		}
+		if len(args) == 0 {
+			args = cmd.Args
+		}
+		job := d.startJob(cmd.Command, args, fn)
+		response.Message = fmt.Sprintf("Started job %s", job.ID)
+		response.Data = map[string]interface{}{
+			"job_id": job.ID,
+			"status": "running",
+		}
+		return response
+	}
+
 	switch cmd.Command {
 	case "server:status":
 		// Return lightweight status and jobs list
-		d.jobsMutex.RLock()
-		jobs := make([]*Job, 0, len(d.jobs))
-		for _, j := range d.jobs {
-			jobs = append(jobs, &Job{ID: j.ID, Command: j.Command, Args: j.Args, StartedAt: j.StartedAt, Status: j.Status, Error: j.Error})
-		}
-		d.jobsMutex.RUnlock()
 		response.Message = "Server status"
 		response.Data = map[string]interface{}{
 			"connections": len(d.connections),
-			"jobs":        jobs,
+			"jobs":        d.listJobs(),
 		}
 
 	case "jobs:kill":
-		id := ""
-		if v, ok := cmd.Data["id"]; ok {
-			if s, ok := v.(string); ok { id = s }
-		}
+		id := dataString(cmd.Data, "id")
 		if id == "" {
 			response.Success = false
 			response.Error = "missing job id"
 			break
 		}
-		d.jobsMutex.RLock()
-		j, ok := d.jobs[id]
-		d.jobsMutex.RUnlock()
+		j, ok := d.killJob(id)
 		if !ok {
 			response.Success = false
 			response.Error = "job not found"
 			break
 		}
-		if j.cancel != nil { j.Status = "stopping"; j.cancel() }
 		response.Message = fmt.Sprintf("Stopping job %s", id)
-
-	case "migrate:status":
-		response.Message = "Migration status checked"
-		response.Data = map[string]interface{}{
-			"status": "completed",
-		}
-
-	case "coinbase:fetch":
-		product := ""
-		if productInterface, ok := cmd.Data["product"]; ok {
-			if productStr, ok := productInterface.(string); ok {
-				product = productStr
-			}
-		}
-
-		response.Message = fmt.Sprintf("Fetching coinbase data for product: %s", product)
 		response.Data = map[string]interface{}{
-			"product": product,
-			"status":  "fetching",
+			"id":     id,
+			"status": j.Status,
 		}
 
 	case "health":
@@ -414,7 +669,10 @@ func startDaemon(port string) error {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
 
-	daemon := NewDaemon(port, cfg)
+	daemon, err := NewDaemon(port, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create daemon: %w", err)
+	}
 	return daemon.Start()
 }
 
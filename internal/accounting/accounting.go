@@ -0,0 +1,318 @@
+// Package accounting builds tax lots from executed trades and computes realized and unrealized P&L.
+package accounting
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Method selects which lots a disposal consumes.
+type Method string
+
+const (
+	FIFO    Method = "fifo"    // oldest lots first
+	LIFO    Method = "lifo"    // newest lots first
+	HIFO    Method = "hifo"    // highest unit cost first
+	Average Method = "average" // all lots pooled at their weighted average cost
+)
+
+// ParseMethod validates a user supplied cost-basis method.
+func ParseMethod(s string) (Method, error) {
+	switch m := Method(strings.ToLower(s)); m {
+	case FIFO, LIFO, HIFO, Average:
+		return m, nil
+	case "avg":
+		return Average, nil
+	default:
+		return "", fmt.Errorf("unknown cost-basis method %q, expected fifo, lifo, hifo or average", s)
+	}
+}
+
+// Trade is an executed buy or sell of Asset priced in Quote. Amounts are exact decimals, so
+// lots are consumed exactly and totals match what the exchange reported.
+type Trade struct {
+	ID    string
+	Time  time.Time
+	Asset string
+	Quote string
+	Buy   bool
+	Size  decimal.Decimal // in asset units
+	Price decimal.Decimal // quote per asset unit
+	Fee   decimal.Decimal // in quote
+}
+
+// Lot is a remaining quantity of an asset acquired at a known unit cost.
+type Lot struct {
+	Asset    string          `json:"asset"`
+	TradeID  string          `json:"trade_id"`
+	Acquired time.Time       `json:"acquired"`
+	Size     decimal.Decimal `json:"size"`
+	UnitCost decimal.Decimal `json:"unit_cost"` // includes the buy fee
+
+	cost decimal.Decimal // total cost of Size; partial disposals take their share of it
+}
+
+// Disposal is the realized result of selling (part of) one lot.
+type Disposal struct {
+	Asset      string          `json:"asset"`
+	TradeID    string          `json:"trade_id"`
+	LotTradeID string          `json:"lot_trade_id,omitempty"`
+	Acquired   time.Time       `json:"acquired"`
+	Disposed   time.Time       `json:"disposed"`
+	Size       decimal.Decimal `json:"size"`
+	Proceeds   decimal.Decimal `json:"proceeds"`   // net of the sell fee
+	CostBasis  decimal.Decimal `json:"cost_basis"` // includes the buy fee
+	Gain       decimal.Decimal `json:"gain"`
+	LongTerm   bool            `json:"long_term"` // held for more than one year
+	Unmatched  bool            `json:"unmatched"` // sold more than was acquired; cost basis is zero
+}
+
+// Ledger applies trades in time order and tracks open lots per asset.
+type Ledger struct {
+	method    Method
+	lots      map[string][]*Lot
+	disposals []Disposal
+}
+
+// NewLedger returns an empty ledger using method for lot selection.
+func NewLedger(method Method) *Ledger {
+	return &Ledger{method: method, lots: make(map[string][]*Lot)}
+}
+
+// Apply records a trade. Trades must be applied in chronological order.
+func (l *Ledger) Apply(t Trade) error {
+	if !t.Size.IsPositive() {
+		return fmt.Errorf("trade %s: size must be positive", t.ID)
+	}
+	if t.Buy {
+		l.acquire(t)
+		return nil
+	}
+	l.dispose(t)
+	return nil
+}
+
+func (l *Ledger) acquire(t Trade) {
+	cost := t.Size.Mul(t.Price).Add(t.Fee)
+	lot := &Lot{
+		Asset:    t.Asset,
+		TradeID:  t.ID,
+		Acquired: t.Time,
+		Size:     t.Size,
+		UnitCost: cost.Div(t.Size),
+		cost:     cost,
+	}
+	if l.method == Average {
+		if pool := l.lots[t.Asset]; len(pool) == 1 {
+			p := pool[0]
+			p.Size = p.Size.Add(lot.Size)
+			p.cost = p.cost.Add(lot.cost)
+			p.UnitCost = p.cost.Div(p.Size)
+			return
+		}
+	}
+	l.lots[t.Asset] = append(l.lots[t.Asset], lot)
+}
+
+func (l *Ledger) dispose(t Trade) {
+	remaining := t.Size
+	// Net proceeds, spreading the sell fee across the whole trade. Each part takes its share
+	// and the last part takes what is left, so the parts add up to the trade exactly.
+	net := t.Size.Mul(t.Price).Sub(t.Fee)
+	unallocated := net
+
+	for remaining.IsPositive() {
+		idx := l.pick(t.Asset)
+		if idx < 0 {
+			proceeds := unallocated
+			l.disposals = append(l.disposals, Disposal{
+				Asset:     t.Asset,
+				TradeID:   t.ID,
+				Disposed:  t.Time,
+				Size:      remaining,
+				Proceeds:  proceeds,
+				Gain:      proceeds,
+				Unmatched: true,
+			})
+			return
+		}
+		lot := l.lots[t.Asset][idx]
+		size := decimal.Min(remaining, lot.Size)
+		proceeds := unallocated
+		if size.LessThan(remaining) {
+			proceeds = share(net, size, t.Size)
+		}
+		cost := lot.cost
+		if size.LessThan(lot.Size) {
+			cost = share(lot.cost, size, lot.Size)
+		}
+		l.disposals = append(l.disposals, Disposal{
+			Asset:      t.Asset,
+			TradeID:    t.ID,
+			LotTradeID: lot.TradeID,
+			Acquired:   lot.Acquired,
+			Disposed:   t.Time,
+			Size:       size,
+			Proceeds:   proceeds,
+			CostBasis:  cost,
+			Gain:       proceeds.Sub(cost),
+			LongTerm:   t.Time.After(lot.Acquired.AddDate(1, 0, 0)),
+		})
+		lot.Size = lot.Size.Sub(size)
+		lot.cost = lot.cost.Sub(cost)
+		remaining = remaining.Sub(size)
+		unallocated = unallocated.Sub(proceeds)
+		if !lot.Size.IsPositive() {
+			lots := l.lots[t.Asset]
+			l.lots[t.Asset] = append(lots[:idx], lots[idx+1:]...)
+		}
+	}
+}
+
+// share returns the part of total attributable to size out of whole.
+func share(total, size, whole decimal.Decimal) decimal.Decimal {
+	return total.Mul(size).Div(whole)
+}
+
+// pick returns the index of the next lot to consume for asset, or -1 when none are left.
+func (l *Ledger) pick(asset string) int {
+	lots := l.lots[asset]
+	if len(lots) == 0 {
+		return -1
+	}
+	switch l.method {
+	case LIFO:
+		return len(lots) - 1
+	case HIFO:
+		best := 0
+		for i, lot := range lots {
+			if lot.UnitCost.GreaterThan(lots[best].UnitCost) {
+				best = i
+			}
+		}
+		return best
+	default: // FIFO, and Average keeps a single pooled lot
+		return 0
+	}
+}
+
+// Disposals returns all realized disposals in the order they occurred.
+func (l *Ledger) Disposals() []Disposal {
+	return append([]Disposal(nil), l.disposals...)
+}
+
+// Lots returns copies of the open lots of every asset, sorted by asset then acquisition time.
+func (l *Ledger) Lots() []Lot {
+	var out []Lot
+	for _, lots := range l.lots {
+		for _, lot := range lots {
+			out = append(out, *lot)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Asset != out[j].Asset {
+			return out[i].Asset < out[j].Asset
+		}
+		return out[i].Acquired.Before(out[j].Acquired)
+	})
+	return out
+}
+
+// Position is the open holding of one asset marked against a market price.
+type Position struct {
+	Asset       string          `json:"asset"`
+	Size        decimal.Decimal `json:"size"`
+	CostBasis   decimal.Decimal `json:"cost_basis"`
+	MarketPrice decimal.Decimal `json:"market_price"`
+	MarketValue decimal.Decimal `json:"market_value"`
+	Unrealized  decimal.Decimal `json:"unrealized"`
+	Priced      bool            `json:"priced"`
+}
+
+// Positions aggregates open lots per asset and marks them with price.
+// price returns ok=false when no market price is known; such positions are left unpriced.
+func (l *Ledger) Positions(price func(asset string) (decimal.Decimal, bool, error)) ([]Position, error) {
+	byAsset := make(map[string]*Position)
+	var assets []string
+	for _, lot := range l.Lots() {
+		p, ok := byAsset[lot.Asset]
+		if !ok {
+			p = &Position{Asset: lot.Asset}
+			byAsset[lot.Asset] = p
+			assets = append(assets, lot.Asset)
+		}
+		p.Size = p.Size.Add(lot.Size)
+		p.CostBasis = p.CostBasis.Add(lot.cost)
+	}
+
+	out := make([]Position, 0, len(assets))
+	for _, a := range assets {
+		p := byAsset[a]
+		mp, ok, err := price(a)
+		if err != nil {
+			return nil, fmt.Errorf("price %s: %w", a, err)
+		}
+		if ok {
+			p.Priced = true
+			p.MarketPrice = mp
+			p.MarketValue = p.Size.Mul(mp)
+			p.Unrealized = p.MarketValue.Sub(p.CostBasis)
+		}
+		out = append(out, *p)
+	}
+	return out, nil
+}
+
+// YearSummary totals the disposals of one calendar year.
+type YearSummary struct {
+	Year          int             `json:"year"`
+	Disposals     int             `json:"disposals"`
+	Proceeds      decimal.Decimal `json:"proceeds"`
+	CostBasis     decimal.Decimal `json:"cost_basis"`
+	Gain          decimal.Decimal `json:"gain"`
+	ShortTermGain decimal.Decimal `json:"short_term_gain"`
+	LongTermGain  decimal.Decimal `json:"long_term_gain"`
+}
+
+// DisposalsInYear filters disposals to those disposed in year (UTC).
+func DisposalsInYear(disposals []Disposal, year int) []Disposal {
+	var out []Disposal
+	for _, d := range disposals {
+		if d.Disposed.UTC().Year() == year {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+// SummarizeByYear totals disposals per calendar year, oldest first.
+func SummarizeByYear(disposals []Disposal) []YearSummary {
+	byYear := make(map[int]*YearSummary)
+	for _, d := range disposals {
+		y := d.Disposed.UTC().Year()
+		s, ok := byYear[y]
+		if !ok {
+			s = &YearSummary{Year: y}
+			byYear[y] = s
+		}
+		s.Disposals++
+		s.Proceeds = s.Proceeds.Add(d.Proceeds)
+		s.CostBasis = s.CostBasis.Add(d.CostBasis)
+		s.Gain = s.Gain.Add(d.Gain)
+		if d.LongTerm {
+			s.LongTermGain = s.LongTermGain.Add(d.Gain)
+		} else {
+			s.ShortTermGain = s.ShortTermGain.Add(d.Gain)
+		}
+	}
+	out := make([]YearSummary, 0, len(byYear))
+	for _, s := range byYear {
+		out = append(out, *s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Year < out[j].Year })
+	return out
+}
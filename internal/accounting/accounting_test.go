@@ -0,0 +1,142 @@
+package accounting
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func day(n int) time.Time {
+	return time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, n)
+}
+
+// Buys 1 BTC at 100, 1 at 300, 1 at 200, then sells 1.5 at 400.
+func sampleTrades() []Trade {
+	return []Trade{
+		{ID: "b1", Time: day(0), Asset: "BTC", Quote: "USD", Buy: true, Size: dec("1"), Price: dec("100")},
+		{ID: "b2", Time: day(1), Asset: "BTC", Quote: "USD", Buy: true, Size: dec("1"), Price: dec("300")},
+		{ID: "b3", Time: day(2), Asset: "BTC", Quote: "USD", Buy: true, Size: dec("1"), Price: dec("200")},
+		{ID: "s1", Time: day(3), Asset: "BTC", Quote: "USD", Size: dec("1.5"), Price: dec("400")},
+	}
+}
+
+func realized(t *testing.T, method Method) (decimal.Decimal, *Ledger) {
+	t.Helper()
+	l := NewLedger(method)
+	for _, tr := range sampleTrades() {
+		if err := l.Apply(tr); err != nil {
+			t.Fatalf("Apply(%s): %v", tr.ID, err)
+		}
+	}
+	var gain decimal.Decimal
+	for _, d := range l.Disposals() {
+		gain = gain.Add(d.Gain)
+	}
+	return gain, l
+}
+
+func dec(s string) decimal.Decimal { return decimal.RequireFromString(s) }
+
+// eq reports whether a equals the decimal string want exactly.
+func eq(a decimal.Decimal, want string) bool { return a.Equal(dec(want)) }
+
+func TestRealizedGainByMethod(t *testing.T) {
+	cases := []struct {
+		method Method
+		gain   string // proceeds 600 minus cost of the consumed 1.5 BTC
+	}{
+		{FIFO, "350"},    // 600 - (100 + 150)
+		{LIFO, "250"},    // 600 - (200 + 150)
+		{HIFO, "200"},    // 600 - (300 + 100)
+		{Average, "300"}, // 600 - 1.5*200
+	}
+	for _, c := range cases {
+		gain, _ := realized(t, c.method)
+		if !eq(gain, c.gain) {
+			t.Errorf("%s: realized gain = %v, want %v", c.method, gain, c.gain)
+		}
+	}
+}
+
+func TestRemainingLotsAndUnrealized(t *testing.T) {
+	_, l := realized(t, FIFO)
+	lots := l.Lots()
+	if len(lots) != 2 || lots[0].TradeID != "b2" || !eq(lots[0].Size, "0.5") || lots[1].TradeID != "b3" {
+		t.Fatalf("unexpected open lots: %+v", lots)
+	}
+
+	positions, err := l.Positions(func(asset string) (decimal.Decimal, bool, error) { return dec("500"), true, nil })
+	if err != nil {
+		t.Fatalf("Positions: %v", err)
+	}
+	if len(positions) != 1 {
+		t.Fatalf("positions = %d, want 1", len(positions))
+	}
+	p := positions[0]
+	// 0.5 BTC at 300 plus 1 BTC at 200 = 350 cost; 1.5 BTC at 500 = 750 value.
+	if !eq(p.Size, "1.5") || !eq(p.CostBasis, "350") || !eq(p.Unrealized, "400") {
+		t.Errorf("position = %+v, want size 1.5, cost 350, unrealized 400", p)
+	}
+}
+
+func TestFeesAdjustBasisAndProceeds(t *testing.T) {
+	l := NewLedger(FIFO)
+	l.Apply(Trade{ID: "b", Time: day(0), Asset: "ETH", Buy: true, Size: dec("2"), Price: dec("10"), Fee: dec("2")})
+	l.Apply(Trade{ID: "s", Time: day(1), Asset: "ETH", Size: dec("2"), Price: dec("15"), Fee: dec("1")})
+
+	d := l.Disposals()
+	if len(d) != 1 {
+		t.Fatalf("disposals = %d, want 1", len(d))
+	}
+	if !eq(d[0].CostBasis, "22") || !eq(d[0].Proceeds, "29") || !eq(d[0].Gain, "7") {
+		t.Errorf("disposal = %+v, want cost 22, proceeds 29, gain 7", d[0])
+	}
+}
+
+func TestUnmatchedSellAndHoldingPeriod(t *testing.T) {
+	l := NewLedger(FIFO)
+	l.Apply(Trade{ID: "b", Time: day(0), Asset: "SOL", Buy: true, Size: dec("1"), Price: dec("10")})
+	l.Apply(Trade{ID: "s", Time: day(400), Asset: "SOL", Size: dec("3"), Price: dec("20")})
+
+	d := l.Disposals()
+	if len(d) != 2 {
+		t.Fatalf("disposals = %d, want 2", len(d))
+	}
+	if !d[0].LongTerm || d[0].Unmatched {
+		t.Errorf("first disposal should be long-term and matched: %+v", d[0])
+	}
+	if !d[1].Unmatched || !eq(d[1].Size, "2") || !eq(d[1].Gain, "40") {
+		t.Errorf("second disposal should be an unmatched 2 SOL with gain 40: %+v", d[1])
+	}
+
+	summary := SummarizeByYear(d)
+	if len(summary) != 1 || summary[0].Year != 2025 || !eq(summary[0].Gain, "50") || !eq(summary[0].LongTermGain, "10") {
+		t.Errorf("summary = %+v", summary)
+	}
+	if got := DisposalsInYear(d, 2024); len(got) != 0 {
+		t.Errorf("DisposalsInYear(2024) = %d, want 0", len(got))
+	}
+}
+
+func TestExactAmountsDoNotDrift(t *testing.T) {
+	l := NewLedger(FIFO)
+	for i, id := range []string{"b1", "b2", "b3"} {
+		l.Apply(Trade{ID: id, Time: day(i), Asset: "BTC", Buy: true, Size: dec("0.1"), Price: dec("100"), Fee: dec("1")})
+	}
+	l.Apply(Trade{ID: "s", Time: day(5), Asset: "BTC", Size: dec("0.3"), Price: dec("300"), Fee: dec("1")})
+
+	// 0.1+0.1+0.1 is exactly 0.3, so no dust lot is left behind.
+	if lots := l.Lots(); len(lots) != 0 {
+		t.Errorf("open lots = %+v, want none", lots)
+	}
+	// The sell fee is split across three lots with repeating shares that still add up to 89.
+	var proceeds, cost decimal.Decimal
+	for _, d := range l.Disposals() {
+		proceeds = proceeds.Add(d.Proceeds)
+		cost = cost.Add(d.CostBasis)
+	}
+	if !eq(proceeds, "89") || !eq(cost, "33") {
+		t.Errorf("proceeds = %s, cost = %s, want 89 and 33", proceeds, cost)
+	}
+}
@@ -0,0 +1,199 @@
+// Package audit checks stored candles for impossible or suspicious values and repairs them from
+// the exchange.
+package audit
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"cryptool/internal/exchange"
+)
+
+// Kind classifies a finding.
+type Kind string
+
+const (
+	// KindHighBelowLow is a candle whose high is below its low.
+	KindHighBelowLow Kind = "high_below_low"
+	// KindOpenOutOfRange is a candle whose open lies outside [low, high].
+	KindOpenOutOfRange Kind = "open_out_of_range"
+	// KindCloseOutOfRange is a candle whose close lies outside [low, high].
+	KindCloseOutOfRange Kind = "close_out_of_range"
+	// KindNonPositivePrice is a candle with a zero or negative open, high, low or close.
+	KindNonPositivePrice Kind = "non_positive_price"
+	// KindMisaligned is a candle that does not start on a bucket boundary.
+	KindMisaligned Kind = "misaligned"
+	// KindDuplicate is a candle in a bucket that already has an earlier candle.
+	KindDuplicate Kind = "duplicate"
+	// KindSpike is a candle whose high or low is far from its neighbours' closes.
+	KindSpike Kind = "spike"
+	// KindMismatch is a candle that differs from a re-fetch from the exchange.
+	KindMismatch Kind = "mismatch"
+)
+
+// Default spike detection settings.
+const (
+	DefaultSpikeSigma  = 8.0
+	DefaultSpikeWindow = 30
+)
+
+// minSpikeScale is the smallest unit a spike is measured in, relative to the neighbours' mean
+// close, so a tick in a series of identical candles is not reported as a spike.
+const minSpikeScale = 0.001
+
+// Options tunes the checks. Zero fields use the defaults.
+type Options struct {
+	// SpikeSigma is how far from the neighbours' mean close a high or low must be to count as a
+	// spike, in units of the neighbours' close standard deviation or mean range.
+	SpikeSigma float64
+	// SpikeWindow is the number of candles on each side that make up the neighbours.
+	SpikeWindow int
+}
+
+func (o Options) withDefaults() Options {
+	if o.SpikeSigma <= 0 {
+		o.SpikeSigma = DefaultSpikeSigma
+	}
+	if o.SpikeWindow <= 0 {
+		o.SpikeWindow = DefaultSpikeWindow
+	}
+	return o
+}
+
+// Finding is one anomaly in a stored candle.
+type Finding struct {
+	Time   time.Time `json:"time"`
+	Kind   Kind      `json:"kind"`
+	Detail string    `json:"detail"`
+}
+
+// Check reports anomalies in candles of granularitySec seconds, which must be sorted by time.
+// A candle can produce several findings.
+func Check(candles []exchange.Candle, granularitySec int, opt Options) []Finding {
+	opt = opt.withDefaults()
+	var out []Finding
+	add := func(t time.Time, k Kind, format string, args ...interface{}) {
+		out = append(out, Finding{Time: t, Kind: k, Detail: fmt.Sprintf(format, args...)})
+	}
+
+	step := int64(granularitySec)
+	seen := make(map[int64]time.Time, len(candles))
+	for i, c := range candles {
+		if field, v, ok := nonPositive(c); ok {
+			add(c.Time, KindNonPositivePrice, "%s %s", field, v)
+		}
+		if c.High.LessThan(c.Low) {
+			add(c.Time, KindHighBelowLow, "high %s < low %s", c.High, c.Low)
+		} else {
+			if c.Open.LessThan(c.Low) || c.Open.GreaterThan(c.High) {
+				add(c.Time, KindOpenOutOfRange, "open %s outside [%s, %s]", c.Open, c.Low, c.High)
+			}
+			if c.Close.LessThan(c.Low) || c.Close.GreaterThan(c.High) {
+				add(c.Time, KindCloseOutOfRange, "close %s outside [%s, %s]", c.Close, c.Low, c.High)
+			}
+		}
+
+		unix := c.Time.Unix()
+		if c.Time.Nanosecond() != 0 || unix%step != 0 {
+			add(c.Time, KindMisaligned, "not on a %ds boundary", granularitySec)
+		}
+		bucket := unix / step
+		if first, ok := seen[bucket]; ok {
+			add(c.Time, KindDuplicate, "same bucket as %s", first.UTC().Format(time.RFC3339))
+		} else {
+			seen[bucket] = c.Time
+		}
+
+		if sigma, mean, ok := spikeSigma(candles, i, opt.SpikeWindow); ok && sigma > opt.SpikeSigma {
+			add(c.Time, KindSpike, "high %s / low %s is %.1f sigma from neighbours' mean close %.8g", c.High, c.Low, sigma, mean)
+		}
+	}
+	return out
+}
+
+// nonPositive returns the first price field of c that is zero or negative.
+func nonPositive(c exchange.Candle) (string, decimal.Decimal, bool) {
+	for _, f := range []struct {
+		name string
+		v    decimal.Decimal
+	}{{"open", c.Open}, {"high", c.High}, {"low", c.Low}, {"close", c.Close}} {
+		if !f.v.IsPositive() {
+			return f.name, f.v, true
+		}
+	}
+	return "", decimal.Zero, false
+}
+
+// spikeSigma measures how far the high or low of candles[i] lies from the mean close of up to
+// window candles on either side. The unit is the larger of the standard deviation of those
+// closes and their mean high-low range, so ordinary wicks in a quiet market do not count.
+// ok is false when there are too few neighbours to tell.
+func spikeSigma(candles []exchange.Candle, i, window int) (sigma, mean float64, ok bool) {
+	lo, hi := max(0, i-window), min(len(candles), i+window+1)
+	var sum, ranges float64
+	n := 0
+	for j := lo; j < hi; j++ {
+		if j != i {
+			sum += candles[j].Close.InexactFloat64()
+			ranges += math.Abs(candles[j].High.InexactFloat64() - candles[j].Low.InexactFloat64())
+			n++
+		}
+	}
+	if n < 4 {
+		return 0, 0, false
+	}
+	mean = sum / float64(n)
+	var sq float64
+	for j := lo; j < hi; j++ {
+		if j != i {
+			d := candles[j].Close.InexactFloat64() - mean
+			sq += d * d
+		}
+	}
+	scale := math.Max(math.Sqrt(sq/float64(n)), ranges/float64(n))
+	scale = math.Max(scale, math.Abs(mean)*minSpikeScale)
+	if scale == 0 {
+		return 0, 0, false
+	}
+	c := candles[i]
+	d := math.Max(math.Abs(c.High.InexactFloat64()-mean), math.Abs(c.Low.InexactFloat64()-mean))
+	return d / scale, mean, true
+}
+
+// Compare reports stored candles that differ from fetched, a re-fetch of the same range from the
+// exchange, or that the exchange did not return. Fetched candles with no stored counterpart are
+// not reported; filling those is the backfill's job.
+func Compare(stored, fetched []exchange.Candle) []Finding {
+	byTime := make(map[int64]exchange.Candle, len(fetched))
+	for _, c := range fetched {
+		byTime[c.Time.UnixNano()] = c
+	}
+	var out []Finding
+	for _, c := range stored {
+		f, ok := byTime[c.Time.UnixNano()]
+		if !ok {
+			out = append(out, Finding{Time: c.Time, Kind: KindMismatch, Detail: "not returned by the exchange"})
+			continue
+		}
+		var diffs []string
+		for _, d := range []struct {
+			name      string
+			have, got decimal.Decimal
+		}{
+			{"open", c.Open, f.Open}, {"high", c.High, f.High}, {"low", c.Low, f.Low},
+			{"close", c.Close, f.Close}, {"volume", c.Volume, f.Volume},
+		} {
+			if !d.have.Equal(d.got) {
+				diffs = append(diffs, fmt.Sprintf("%s %s != %s", d.name, d.have, d.got))
+			}
+		}
+		if len(diffs) > 0 {
+			out = append(out, Finding{Time: c.Time, Kind: KindMismatch, Detail: strings.Join(diffs, ", ")})
+		}
+	}
+	return out
+}
@@ -0,0 +1,261 @@
+package audit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"cryptool/internal/exchange"
+)
+
+var t0 = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func minute(n int) time.Time { return t0.Add(time.Duration(n) * time.Minute) }
+
+func d(s string) decimal.Decimal { return decimal.RequireFromString(s) }
+
+// flat returns n well-formed 1m candles around 100 starting at t0.
+func flat(n int) []exchange.Candle {
+	out := make([]exchange.Candle, n)
+	for i := range out {
+		out[i] = exchange.Candle{Time: minute(i), Open: d("100"), High: d("101"), Low: d("99"), Close: d("100"), Volume: d("5")}
+	}
+	return out
+}
+
+func kinds(findings []Finding) map[Kind][]time.Time {
+	out := map[Kind][]time.Time{}
+	for _, f := range findings {
+		out[f.Kind] = append(out[f.Kind], f.Time)
+	}
+	return out
+}
+
+func TestCheckCleanCandles(t *testing.T) {
+	if got := Check(flat(100), 60, Options{}); len(got) != 0 {
+		t.Errorf("findings = %+v, want none", got)
+	}
+}
+
+func TestCheckFindsBrokenCandles(t *testing.T) {
+	candles := flat(10)
+	candles[1].High, candles[1].Low = d("98"), d("99")
+	candles[2].Open = d("102")
+	candles[3].Close = d("98")
+	candles[4].Low = d("0")
+	candles[5].Time = minute(5).Add(30 * time.Second) // off the boundary and in bucket 5 with nothing else
+	candles[6].Time = minute(5).Add(45 * time.Second) // shares bucket 5 with candles[5]
+
+	got := kinds(Check(candles, 60, Options{}))
+	want := map[Kind][]time.Time{
+		KindHighBelowLow:     {minute(1)},
+		KindOpenOutOfRange:   {minute(2)},
+		KindCloseOutOfRange:  {minute(3)},
+		KindNonPositivePrice: {minute(4)},
+		KindMisaligned:       {candles[5].Time, candles[6].Time},
+		KindDuplicate:        {candles[6].Time},
+		KindSpike:            {minute(4)}, // a low of zero is also far from every neighbour
+	}
+	for k, w := range want {
+		if len(got[k]) != len(w) {
+			t.Errorf("%s at %v, want %v", k, got[k], w)
+			continue
+		}
+		for i := range w {
+			if !got[k][i].Equal(w[i]) {
+				t.Errorf("%s at %v, want %v", k, got[k], w)
+			}
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("kinds = %v, want only %v", got, want)
+	}
+}
+
+func TestCheckFindsSpikes(t *testing.T) {
+	candles := flat(61)
+	candles[30].High = d("150")
+	got := kinds(Check(candles, 60, Options{}))
+	if s := got[KindSpike]; len(s) != 1 || !s[0].Equal(minute(30)) {
+		t.Errorf("spikes at %v, want only %v", s, minute(30))
+	}
+
+	// A steady trend is not a spike, however far it moves over the window.
+	for i := range candles {
+		p := decimal.NewFromInt(int64(100 + i))
+		candles[i].Open, candles[i].Close = p, p
+		candles[i].High, candles[i].Low = p.Add(d("0.5")), p.Sub(d("0.5"))
+	}
+	if s := kinds(Check(candles, 60, Options{}))[KindSpike]; len(s) != 0 {
+		t.Errorf("spikes in a trend at %v, want none", s)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	stored := flat(3)
+	fetched := flat(3)[:2]
+	fetched[0].Volume = d("5.000") // same value, different scale
+	fetched[1].Close = d("100.5")
+
+	got := Compare(stored, fetched)
+	if len(got) != 2 || !got[0].Time.Equal(minute(1)) || !got[1].Time.Equal(minute(2)) {
+		t.Fatalf("findings = %+v, want mismatches at minutes 1 and 2", got)
+	}
+	if got[0].Detail != "close 100 != 100.5" {
+		t.Errorf("detail = %q", got[0].Detail)
+	}
+	if got[1].Detail != "not returned by the exchange" {
+		t.Errorf("detail = %q", got[1].Detail)
+	}
+}
+
+// fakeStore serves candles from memory and records repairs.
+type fakeStore struct {
+	candles     []exchange.Candle
+	loads       int
+	quarantined map[time.Time]string
+	upserted    []exchange.Candle
+}
+
+func (s *fakeStore) GetCandles(ctx context.Context, x, product string, granularitySec int, start, end time.Time) ([]exchange.Candle, error) {
+	s.loads++
+	var out []exchange.Candle
+	for _, c := range s.candles {
+		if !c.Time.Before(start) && c.Time.Before(end) {
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
+
+func (s *fakeStore) QuarantineCandles(ctx context.Context, x, product string, granularitySec int, reasons map[time.Time]string) (int, error) {
+	s.quarantined = reasons
+	return len(reasons), nil
+}
+
+func (s *fakeStore) UpsertCandles(ctx context.Context, x, product string, granularitySec int, candles []exchange.Candle) (int, error) {
+	s.upserted = append(s.upserted, candles...)
+	return len(candles), nil
+}
+
+// fakeSource serves a clean copy of the stored history.
+type fakeSource struct {
+	candles []exchange.Candle
+	calls   int
+}
+
+func (f *fakeSource) Name() string                { return "fake" }
+func (f *fakeSource) MaxCandlesPerRequest() int64 { return 50 }
+func (f *fakeSource) GetProducts(ctx context.Context) ([]exchange.Product, error) {
+	return nil, nil
+}
+
+func (f *fakeSource) GetCandles(ctx context.Context, productID string, start, end time.Time, granularity string) ([]exchange.Candle, error) {
+	f.calls++
+	var out []exchange.Candle
+	for _, c := range f.candles {
+		if !c.Time.Before(start) && !c.Time.After(end) {
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
+
+func newTestAuditor(store *fakeStore) *Auditor {
+	a := New("fake", store)
+	a.Now = func() time.Time { return t0.AddDate(0, 0, 1) }
+	return a
+}
+
+func TestAuditorRunsInChunks(t *testing.T) {
+	store := &fakeStore{candles: flat(300)}
+	store.candles[99].High = d("150") // last bucket of the first chunk
+	store.candles[100].Open = d("0")  // first bucket of the second chunk
+	src := &fakeSource{candles: flat(300)}
+
+	a := newTestAuditor(store)
+	a.ChunkBuckets = 100
+	a.Source = src
+	r, err := a.Run(context.Background(), "BTC-USD", "1m", t0, minute(300))
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if r.Candles != 300 || store.loads != 3 {
+		t.Errorf("candles = %d, loads = %d, want 300 and 3", r.Candles, store.loads)
+	}
+	// Each chunk of 100 buckets takes two requests of at most 50.
+	if src.calls != 6 {
+		t.Errorf("source calls = %d, want 6", src.calls)
+	}
+
+	counts := r.Counts()
+	want := map[Kind]int{KindSpike: 1, KindNonPositivePrice: 1, KindOpenOutOfRange: 1, KindMismatch: 2}
+	if len(counts) != len(want) {
+		t.Errorf("counts = %v, want %v", counts, want)
+	}
+	for k, n := range want {
+		if counts[k] != n {
+			t.Errorf("counts = %v, want %v", counts, want)
+		}
+	}
+	for i := 1; i < len(r.Findings); i++ {
+		if r.Findings[i].Time.Before(r.Findings[i-1].Time) {
+			t.Fatalf("findings not sorted by time: %+v", r.Findings)
+		}
+	}
+}
+
+func TestAuditorSkipsOpenBucket(t *testing.T) {
+	store := &fakeStore{candles: flat(10)}
+	a := newTestAuditor(store)
+	a.Now = func() time.Time { return minute(9).Add(30 * time.Second) }
+
+	r, err := a.Run(context.Background(), "BTC-USD", "1m", t0, minute(10))
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if r.Candles != 9 || !r.End.Equal(minute(9)) {
+		t.Errorf("candles = %d, end = %v, want 9 and %v", r.Candles, r.End, minute(9))
+	}
+}
+
+func TestAuditorRepairsFindings(t *testing.T) {
+	store := &fakeStore{candles: flat(120)}
+	store.candles[10].High, store.candles[10].Low = d("98"), d("99")
+	store.candles[100].Close = d("-1")
+	store.candles[110].Time = minute(110).Add(time.Second)
+
+	a := newTestAuditor(store)
+	r, err := a.Run(context.Background(), "BTC-USD", "1m", t0, minute(120))
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	if _, err := a.Quarantine(context.Background(), r); err != nil {
+		t.Fatalf("Quarantine: %v", err)
+	}
+	if r.Quarantined != 3 || store.quarantined[minute(10)] != "high_below_low" {
+		t.Errorf("quarantined %d: %v", r.Quarantined, store.quarantined)
+	}
+
+	if _, err := a.Refetch(context.Background(), r); err == nil {
+		t.Fatal("Refetch without a source succeeded")
+	}
+	src := &fakeSource{candles: flat(120)}
+	a.Source = src
+	if _, err := a.Refetch(context.Background(), r); err != nil {
+		t.Fatalf("Refetch: %v", err)
+	}
+	// The misaligned candle has no bucket to refetch; the other two are 90 buckets apart,
+	// which is more than one request of 50.
+	if r.Refetched != 2 || src.calls != 2 {
+		t.Errorf("refetched = %d in %d calls, want 2 in 2", r.Refetched, src.calls)
+	}
+	for _, c := range store.upserted {
+		if !c.Close.Equal(d("100")) {
+			t.Errorf("upserted %+v, want the exchange's candle", c)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"cryptool/internal/backfill"
+	"cryptool/internal/exchange"
+)
+
+// DefaultChunkBuckets is the number of buckets audited per store call.
+const DefaultChunkBuckets = 10000
+
+// Store is the subset of ingest.Store the auditor needs.
+type Store interface {
+	GetCandles(ctx context.Context, exchange, product string, granularitySec int, start, end time.Time) ([]exchange.Candle, error)
+	QuarantineCandles(ctx context.Context, exchange, product string, granularitySec int, reasons map[time.Time]string) (int, error)
+	UpsertCandles(ctx context.Context, exchange, product string, granularitySec int, candles []exchange.Candle) (int, error)
+}
+
+// Report is the result of one audit.
+type Report struct {
+	Exchange    string    `json:"exchange"`
+	Product     string    `json:"product"`
+	Granularity string    `json:"granularity"`
+	Start       time.Time `json:"start"`
+	End         time.Time `json:"end"`
+	Candles     int       `json:"candles"`
+	Findings    []Finding `json:"findings"`
+	Quarantined int       `json:"quarantined"`
+	Refetched   int       `json:"refetched"`
+}
+
+// Counts returns the number of findings of each kind.
+func (r *Report) Counts() map[Kind]int {
+	out := map[Kind]int{}
+	for _, f := range r.Findings {
+		out[f.Kind]++
+	}
+	return out
+}
+
+// Auditor runs Check over stored candles in chunks and, when it has a Source, compares them
+// with a re-fetch from the exchange.
+type Auditor struct {
+	// Exchange is the value of the candles.exchange column.
+	Exchange string
+	// Options tunes the checks.
+	Options Options
+	// ChunkBuckets is the number of buckets loaded per store call.
+	ChunkBuckets int
+	// Source, when set, is asked for every audited bucket again and differences are reported
+	// as KindMismatch. Refetch requires it.
+	Source exchange.MarketData
+	// Now returns the current time; the bucket still in progress is not audited.
+	Now func() time.Time
+
+	store Store
+}
+
+// New returns an Auditor for the candles of exchangeName in store.
+func New(exchangeName string, store Store) *Auditor {
+	return &Auditor{
+		Exchange:     exchangeName,
+		ChunkBuckets: DefaultChunkBuckets,
+		Now:          func() time.Time { return time.Now().UTC() },
+		store:        store,
+	}
+}
+
+// Run audits the candles of product in [start, end) and returns the findings sorted by time.
+func (a *Auditor) Run(ctx context.Context, product, granularity string, start, end time.Time) (*Report, error) {
+	sec, err := backfill.ParseGranularity(granularity)
+	if err != nil {
+		return nil, err
+	}
+	step := time.Duration(sec) * time.Second
+	start = start.UTC().Truncate(step)
+	if open := a.Now().UTC().Truncate(step); end.After(open) {
+		end = open
+	}
+	r := &Report{Exchange: a.Exchange, Product: product, Granularity: granularity, Start: start, End: end}
+
+	opt := a.Options.withDefaults()
+	chunk := a.ChunkBuckets
+	if chunk <= 0 {
+		chunk = DefaultChunkBuckets
+	}
+	// Neighbouring buckets are loaded too so spikes at chunk edges see a full window.
+	pad := time.Duration(opt.SpikeWindow) * step
+	for cs := start; cs.Before(end); {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		ce := cs.Add(time.Duration(chunk) * step)
+		if ce.After(end) {
+			ce = end
+		}
+		in := func(t time.Time) bool { return !t.Before(cs) && t.Before(ce) }
+
+		candles, err := a.store.GetCandles(ctx, a.Exchange, product, int(sec), cs.Add(-pad), ce.Add(pad))
+		if err != nil {
+			return nil, err
+		}
+		var stored []exchange.Candle
+		for _, c := range candles {
+			if in(c.Time) {
+				stored = append(stored, c)
+			}
+		}
+		r.Candles += len(stored)
+		for _, f := range Check(candles, int(sec), opt) {
+			if in(f.Time) {
+				r.Findings = append(r.Findings, f)
+			}
+		}
+		if a.Source != nil {
+			fetched, err := a.fetch(ctx, product, granularity, step, cs, ce)
+			if err != nil {
+				return nil, err
+			}
+			r.Findings = append(r.Findings, Compare(stored, fetched)...)
+		}
+		cs = ce
+	}
+	sort.SliceStable(r.Findings, func(i, j int) bool { return r.Findings[i].Time.Before(r.Findings[j].Time) })
+	return r, nil
+}
+
+// fetch requests [from, to) from the source in windows of at most its per-request limit.
+func (a *Auditor) fetch(ctx context.Context, product, granularity string, step time.Duration, from, to time.Time) ([]exchange.Candle, error) {
+	window := time.Duration(a.maxBuckets()) * step
+	var out []exchange.Candle
+	for ws := from; ws.Before(to); ws = ws.Add(window) {
+		we := ws.Add(window)
+		if we.After(to) {
+			we = to
+		}
+		candles, err := a.Source.GetCandles(ctx, product, ws, we.Add(-step), granularity)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, candles...)
+	}
+	return out, nil
+}
+
+func (a *Auditor) maxBuckets() int64 {
+	if n := a.Source.MaxCandlesPerRequest(); n > 0 {
+		return n
+	}
+	return backfill.DefaultMaxBuckets
+}
+
+// Quarantine moves every candle with a finding out of the candles table, recording the kinds
+// found as the reason. The buckets then count as missing and are fetched again by the backfill.
+func (a *Auditor) Quarantine(ctx context.Context, r *Report) (int, error) {
+	sec, err := backfill.ParseGranularity(r.Granularity)
+	if err != nil {
+		return 0, err
+	}
+	kinds := map[time.Time][]string{}
+	for _, f := range r.Findings {
+		t := f.Time.UTC()
+		if !containsKind(kinds[t], f.Kind) {
+			kinds[t] = append(kinds[t], string(f.Kind))
+		}
+	}
+	if len(kinds) == 0 {
+		return 0, nil
+	}
+	reasons := make(map[time.Time]string, len(kinds))
+	for t, k := range kinds {
+		reasons[t] = strings.Join(k, ",")
+	}
+	n, err := a.store.QuarantineCandles(ctx, a.Exchange, r.Product, int(sec), reasons)
+	r.Quarantined += n
+	return n, err
+}
+
+func containsKind(kinds []string, k Kind) bool {
+	for _, s := range kinds {
+		if s == string(k) {
+			return true
+		}
+	}
+	return false
+}
+
+// Refetch requests every flagged bucket from the source again and overwrites the stored candle
+// with the exchange's. Misaligned candles have no bucket to request and are skipped, as are
+// buckets the exchange returns nothing for.
+func (a *Auditor) Refetch(ctx context.Context, r *Report) (int, error) {
+	if a.Source == nil {
+		return 0, errors.New("refetch needs an exchange source")
+	}
+	sec, err := backfill.ParseGranularity(r.Granularity)
+	if err != nil {
+		return 0, err
+	}
+	step := time.Duration(sec) * time.Second
+	flagged := map[int64]bool{}
+	var times []time.Time
+	for _, f := range r.Findings {
+		t := f.Time.UTC()
+		if !t.Truncate(step).Equal(t) || flagged[t.UnixNano()] {
+			continue
+		}
+		flagged[t.UnixNano()] = true
+		times = append(times, t)
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+
+	window := time.Duration(a.maxBuckets()) * step
+	total := 0
+	for i := 0; i < len(times); {
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+		j := i
+		for j < len(times) && times[j].Before(times[i].Add(window)) {
+			j++
+		}
+		fetched, err := a.Source.GetCandles(ctx, r.Product, times[i], times[j-1], r.Granularity)
+		if err != nil {
+			return total, err
+		}
+		var replace []exchange.Candle
+		for _, c := range fetched {
+			if flagged[c.Time.UTC().UnixNano()] {
+				replace = append(replace, c)
+			}
+		}
+		if len(replace) > 0 {
+			n, err := a.store.UpsertCandles(ctx, a.Exchange, r.Product, int(sec), replace)
+			if err != nil {
+				return total, err
+			}
+			total += n
+		}
+		i = j
+	}
+	r.Refetched += total
+	return total, nil
+}
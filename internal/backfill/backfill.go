@@ -0,0 +1,241 @@
+// Package backfill fills gaps in the local candle history from an exchange candle source.
+package backfill
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"cryptool/internal/exchange"
+)
+
+// DefaultMaxBuckets is used when neither the Backfiller nor its source set a per-request limit.
+const DefaultMaxBuckets = 350
+
+// DefaultGapBatchSize is the number of gap timestamps marked per store call.
+const DefaultGapBatchSize = 5000
+
+// Store is the subset of ingest.Store the backfiller needs.
+type Store interface {
+	CountGapsToFill(ctx context.Context, exchange, product string, start, end time.Time, granularitySec int) (int, error)
+	GetMissingCandleTimestamps(ctx context.Context, exchange, product string, start, end time.Time, granularitySec int) ([]time.Time, error)
+	InsertCandles(ctx context.Context, exchange, product string, granularitySec int, candles []exchange.Candle) (int, error)
+	MarkGaps(ctx context.Context, exchange, product string, granularitySec int, times []time.Time) (int, error)
+}
+
+// EventKind identifies a progress event emitted by the backfiller.
+type EventKind string
+
+const (
+	// EventBatch is emitted before a window is fetched from the source.
+	EventBatch EventKind = "batch"
+	// EventInserted is emitted after the candles of a window were stored.
+	EventInserted EventKind = "inserted"
+	// EventGapMarked is emitted for every timestamp marked as an empty bucket.
+	EventGapMarked EventKind = "gap_marked"
+	// EventGapError is emitted when marking a gap failed. It does not stop the fill.
+	EventGapError EventKind = "gap_error"
+)
+
+// Event describes progress of a Fill call.
+type Event struct {
+	Kind     EventKind
+	Product  string
+	Batch    int       // batch number within the Fill call
+	Start    time.Time // window start (EventBatch, EventInserted)
+	End      time.Time // window end, exclusive (EventBatch, EventInserted)
+	Gaps     int       // potential gaps in the window (EventBatch)
+	Received int       // candles returned by the source (EventInserted)
+	Inserted int       // new candles stored (EventInserted)
+	Time     time.Time // gap timestamp (EventGapMarked, EventGapError)
+	Err      error     // EventGapError only
+}
+
+// Backfiller recursively splits a time range into API-sized windows, fetches
+// the windows that still contain gaps, and marks buckets the source has no data for.
+type Backfiller struct {
+	// Exchange is the value stored in the candles.exchange column.
+	Exchange string
+	// MaxBuckets is the per-request candle limit of the source.
+	MaxBuckets int64
+	// GapBatchSize is the number of gap timestamps marked per store call.
+	GapBatchSize int
+	// Now returns the current time; ranges are clamped so no future data is requested.
+	Now func() time.Time
+	// OnEvent receives progress events. Calls are serialized.
+	OnEvent func(Event)
+
+	source  exchange.MarketData
+	store   Store
+	eventMu sync.Mutex
+}
+
+// New returns a Backfiller that stores candles under the source's exchange name and
+// respects its per-request candle limit.
+func New(source exchange.MarketData, store Store) *Backfiller {
+	maxBuckets := source.MaxCandlesPerRequest()
+	if maxBuckets <= 0 {
+		maxBuckets = DefaultMaxBuckets
+	}
+	return &Backfiller{
+		Exchange:     source.Name(),
+		MaxBuckets:   maxBuckets,
+		GapBatchSize: DefaultGapBatchSize,
+		Now:          time.Now,
+		source:       source,
+		store:        store,
+	}
+}
+
+// Fill fetches all missing candles for product in [start, end) and returns how many new candles were stored.
+// It stops at the first source error, wrapped so errors.Is sees adapter sentinels such as
+// exchange.ErrUnauthorized; the window whose request failed is never marked as gaps.
+// An unknown granularity, or one the source cannot serve, fails before anything is fetched.
+func (b *Backfiller) Fill(ctx context.Context, product, granularity string, start, end time.Time) (int, error) {
+	secPerBucket, err := ParseGranularity(granularity)
+	if err != nil {
+		return 0, err
+	}
+	if err := exchange.CheckGranularity(b.source, granularity); err != nil {
+		return 0, err
+	}
+	maxBuckets := b.MaxBuckets
+	if maxBuckets <= 0 {
+		maxBuckets = DefaultMaxBuckets
+	}
+	if b.Now != nil {
+		// Never request future data
+		if now := b.Now().UTC().Truncate(time.Second); end.After(now) {
+			end = now
+		}
+	}
+	if !end.After(start) {
+		return 0, nil
+	}
+
+	batchCount := 0
+	totalInserted := 0
+
+	var fetchRecursive func(start, end time.Time) error
+	fetchRecursive = func(start, end time.Time) error {
+		// Stop promptly when the caller (e.g. a daemon job) is cancelled.
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		// 1. Count how many gaps in this range are worth filling (i.e. not permanently skipped).
+		gapsToFill, err := b.store.CountGapsToFill(ctx, b.Exchange, product, start, end, int(secPerBucket))
+		if err != nil {
+			return fmt.Errorf("failed to count gaps to fill in range: %w", err)
+		}
+		if gapsToFill == 0 {
+			return nil // Range is fully populated or all gaps are permanent.
+		}
+
+		// 2. If the time window is small enough, handle it as a single batch.
+		windowSize := int(end.Sub(start).Seconds() / float64(secPerBucket))
+		// The window size must be strictly less than maxBuckets. If it's equal, an inclusive
+		// time range could contain maxBuckets + 1 candles, violating the API limit.
+		if windowSize < int(maxBuckets) {
+			batchCount++
+			b.emit(Event{Kind: EventBatch, Product: product, Batch: batchCount, Start: start, End: end, Gaps: gapsToFill})
+
+			// The source's `end` parameter is inclusive. To align with our exclusive `end`,
+			// we subtract one second from the end time.
+			candles, err := b.source.GetCandles(ctx, product, start, end.Add(-time.Second), granularity)
+			if err != nil {
+				return fmt.Errorf("candles batch error: %w", err)
+			}
+
+			inserted, err := b.store.InsertCandles(ctx, b.Exchange, product, int(secPerBucket), candles)
+			if err != nil {
+				return fmt.Errorf("insert candles: %w", err)
+			}
+			totalInserted += inserted
+			b.emit(Event{Kind: EventInserted, Product: product, Batch: batchCount, Start: start, End: end, Received: len(candles), Inserted: inserted})
+
+			// After inserting, find out which timestamps are still missing and mark them as gaps.
+			missing, err := b.store.GetMissingCandleTimestamps(ctx, b.Exchange, product, start, end, int(secPerBucket))
+			if err != nil {
+				return fmt.Errorf("failed to get missing timestamps post-fetch: %w", err)
+			}
+			b.markGaps(ctx, product, int(secPerBucket), missing)
+			return nil
+		}
+
+		// 3. If too many missing, split the range and recurse
+		mid := start.Add(end.Sub(start) / 2)
+		// Align mid to the granularity bucket
+		mid = mid.Truncate(time.Duration(secPerBucket) * time.Second)
+		if !mid.After(start) {
+			mid = start.Add(time.Duration(secPerBucket) * time.Second)
+		}
+
+		if err := fetchRecursive(start, mid); err != nil {
+			return err
+		}
+		return fetchRecursive(mid, end)
+	}
+
+	err = fetchRecursive(start, end)
+	return totalInserted, err
+}
+
+// markGaps records the remaining missing timestamps as gap ranges in batches. A failed batch
+// is reported per timestamp and does not stop the remaining batches.
+func (b *Backfiller) markGaps(ctx context.Context, product string, granularitySec int, missing []time.Time) {
+	size := b.GapBatchSize
+	if size <= 0 {
+		size = DefaultGapBatchSize
+	}
+	for len(missing) > 0 {
+		batch := missing[:min(size, len(missing))]
+		missing = missing[len(batch):]
+
+		kind := EventGapMarked
+		_, err := b.store.MarkGaps(ctx, b.Exchange, product, granularitySec, batch)
+		if err != nil {
+			kind = EventGapError
+		}
+		for _, t := range batch {
+			b.emit(Event{Kind: kind, Product: product, Time: t, Err: err})
+		}
+	}
+}
+
+func (b *Backfiller) emit(ev Event) {
+	if b.OnEvent == nil {
+		return
+	}
+	b.eventMu.Lock()
+	defer b.eventMu.Unlock()
+	b.OnEvent(ev)
+}
+
+// ParseGranularity maps a granularity such as 1m, 4h or 1d to seconds per bucket.
+func ParseGranularity(g string) (int64, error) {
+	switch strings.ToLower(g) {
+	case "1m":
+		return 60, nil
+	case "5m":
+		return 5 * 60, nil
+	case "15m":
+		return 15 * 60, nil
+	case "30m":
+		return 30 * 60, nil
+	case "1h":
+		return 60 * 60, nil
+	case "2h":
+		return 2 * 60 * 60, nil
+	case "4h":
+		return 4 * 60 * 60, nil
+	case "6h":
+		return 6 * 60 * 60, nil
+	case "1d":
+		return 24 * 60 * 60, nil
+	default:
+		return 0, fmt.Errorf("unknown granularity %q", g)
+	}
+}
@@ -0,0 +1,331 @@
+package backfill
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"cryptool/internal/exchange"
+)
+
+// fakeSource serves one candle per bucket except for the timestamps in holes.
+type fakeSource struct {
+	mu      sync.Mutex
+	holes   map[time.Time]bool
+	err     error
+	calls   int
+	maxSeen int
+	step    time.Duration
+	max     int64 // per-request limit; DefaultMaxBuckets when zero
+}
+
+func (f *fakeSource) Name() string { return "fake" }
+
+func (f *fakeSource) MaxCandlesPerRequest() int64 {
+	if f.max > 0 {
+		return f.max
+	}
+	return DefaultMaxBuckets
+}
+
+func (f *fakeSource) GetProducts(ctx context.Context) ([]exchange.Product, error) { return nil, nil }
+
+func (f *fakeSource) GetCandles(ctx context.Context, productID string, start, end time.Time, granularity string) ([]exchange.Candle, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	var out []exchange.Candle
+	for t := start; !t.After(end); t = t.Add(f.step) {
+		if f.holes[t] {
+			continue
+		}
+		out = append(out, exchange.Candle{Time: t, Open: decimal.NewFromInt(1), High: decimal.NewFromInt(2), Low: decimal.NewFromInt(1), Close: decimal.NewFromInt(2), Volume: decimal.NewFromInt(10)})
+	}
+	if len(out) > f.maxSeen {
+		f.maxSeen = len(out)
+	}
+	if int64(len(out)) > f.MaxCandlesPerRequest() {
+		return nil, errors.New("limit exceeded")
+	}
+	return out, nil
+}
+
+// fakeStore mimics the candles table semantics of ingest.Store in memory.
+type fakeStore struct {
+	mu        sync.Mutex
+	candles   map[time.Time]exchange.Candle
+	attempts  map[time.Time]int // gap marker attempts per bucket
+	markCalls int
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{candles: map[time.Time]exchange.Candle{}, attempts: map[time.Time]int{}}
+}
+
+func (s *fakeStore) missing(start, end time.Time, granularitySec int) []time.Time {
+	var out []time.Time
+	step := time.Duration(granularitySec) * time.Second
+	for t := start; t.Before(end); t = t.Add(step) {
+		if _, ok := s.candles[t]; !ok && s.attempts[t] < 5 {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+func (s *fakeStore) CountGapsToFill(ctx context.Context, exchange, product string, start, end time.Time, granularitySec int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.missing(start, end, granularitySec)), nil
+}
+
+func (s *fakeStore) GetMissingCandleTimestamps(ctx context.Context, exchange, product string, start, end time.Time, granularitySec int) ([]time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.missing(start, end, granularitySec), nil
+}
+
+func (s *fakeStore) InsertCandles(ctx context.Context, exchange, product string, granularitySec int, candles []exchange.Candle) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, c := range candles {
+		if _, ok := s.candles[c.Time]; ok {
+			continue
+		}
+		s.candles[c.Time] = c
+		n++
+	}
+	return n, nil
+}
+
+func (s *fakeStore) MarkGaps(ctx context.Context, x, product string, granularitySec int, times []time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.markCalls++
+	for _, t := range times {
+		s.attempts[t]++
+	}
+	return len(times), nil
+}
+
+var t0 = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func newTestBackfiller(src *fakeSource, store *fakeStore) *Backfiller {
+	b := New(src, store)
+	b.Now = func() time.Time { return t0.AddDate(1, 0, 0) }
+	return b
+}
+
+func TestFillInsertsAllCandles(t *testing.T) {
+	src := &fakeSource{step: time.Minute}
+	store := newFakeStore()
+	b := newTestBackfiller(src, store)
+
+	inserted, err := b.Fill(context.Background(), "BTC-USD", "1m", t0, t0.Add(100*time.Minute))
+	if err != nil {
+		t.Fatalf("Fill: %v", err)
+	}
+	if inserted != 100 {
+		t.Errorf("inserted = %d, want 100", inserted)
+	}
+	if src.calls != 1 {
+		t.Errorf("source calls = %d, want 1", src.calls)
+	}
+
+	// A second run over a complete range must not hit the source.
+	if _, err := b.Fill(context.Background(), "BTC-USD", "1m", t0, t0.Add(100*time.Minute)); err != nil {
+		t.Fatalf("second Fill: %v", err)
+	}
+	if src.calls != 1 {
+		t.Errorf("source calls after second run = %d, want 1", src.calls)
+	}
+}
+
+func TestFillSplitsLargeRanges(t *testing.T) {
+	src := &fakeSource{step: time.Minute}
+	store := newFakeStore()
+	b := newTestBackfiller(src, store)
+
+	inserted, err := b.Fill(context.Background(), "BTC-USD", "1m", t0, t0.Add(24*time.Hour))
+	if err != nil {
+		t.Fatalf("Fill: %v", err)
+	}
+	if inserted != 24*60 {
+		t.Errorf("inserted = %d, want %d", inserted, 24*60)
+	}
+	if src.maxSeen > DefaultMaxBuckets {
+		t.Errorf("largest batch = %d, want <= %d", src.maxSeen, DefaultMaxBuckets)
+	}
+	if src.calls < 24*60/DefaultMaxBuckets {
+		t.Errorf("source calls = %d, expected the range to be split", src.calls)
+	}
+}
+
+func TestFillRespectsMaxBuckets(t *testing.T) {
+	src := &fakeSource{step: time.Minute}
+	store := newFakeStore()
+	b := newTestBackfiller(src, store)
+	b.MaxBuckets = 50
+
+	if _, err := b.Fill(context.Background(), "BTC-USD", "1m", t0, t0.Add(6*time.Hour)); err != nil {
+		t.Fatalf("Fill: %v", err)
+	}
+	if src.maxSeen > 50 {
+		t.Errorf("largest batch = %d, want <= 50", src.maxSeen)
+	}
+}
+
+func TestFillUsesSourceLimit(t *testing.T) {
+	src := &fakeSource{step: time.Minute, max: 1000}
+	store := newFakeStore()
+	b := newTestBackfiller(src, store)
+	if b.MaxBuckets != 1000 {
+		t.Fatalf("MaxBuckets = %d, want the source limit 1000", b.MaxBuckets)
+	}
+
+	if _, err := b.Fill(context.Background(), "BTC-USDT", "1m", t0, t0.Add(24*time.Hour)); err != nil {
+		t.Fatalf("Fill: %v", err)
+	}
+	if src.maxSeen <= DefaultMaxBuckets || src.maxSeen > 1000 {
+		t.Errorf("largest batch = %d, want between %d and 1000", src.maxSeen, DefaultMaxBuckets)
+	}
+}
+
+func TestFillMarksGapsUntilGivenUp(t *testing.T) {
+	hole := t0.Add(10 * time.Minute)
+	src := &fakeSource{step: time.Minute, holes: map[time.Time]bool{hole: true}}
+	store := newFakeStore()
+	b := newTestBackfiller(src, store)
+
+	var marked []time.Time
+	b.OnEvent = func(ev Event) {
+		if ev.Kind == EventGapMarked {
+			marked = append(marked, ev.Time)
+		}
+	}
+
+	for i := 0; i < 7; i++ {
+		if _, err := b.Fill(context.Background(), "BTC-USD", "1m", t0, t0.Add(30*time.Minute)); err != nil {
+			t.Fatalf("Fill #%d: %v", i, err)
+		}
+	}
+	if store.attempts[hole] != 5 {
+		t.Errorf("gap attempts = %d, want 5", store.attempts[hole])
+	}
+	if len(marked) != 5 {
+		t.Errorf("gap marked events = %d, want 5", len(marked))
+	}
+	// Once the gap reaches the retry limit the range is skipped entirely.
+	if src.calls != 5 {
+		t.Errorf("source calls = %d, want 5", src.calls)
+	}
+}
+
+func TestFillMarksGapsInBatches(t *testing.T) {
+	holes := map[time.Time]bool{}
+	for i := 0; i < 7; i++ {
+		holes[t0.Add(time.Duration(i)*time.Minute)] = true
+	}
+	src := &fakeSource{step: time.Minute, holes: holes}
+	store := newFakeStore()
+	b := newTestBackfiller(src, store)
+	b.GapBatchSize = 3
+
+	var marked int
+	b.OnEvent = func(ev Event) {
+		if ev.Kind == EventGapMarked {
+			marked++
+		}
+	}
+	if _, err := b.Fill(context.Background(), "BTC-USD", "1m", t0, t0.Add(30*time.Minute)); err != nil {
+		t.Fatalf("Fill: %v", err)
+	}
+	// Seven holes in batches of three take three store calls, not one per hole.
+	if store.markCalls != 3 || marked != 7 {
+		t.Errorf("mark calls = %d, marked events = %d, want 3 and 7", store.markCalls, marked)
+	}
+}
+
+func TestFillReturnsSourceError(t *testing.T) {
+	src := &fakeSource{step: time.Minute, err: fmt.Errorf("coinbase http 401: %w", exchange.ErrUnauthorized)}
+	store := newFakeStore()
+	b := newTestBackfiller(src, store)
+
+	// The range spans several windows; the first failure stops the fill without marking gaps.
+	_, err := b.Fill(context.Background(), "BTC-USD", "1m", t0, t0.Add(24*time.Hour))
+	if !errors.Is(err, exchange.ErrUnauthorized) {
+		t.Fatalf("err = %v, want ErrUnauthorized", err)
+	}
+	if src.calls != 1 {
+		t.Errorf("source calls = %d, want 1", src.calls)
+	}
+	if len(store.candles) != 0 {
+		t.Errorf("%d buckets stored or marked after a failed request", len(store.candles))
+	}
+}
+
+func TestFillStopsOnCancel(t *testing.T) {
+	src := &fakeSource{step: time.Minute}
+	b := newTestBackfiller(src, newFakeStore())
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := b.Fill(ctx, "BTC-USD", "1m", t0, t0.Add(24*time.Hour))
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	if src.calls != 0 {
+		t.Errorf("source calls = %d, want 0", src.calls)
+	}
+}
+
+func TestFillClampsToNow(t *testing.T) {
+	src := &fakeSource{step: time.Minute}
+	b := New(src, newFakeStore())
+	b.Now = func() time.Time { return t0.Add(5 * time.Minute) }
+
+	inserted, err := b.Fill(context.Background(), "BTC-USD", "1m", t0, t0.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Fill: %v", err)
+	}
+	if inserted != 5 {
+		t.Errorf("inserted = %d, want 5", inserted)
+	}
+}
+
+func TestParseGranularity(t *testing.T) {
+	if sec, err := ParseGranularity("4H"); err != nil || sec != 4*3600 {
+		t.Errorf("ParseGranularity(4H) = %d, %v, want %d", sec, err, 4*3600)
+	}
+	if _, err := ParseGranularity("3h"); err == nil {
+		t.Error("ParseGranularity(3h) succeeded, want an error")
+	}
+}
+
+// hourlySource is a fakeSource that only serves 1h candles.
+type hourlySource struct{ *fakeSource }
+
+func (hourlySource) SupportsGranularity(granularity string) bool { return granularity == "1h" }
+
+func TestFillRejectsGranularity(t *testing.T) {
+	src := &fakeSource{step: time.Minute}
+	if _, err := newTestBackfiller(src, newFakeStore()).Fill(context.Background(), "BTC-USD", "3h", t0, t0.Add(time.Hour)); err == nil {
+		t.Error("Fill(3h) succeeded, want an unknown granularity error")
+	}
+	b := New(hourlySource{src}, newFakeStore())
+	if _, err := b.Fill(context.Background(), "BTC-USD", "1m", t0, t0.Add(time.Hour)); !errors.Is(err, exchange.ErrNotSupported) {
+		t.Errorf("Fill(1m) err = %v, want ErrNotSupported", err)
+	}
+	if src.calls != 0 {
+		t.Errorf("source calls = %d, want 0", src.calls)
+	}
+}
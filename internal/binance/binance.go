@@ -0,0 +1,355 @@
+// Package binance implements the exchange adapter for Binance's public klines and exchangeInfo
+// REST endpoints. Requests are throttled by request weight, as reported in Binance's
+// X-MBX-USED-WEIGHT-* response headers, rather than by a fixed request rate.
+package binance
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"cryptool/internal/config"
+	"cryptool/internal/exchange"
+	"cryptool/internal/ratelimit"
+
+	"github.com/shopspring/decimal"
+)
+
+// DefaultBaseURL is Binance's public REST API.
+const DefaultBaseURL = "https://api.binance.com"
+
+// MaxCandlesPerRequest is the largest klines limit Binance accepts.
+const MaxCandlesPerRequest = 1000
+
+// Request weights of the endpoints used, per Binance's API documentation.
+const (
+	klinesWeight       = 2
+	exchangeInfoWeight = 20
+)
+
+// defaultWeightLimits is the REQUEST_WEIGHT budget used until exchangeInfo reports the current one.
+var defaultWeightLimits = map[time.Duration]int{time.Minute: 6000}
+
+func init() {
+	exchange.Register("binance", func(cfg *config.Config) (exchange.Exchange, error) {
+		return NewClient(""), nil
+	})
+}
+
+// Client is a minimal Binance public API client that implements exchange.MarketData.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	maxRetries int
+	limiter    *weightLimiter
+}
+
+// NewClient returns a client for baseURL; an empty baseURL uses DefaultBaseURL.
+func NewClient(baseURL string) *Client {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	return &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		maxRetries: 3,
+		limiter:    newWeightLimiter(defaultWeightLimits),
+	}
+}
+
+func (c *Client) Name() string { return "binance" }
+
+func (c *Client) MaxCandlesPerRequest() int64 { return MaxCandlesPerRequest }
+
+// exchangeInfo is the subset of the exchangeInfo response the adapter uses.
+type exchangeInfo struct {
+	RateLimits []struct {
+		RateLimitType string `json:"rateLimitType"`
+		Interval      string `json:"interval"`
+		IntervalNum   int    `json:"intervalNum"`
+		Limit         int    `json:"limit"`
+	} `json:"rateLimits"`
+	Symbols []json.RawMessage `json:"symbols"`
+}
+
+type symbolInfo struct {
+	Symbol     string `json:"symbol"`
+	Status     string `json:"status"`
+	BaseAsset  string `json:"baseAsset"`
+	QuoteAsset string `json:"quoteAsset"`
+	Filters    []struct {
+		FilterType  string `json:"filterType"`
+		TickSize    string `json:"tickSize"`
+		MinQty      string `json:"minQty"`
+		MaxQty      string `json:"maxQty"`
+		StepSize    string `json:"stepSize"`
+		MinNotional string `json:"minNotional"`
+		MaxNotional string `json:"maxNotional"`
+	} `json:"filters"`
+}
+
+// GetProducts lists all symbols as BASE-QUOTE products. It also adopts the REQUEST_WEIGHT
+// limits exchangeInfo reports.
+func (c *Client) GetProducts(ctx context.Context) ([]exchange.Product, error) {
+	var info exchangeInfo
+	if err := c.get(ctx, "/api/v3/exchangeInfo", nil, exchangeInfoWeight, &info); err != nil {
+		return nil, err
+	}
+
+	limits := map[time.Duration]int{}
+	for _, rl := range info.RateLimits {
+		if rl.RateLimitType != "REQUEST_WEIGHT" || rl.Interval == "" || rl.Limit <= 0 {
+			continue
+		}
+		if interval, ok := parseInterval(strconv.Itoa(rl.IntervalNum) + rl.Interval[:1]); ok {
+			limits[interval] = rl.Limit
+		}
+	}
+	c.limiter.setLimits(limits)
+
+	products := make([]exchange.Product, 0, len(info.Symbols))
+	for _, data := range info.Symbols {
+		var s symbolInfo
+		if err := json.Unmarshal(data, &s); err != nil {
+			return nil, fmt.Errorf("decode symbol: %w", err)
+		}
+		p := exchange.Product{
+			ProductID:       s.BaseAsset + "-" + s.QuoteAsset,
+			BaseCurrency:    s.BaseAsset,
+			QuoteCurrency:   s.QuoteAsset,
+			Status:          strings.ToLower(s.Status),
+			TradingDisabled: s.Status != "TRADING",
+			Details:         data,
+		}
+		for _, f := range s.Filters {
+			switch f.FilterType {
+			case "PRICE_FILTER":
+				p.PriceIncrement = f.TickSize
+			case "LOT_SIZE":
+				p.BaseIncrement = f.StepSize
+				p.BaseMinSize = f.MinQty
+				p.BaseMaxSize = f.MaxQty
+			case "NOTIONAL", "MIN_NOTIONAL":
+				p.QuoteMinSize = f.MinNotional
+				p.QuoteMaxSize = f.MaxNotional
+			}
+		}
+		products = append(products, p)
+	}
+	return products, nil
+}
+
+// intervals maps granularities to Binance kline intervals.
+var intervals = map[string]string{
+	"1m":  "1m",
+	"5m":  "5m",
+	"15m": "15m",
+	"30m": "30m",
+	"1h":  "1h",
+	"2h":  "2h",
+	"4h":  "4h",
+	"6h":  "6h",
+	"1d":  "1d",
+}
+
+// SupportsGranularity reports whether Binance serves klines at granularity.
+func (c *Client) SupportsGranularity(granularity string) bool {
+	_, ok := intervals[strings.ToLower(granularity)]
+	return ok
+}
+
+// GetCandles returns the klines of product that open in [start, end].
+func (c *Client) GetCandles(ctx context.Context, productID string, start, end time.Time, granularity string) ([]exchange.Candle, error) {
+	interval, ok := intervals[strings.ToLower(granularity)]
+	if !ok {
+		return nil, fmt.Errorf("binance granularity %q: %w", granularity, exchange.ErrNotSupported)
+	}
+
+	q := url.Values{}
+	q.Set("symbol", Symbol(productID))
+	q.Set("interval", interval)
+	q.Set("startTime", strconv.FormatInt(start.UnixMilli(), 10))
+	q.Set("endTime", strconv.FormatInt(end.UnixMilli(), 10))
+	q.Set("limit", strconv.Itoa(MaxCandlesPerRequest))
+
+	// Each kline is [openTime, open, high, low, close, volume, closeTime, ...].
+	var rows [][]json.RawMessage
+	if err := c.get(ctx, "/api/v3/klines", q, klinesWeight, &rows); err != nil {
+		return nil, err
+	}
+	candles := make([]exchange.Candle, 0, len(rows))
+	for _, r := range rows {
+		if len(r) < 6 {
+			return nil, fmt.Errorf("short kline for %s: %d fields", productID, len(r))
+		}
+		var openMs int64
+		if err := json.Unmarshal(r[0], &openMs); err != nil {
+			return nil, fmt.Errorf("decode kline time for %s: %w", productID, err)
+		}
+		c := exchange.Candle{Time: time.UnixMilli(openMs).UTC()}
+		for i, dst := range []*decimal.Decimal{&c.Open, &c.High, &c.Low, &c.Close, &c.Volume} {
+			d, err := jsonDecimal(r[ohlcvFields[i]])
+			if err != nil {
+				return nil, fmt.Errorf("decode kline for %s at %s: %w", productID, c.Time.Format(time.RFC3339), err)
+			}
+			*dst = d
+		}
+		candles = append(candles, c)
+	}
+	return candles, nil
+}
+
+// HistoryStart implements exchange.HistoryStarter. exchangeInfo has no listing dates, so it
+// asks for the first kline of productID at granularity.
+func (c *Client) HistoryStart(ctx context.Context, productID, granularity string) (time.Time, error) {
+	interval, ok := intervals[strings.ToLower(granularity)]
+	if !ok {
+		return time.Time{}, fmt.Errorf("binance granularity %q: %w", granularity, exchange.ErrNotSupported)
+	}
+
+	q := url.Values{}
+	q.Set("symbol", Symbol(productID))
+	q.Set("interval", interval)
+	q.Set("startTime", "0")
+	q.Set("limit", "1")
+
+	var rows [][]json.RawMessage
+	if err := c.get(ctx, "/api/v3/klines", q, klinesWeight, &rows); err != nil {
+		return time.Time{}, err
+	}
+	if len(rows) == 0 || len(rows[0]) == 0 {
+		return time.Time{}, fmt.Errorf("binance has no klines for %s: %w", productID, exchange.ErrNotFound)
+	}
+	var openMs int64
+	if err := json.Unmarshal(rows[0][0], &openMs); err != nil {
+		return time.Time{}, fmt.Errorf("decode kline time for %s: %w", productID, err)
+	}
+	return time.UnixMilli(openMs).UTC(), nil
+}
+
+// Symbol converts a BASE-QUOTE product ID to a Binance symbol, e.g. BTC-USDT to BTCUSDT.
+func Symbol(productID string) string {
+	return strings.ToUpper(strings.ReplaceAll(productID, "-", ""))
+}
+
+// APIError is a non-2xx response from Binance. It matches exchange.ErrUnauthorized,
+// exchange.ErrRateLimited and exchange.ErrNotFound with errors.Is, so exchange-neutral code
+// can react to it.
+type APIError struct {
+	StatusCode int
+	Code       int    `json:"code"`
+	Msg        string `json:"msg"`
+	// Body is the raw response body, kept when it is not a Binance error object.
+	Body string `json:"-"`
+}
+
+// newAPIError builds the error of a failed response from its status and body.
+func newAPIError(status int, body []byte) *APIError {
+	e := &APIError{StatusCode: status}
+	if err := json.Unmarshal(body, e); err != nil || e.Msg == "" {
+		e.Body = strings.TrimSpace(string(body))
+	}
+	return e
+}
+
+func (e *APIError) Error() string {
+	msg := e.Body
+	if e.Msg != "" {
+		msg = fmt.Sprintf("%s (code %d)", e.Msg, e.Code)
+	}
+	return fmt.Sprintf("binance http %d: %s", e.StatusCode, msg)
+}
+
+// Binance error codes mapped to the exchange package's sentinels.
+const (
+	codeUnauthorized    = -1002
+	codeTooManyRequests = -1003
+	codeInvalidSymbol   = -1121
+	codeRejectedAPIKey  = -2015
+)
+
+// Is maps the error to the exchange package's sentinels.
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case exchange.ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden ||
+			e.Code == codeUnauthorized || e.Code == codeRejectedAPIKey
+	case exchange.ErrRateLimited:
+		// A 418 is an IP ban for repeatedly ignoring 429s.
+		return e.StatusCode == http.StatusTooManyRequests || e.StatusCode == http.StatusTeapot ||
+			e.Code == codeTooManyRequests
+	case exchange.ErrNotFound:
+		return e.StatusCode == http.StatusNotFound || e.Code == codeInvalidSymbol
+	}
+	return false
+}
+
+// get performs a public GET request of the given weight and decodes the JSON body into out.
+// A 429 blocks all requests for Retry-After and is retried; a 418 means the IP is banned and
+// is returned as an error.
+func (c *Client) get(ctx context.Context, path string, query url.Values, weight int, out interface{}) error {
+	u := c.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	for attempt := 0; ; attempt++ {
+		if err := c.limiter.wait(ctx, weight); err != nil {
+			return err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			return err
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("read %s: %w", path, err)
+		}
+		c.limiter.observe(resp.Header)
+
+		switch {
+		case resp.StatusCode == http.StatusTooManyRequests && attempt < c.maxRetries:
+			now := c.limiter.now()
+			wait := ratelimit.Delay(resp.Header, now)
+			if resp.Header.Get("Retry-After") == "" {
+				wait = time.Minute // Binance always sends one; back off hard if it did not
+			}
+			c.limiter.block(now.Add(wait))
+			continue
+		case resp.StatusCode == http.StatusTeapot:
+			now := c.limiter.now()
+			wait := ratelimit.Delay(resp.Header, now)
+			c.limiter.block(now.Add(wait))
+			return fmt.Errorf("binance %s: IP banned for %s: %w", path, wait, newAPIError(resp.StatusCode, body))
+		case resp.StatusCode < 200 || resp.StatusCode >= 300:
+			return newAPIError(resp.StatusCode, body)
+		}
+		if err := json.Unmarshal(body, out); err != nil {
+			return fmt.Errorf("decode %s: %w", path, err)
+		}
+		return nil
+	}
+}
+
+// ohlcvFields are the indexes of open, high, low, close and volume in a kline.
+var ohlcvFields = [5]int{1, 2, 3, 4, 5}
+
+// jsonDecimal parses a JSON string or number as a decimal.
+func jsonDecimal(raw json.RawMessage) (decimal.Decimal, error) {
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		s = string(raw)
+	}
+	return decimal.NewFromString(s)
+}
@@ -0,0 +1,177 @@
+package binance
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"cryptool/internal/exchange"
+)
+
+// fixtureServer serves testdata files by path with a used-weight header and records every query.
+func fixtureServer(t *testing.T, routes map[string]string, status int) (*httptest.Server, *[]url.Values) {
+	t.Helper()
+	var queries []url.Values
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name, ok := routes[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		queries = append(queries, r.URL.Query())
+		data, err := os.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			t.Errorf("read fixture %s: %v", name, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-MBX-USED-WEIGHT-1M", "42")
+		w.WriteHeader(status)
+		w.Write(data)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &queries
+}
+
+func TestGetProducts(t *testing.T) {
+	srv, _ := fixtureServer(t, map[string]string{"/api/v3/exchangeInfo": "exchange_info.json"}, http.StatusOK)
+	c := NewClient(srv.URL)
+	products, err := c.GetProducts(context.Background())
+	if err != nil {
+		t.Fatalf("GetProducts: %v", err)
+	}
+	if len(products) != 2 {
+		t.Fatalf("got %d products, want 2", len(products))
+	}
+
+	btc := products[0]
+	if btc.ProductID != "BTC-USDT" || btc.BaseCurrency != "BTC" || btc.QuoteCurrency != "USDT" || btc.TradingDisabled ||
+		btc.PriceIncrement != "0.01000000" || btc.BaseIncrement != "0.00001000" || btc.BaseMinSize != "0.00001000" ||
+		btc.QuoteMinSize != "5.00000000" {
+		t.Errorf("unexpected BTC-USDT product: %+v", btc)
+	}
+	if eth := products[1]; eth.ProductID != "ETH-BTC" || !eth.TradingDisabled || eth.Status != "break" {
+		t.Errorf("ETH-BTC should be trading disabled: %+v", eth)
+	}
+
+	// The used weight from the response header is adopted for the one-minute window.
+	if w := c.limiter.windows[time.Minute]; w.used != 42 || w.limit != 6000 {
+		t.Errorf("minute window = %+v, want used 42 of 6000", *w)
+	}
+}
+
+func TestGetCandles(t *testing.T) {
+	srv, queries := fixtureServer(t, map[string]string{"/api/v3/klines": "klines_btcusdt_1h.json"}, http.StatusOK)
+	c := NewClient(srv.URL)
+
+	start := time.Unix(1704067200, 0).UTC()
+	end := start.Add(time.Hour)
+	candles, err := c.GetCandles(context.Background(), "BTC-USDT", start, end, "1h")
+	if err != nil {
+		t.Fatalf("GetCandles: %v", err)
+	}
+	if len(candles) != 2 {
+		t.Fatalf("got %d candles, want 2", len(candles))
+	}
+	first := candles[0]
+	if !first.Time.Equal(start) || first.Open.String() != "42283.58" || first.High.String() != "42554.57" || first.Low.String() != "42261.02" ||
+		first.Close.String() != "42475.23" || first.Volume.String() != "1271.68108" {
+		t.Errorf("unexpected first candle: %+v", first)
+	}
+
+	q := (*queries)[0]
+	if q.Get("symbol") != "BTCUSDT" || q.Get("interval") != "1h" || q.Get("startTime") != "1704067200000" ||
+		q.Get("endTime") != "1704070800000" || q.Get("limit") != "1000" {
+		t.Errorf("unexpected klines query: %v", q)
+	}
+}
+
+func TestGetCandlesErrors(t *testing.T) {
+	srv, _ := fixtureServer(t, map[string]string{"/api/v3/klines": "error_invalid_symbol.json"}, http.StatusBadRequest)
+	c := NewClient(srv.URL)
+	ctx := context.Background()
+	now := time.Now()
+
+	if _, err := c.GetCandles(ctx, "BTC-USDT", now.Add(-time.Hour), now, "3d"); !errors.Is(err, exchange.ErrNotSupported) {
+		t.Errorf("3d granularity: err = %v, want ErrNotSupported", err)
+	}
+	_, err := c.GetCandles(ctx, "FOO-BAR", now.Add(-time.Hour), now, "1h")
+	if err == nil || !strings.Contains(err.Error(), "Invalid symbol. (code -1121)") {
+		t.Errorf("API error: err = %v", err)
+	}
+	if !errors.Is(err, exchange.ErrNotFound) {
+		t.Errorf("invalid symbol: err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestErrorSentinels(t *testing.T) {
+	for _, tc := range []struct {
+		status int
+		body   string
+		want   error
+	}{
+		{http.StatusUnauthorized, `{"code":-2015,"msg":"Invalid API-key, IP, or permissions for action."}`, exchange.ErrUnauthorized},
+		{http.StatusTooManyRequests, `{"code":-1003,"msg":"Too many requests."}`, exchange.ErrRateLimited},
+		{http.StatusNotFound, `not found`, exchange.ErrNotFound},
+	} {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Retry-After", "0")
+			http.Error(w, tc.body, tc.status)
+		}))
+		c := NewClient(srv.URL)
+		c.maxRetries = 0
+		now := time.Now()
+		_, err := c.GetCandles(context.Background(), "BTC-USDT", now.Add(-time.Hour), now, "1h")
+		srv.Close()
+		if !errors.Is(err, tc.want) {
+			t.Errorf("http %d: err = %v, want %v", tc.status, err, tc.want)
+		}
+	}
+}
+
+func TestHistoryStart(t *testing.T) {
+	srv, queries := fixtureServer(t, map[string]string{"/api/v3/klines": "klines_btcusdt_1h.json"}, http.StatusOK)
+	start, err := NewClient(srv.URL).HistoryStart(context.Background(), "BTC-USDT", "1h")
+	if err != nil {
+		t.Fatalf("HistoryStart: %v", err)
+	}
+	if want := time.Unix(1704067200, 0).UTC(); !start.Equal(want) {
+		t.Errorf("start = %s, want the first kline at %s", start, want)
+	}
+	q := (*queries)[0]
+	if q.Get("symbol") != "BTCUSDT" || q.Get("startTime") != "0" || q.Get("limit") != "1" {
+		t.Errorf("unexpected klines query: %v", q)
+	}
+}
+
+func TestRetriesAfterTooManyRequests(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.Header().Set("Retry-After", "0")
+			http.Error(w, `{"code":-1003,"msg":"Too many requests."}`, http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte("[]"))
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL)
+	now := time.Now()
+	candles, err := c.GetCandles(context.Background(), "BTC-USDT", now.Add(-time.Hour), now, "1m")
+	if err != nil {
+		t.Fatalf("GetCandles: %v", err)
+	}
+	if calls != 2 || len(candles) != 0 {
+		t.Errorf("calls = %d, candles = %d; want a retry and no candles", calls, len(candles))
+	}
+}
@@ -0,0 +1,168 @@
+package binance
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cryptool/internal/ratelimit"
+)
+
+// usedWeightPrefix is the canonical form of Binance's X-MBX-USED-WEIGHT-<n><unit> headers.
+const usedWeightPrefix = "X-Mbx-Used-Weight-"
+
+// weightLimiter tracks request weight per fixed window, the way Binance accounts it, and
+// blocks callers until a request of a given weight fits every window. Server headers are
+// authoritative: the used weight they report replaces the local estimate.
+type weightLimiter struct {
+	mu           sync.Mutex
+	windows      map[time.Duration]*weightWindow
+	blockedUntil time.Time
+
+	now   func() time.Time
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+type weightWindow struct {
+	limit int
+	used  int
+	start time.Time
+}
+
+// newWeightLimiter returns a limiter with one window per interval in limits.
+func newWeightLimiter(limits map[time.Duration]int) *weightLimiter {
+	l := &weightLimiter{
+		windows: map[time.Duration]*weightWindow{},
+		now:     time.Now,
+		sleep:   ratelimit.Sleep,
+	}
+	l.setLimits(limits)
+	return l
+}
+
+// setLimits replaces the window limits, keeping the weight already used in known windows.
+func (l *weightLimiter) setLimits(limits map[time.Duration]int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for interval, limit := range limits {
+		if w, ok := l.windows[interval]; ok {
+			w.limit = limit
+			continue
+		}
+		l.windows[interval] = &weightWindow{limit: limit}
+	}
+}
+
+// wait blocks until weight fits every window, then reserves it.
+func (l *weightLimiter) wait(ctx context.Context, weight int) error {
+	for {
+		d := l.reserve(weight)
+		if d <= 0 {
+			return nil
+		}
+		if err := l.sleep(ctx, d); err != nil {
+			return err
+		}
+	}
+}
+
+// reserve reserves weight and returns 0, or returns how long to wait before trying again.
+func (l *weightLimiter) reserve(weight int) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	if now.Before(l.blockedUntil) {
+		return l.blockedUntil.Sub(now)
+	}
+	var wait time.Duration
+	for interval, w := range l.windows {
+		w.roll(interval, now)
+		// A request heavier than the whole limit can never fit; let it through on an empty window.
+		if w.used > 0 && w.used+weight > w.limit {
+			if d := w.start.Add(interval).Sub(now); d > wait {
+				wait = d
+			}
+		}
+	}
+	if wait > 0 {
+		return wait
+	}
+	for _, w := range l.windows {
+		w.used += weight
+	}
+	return 0
+}
+
+// observe records the used weight reported in the response headers.
+func (l *weightLimiter) observe(h http.Header) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	for key, values := range h {
+		if !strings.HasPrefix(key, usedWeightPrefix) || len(values) == 0 {
+			continue
+		}
+		interval, ok := parseInterval(strings.TrimPrefix(key, usedWeightPrefix))
+		if !ok {
+			continue
+		}
+		used, err := strconv.Atoi(values[0])
+		if err != nil {
+			continue
+		}
+		w, ok := l.windows[interval]
+		if !ok {
+			// Windows without a known limit are not tracked until exchangeInfo reports one.
+			continue
+		}
+		w.roll(interval, now)
+		w.used = used
+	}
+}
+
+// block stops all requests until t, e.g. after a 429 with Retry-After.
+func (l *weightLimiter) block(t time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if t.After(l.blockedUntil) {
+		l.blockedUntil = t
+	}
+}
+
+// roll starts a new window when now is past the current one. Binance windows are
+// aligned to the interval, e.g. every minute on the minute.
+func (w *weightWindow) roll(interval time.Duration, now time.Time) {
+	start := now.Truncate(interval)
+	if start.After(w.start) {
+		w.start = start
+		w.used = 0
+	}
+}
+
+// parseInterval parses a header interval suffix such as 1m, 10s or 1d.
+func parseInterval(s string) (time.Duration, bool) {
+	if len(s) < 2 {
+		return 0, false
+	}
+	n, err := strconv.Atoi(s[:len(s)-1])
+	if err != nil || n <= 0 {
+		return 0, false
+	}
+	var unit time.Duration
+	switch strings.ToLower(s[len(s)-1:]) {
+	case "s":
+		unit = time.Second
+	case "m":
+		unit = time.Minute
+	case "h":
+		unit = time.Hour
+	case "d":
+		unit = 24 * time.Hour
+	default:
+		return 0, false
+	}
+	return time.Duration(n) * unit, true
+}
@@ -0,0 +1,94 @@
+package binance
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// fakeClock drives a weightLimiter: sleeping advances the clock instead of blocking.
+type fakeClock struct {
+	now   time.Time
+	slept []time.Duration
+}
+
+func newTestLimiter(limit int) (*weightLimiter, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 12, 0, 30, 0, time.UTC)}
+	l := newWeightLimiter(map[time.Duration]int{time.Minute: limit})
+	l.now = func() time.Time { return clock.now }
+	l.sleep = func(ctx context.Context, d time.Duration) error {
+		clock.slept = append(clock.slept, d)
+		clock.now = clock.now.Add(d)
+		return nil
+	}
+	return l, clock
+}
+
+func TestWeightLimiterWaitsForNextWindow(t *testing.T) {
+	l, clock := newTestLimiter(10)
+	ctx := context.Background()
+
+	for i := 0; i < 5; i++ {
+		if err := l.wait(ctx, 2); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(clock.slept) != 0 {
+		t.Fatalf("slept %v within budget", clock.slept)
+	}
+
+	// The sixth request exceeds the budget and waits until the window rolls over on the minute.
+	if err := l.wait(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+	if len(clock.slept) != 1 || clock.slept[0] != 30*time.Second {
+		t.Errorf("slept %v, want [30s]", clock.slept)
+	}
+	if used := l.windows[time.Minute].used; used != 2 {
+		t.Errorf("used = %d after rollover, want 2", used)
+	}
+}
+
+func TestWeightLimiterObservesHeaders(t *testing.T) {
+	l, clock := newTestLimiter(100)
+
+	// The server reports more weight than was reserved locally, e.g. from another process.
+	h := http.Header{}
+	h.Set("X-MBX-USED-WEIGHT-1M", "99")
+	h.Set("X-MBX-USED-WEIGHT-1H", "500") // no known limit, ignored
+	l.observe(h)
+
+	if err := l.wait(context.Background(), 2); err != nil {
+		t.Fatal(err)
+	}
+	if len(clock.slept) != 1 || clock.slept[0] != 30*time.Second {
+		t.Errorf("slept %v, want [30s]", clock.slept)
+	}
+}
+
+func TestWeightLimiterBlock(t *testing.T) {
+	l, clock := newTestLimiter(100)
+	l.block(clock.now.Add(5 * time.Second))
+
+	if err := l.wait(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+	if len(clock.slept) != 1 || clock.slept[0] != 5*time.Second {
+		t.Errorf("slept %v, want [5s]", clock.slept)
+	}
+}
+
+func TestParseInterval(t *testing.T) {
+	cases := map[string]time.Duration{"1m": time.Minute, "10S": 10 * time.Second, "1h": time.Hour, "1D": 24 * time.Hour}
+	for in, want := range cases {
+		if got, ok := parseInterval(in); !ok || got != want {
+			t.Errorf("parseInterval(%q) = %v, %v; want %v", in, got, ok, want)
+		}
+	}
+	for _, in := range []string{"", "m", "0m", "1x"} {
+		if _, ok := parseInterval(in); ok {
+			t.Errorf("parseInterval(%q) should fail", in)
+		}
+	}
+}
@@ -0,0 +1,290 @@
+// Package cassette records HTTP interactions to files and replays them, so a client run can be
+// reproduced exactly without network access. A cassette is a directory holding one JSON file per
+// interaction, numbered in the order the requests were made. Credentials are never written.
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Redacted replaces the value of every sensitive header in a cassette.
+const Redacted = "REDACTED"
+
+// sensitiveHeaders are redacted when recording. Names are canonical.
+var sensitiveHeaders = map[string]bool{
+	"Authorization":        true,
+	"Cb-Access-Key":        true,
+	"Cb-Access-Sign":       true,
+	"Cb-Access-Passphrase": true,
+	"Cookie":               true,
+	"Set-Cookie":           true,
+}
+
+// Interaction is one recorded request and its response.
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Request is the recorded part of an http.Request.
+type Request struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+}
+
+// Response is the recorded part of an http.Response.
+type Response struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body"`
+}
+
+// Recorder is an http.RoundTripper that forwards requests to Transport and writes every
+// interaction to Dir.
+type Recorder struct {
+	Dir       string
+	Transport http.RoundTripper
+
+	mu sync.Mutex
+	n  int
+}
+
+// NewRecorder returns a Recorder writing to dir, which is created if needed. A nil transport
+// uses http.DefaultTransport.
+func NewRecorder(dir string, transport http.RoundTripper) (*Recorder, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create cassette dir: %w", err)
+	}
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	// Continue numbering after existing interactions so a cassette can span several runs.
+	files, err := interactionFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	n := 0
+	if len(files) > 0 {
+		n = sequence(files[len(files)-1])
+	}
+	return &Recorder{Dir: dir, Transport: transport, n: n}, nil
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := readBody(&req.Body)
+	if err != nil {
+		return nil, fmt.Errorf("cassette: read request body: %w", err)
+	}
+	resp, err := r.Transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := readBody(&resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("cassette: read response body: %w", err)
+	}
+
+	in := Interaction{
+		Request: Request{
+			Method: req.Method,
+			URL:    req.URL.String(),
+			Header: redact(req.Header),
+			Body:   reqBody,
+		},
+		Response: Response{
+			StatusCode: resp.StatusCode,
+			Header:     redact(resp.Header),
+			Body:       respBody,
+		},
+	}
+	if err := r.write(in); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (r *Recorder) write(in Interaction) error {
+	data, err := json.MarshalIndent(in, "", "  ")
+	if err != nil {
+		return fmt.Errorf("cassette: encode interaction: %w", err)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.n++
+	name := fmt.Sprintf("%04d-%s-%s.json", r.n, in.Request.Method, slug(in.Request.URL))
+	if err := os.WriteFile(filepath.Join(r.Dir, name), append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("cassette: write %s: %w", name, err)
+	}
+	return nil
+}
+
+// Player is an http.RoundTripper that answers requests from a recorded cassette. A request
+// matches an interaction with the same method, path, query and body; the host is ignored so
+// cassettes recorded against one base URL replay against any other. Identical requests, e.g.
+// retries, are answered in recorded order.
+type Player struct {
+	mu           sync.Mutex
+	interactions []Interaction
+	used         []bool
+}
+
+// Load reads the cassette in dir.
+func Load(dir string) (*Player, error) {
+	files, err := interactionFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("cassette %s has no interactions", dir)
+	}
+	p := &Player{}
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("cassette: %w", err)
+		}
+		var in Interaction
+		if err := json.Unmarshal(data, &in); err != nil {
+			return nil, fmt.Errorf("cassette: decode %s: %w", filepath.Base(f), err)
+		}
+		p.interactions = append(p.interactions, in)
+	}
+	p.used = make([]bool, len(p.interactions))
+	return p, nil
+}
+
+func (p *Player) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readBody(&req.Body)
+	if err != nil {
+		return nil, fmt.Errorf("cassette: read request body: %w", err)
+	}
+	key := matchKey(req.Method, req.URL.RequestURI(), body)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, in := range p.interactions {
+		if p.used[i] || matchKey(in.Request.Method, requestURI(in.Request.URL), in.Request.Body) != key {
+			continue
+		}
+		p.used[i] = true
+		header := in.Response.Header.Clone()
+		if header == nil {
+			header = http.Header{}
+		}
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", in.Response.StatusCode, http.StatusText(in.Response.StatusCode)),
+			StatusCode:    in.Response.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          io.NopCloser(strings.NewReader(in.Response.Body)),
+			ContentLength: int64(len(in.Response.Body)),
+			Request:       req,
+		}, nil
+	}
+	return nil, fmt.Errorf("cassette: no recorded response for %s %s", req.Method, req.URL.RequestURI())
+}
+
+// Remaining returns the number of recorded interactions not replayed yet.
+func (p *Player) Remaining() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	n := 0
+	for _, u := range p.used {
+		if !u {
+			n++
+		}
+	}
+	return n
+}
+
+func matchKey(method, uri, body string) string {
+	return method + " " + uri + "\n" + body
+}
+
+// requestURI returns the path and query of a recorded URL.
+func requestURI(raw string) string {
+	if i := strings.Index(raw, "://"); i >= 0 {
+		raw = raw[i+3:]
+		if j := strings.IndexByte(raw, '/'); j >= 0 {
+			return raw[j:]
+		}
+		return "/"
+	}
+	return raw
+}
+
+// readBody reads *body and replaces it with a fresh reader over the same bytes.
+func readBody(body *io.ReadCloser) (string, error) {
+	if *body == nil || *body == http.NoBody {
+		return "", nil
+	}
+	data, err := io.ReadAll(*body)
+	(*body).Close()
+	if err != nil {
+		return "", err
+	}
+	*body = io.NopCloser(bytes.NewReader(data))
+	return string(data), nil
+}
+
+// redact returns a copy of h with sensitive values replaced.
+func redact(h http.Header) http.Header {
+	out := h.Clone()
+	for k := range out {
+		if sensitiveHeaders[http.CanonicalHeaderKey(k)] {
+			out[k] = []string{Redacted}
+		}
+	}
+	return out
+}
+
+// slug turns a URL path into a short file name component.
+func slug(rawURL string) string {
+	path := requestURI(rawURL)
+	if i := strings.IndexByte(path, '?'); i >= 0 {
+		path = path[:i]
+	}
+	path = strings.TrimPrefix(path, "/api/v3/brokerage/")
+	s := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-':
+			return r
+		}
+		return '_'
+	}, strings.Trim(path, "/"))
+	if len(s) > 60 {
+		s = s[:60]
+	}
+	return s
+}
+
+// interactionFiles returns the interaction files in dir in recorded order.
+func interactionFiles(dir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("cassette: %w", err)
+	}
+	// Sort by sequence number rather than by name, so 10000 follows 9999.
+	sort.SliceStable(files, func(i, j int) bool { return sequence(files[i]) < sequence(files[j]) })
+	return files, nil
+}
+
+// sequence returns the number an interaction file name starts with.
+func sequence(file string) int {
+	n, _ := strconv.Atoi(strings.SplitN(filepath.Base(file), "-", 2)[0])
+	return n
+}
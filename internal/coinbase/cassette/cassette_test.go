@@ -0,0 +1,109 @@
+package cassette
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"call":` + string(rune('0'+calls)) + `,"echo":"` + string(body) + `"}`))
+	}))
+
+	dir := t.TempDir()
+	rec, err := NewRecorder(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: rec}
+
+	get := func(c *http.Client, base string) string {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, base+"/api/v3/brokerage/accounts?limit=250", nil)
+		req.Header.Set("Authorization", "Bearer secret-token")
+		resp, err := c.Do(req)
+		if err != nil {
+			t.Fatalf("GET: %v", err)
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		return string(b)
+	}
+	post := func(c *http.Client, base string) string {
+		t.Helper()
+		resp, err := c.Post(base+"/api/v3/brokerage/orders", "application/json", strings.NewReader("order"))
+		if err != nil {
+			t.Fatalf("POST: %v", err)
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		return string(b)
+	}
+
+	recorded := []string{get(client, srv.URL), get(client, srv.URL), post(client, srv.URL)}
+	srv.Close()
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(files) != 3 {
+		t.Fatalf("recorded %d files, want 3", len(files))
+	}
+	first, _ := os.ReadFile(files[0])
+	if strings.Contains(string(first), "secret-token") || !strings.Contains(string(first), Redacted) {
+		t.Errorf("authorization header not redacted:\n%s", first)
+	}
+	if base := filepath.Base(files[0]); base != "0001-GET-accounts.json" {
+		t.Errorf("file name = %s", base)
+	}
+
+	player, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client = &http.Client{Transport: player}
+	// Replay ignores the host and answers identical requests in recorded order.
+	replayed := []string{get(client, "http://replay.invalid"), get(client, "http://replay.invalid"), post(client, "http://replay.invalid")}
+	for i := range recorded {
+		if replayed[i] != recorded[i] {
+			t.Errorf("interaction %d replayed %q, recorded %q", i, replayed[i], recorded[i])
+		}
+	}
+	if player.Remaining() != 0 {
+		t.Errorf("remaining = %d, want 0", player.Remaining())
+	}
+	if _, err := client.Get("http://replay.invalid/api/v3/brokerage/products"); err == nil || !strings.Contains(err.Error(), "no recorded response") {
+		t.Errorf("unrecorded request: err = %v", err)
+	}
+}
+
+func TestRecorderContinuesNumbering(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"10-GET-b.json", "9-GET-a.json"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("{}"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rec, err := NewRecorder(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.n != 10 {
+		t.Errorf("n = %d, want numbering to continue after 10", rec.n)
+	}
+
+	files, err := interactionFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Base(files[0]) != "9-GET-a.json" {
+		t.Errorf("files not in sequence order: %v", files)
+	}
+}
@@ -18,14 +18,17 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	"gopkg.in/go-jose/go-jose.v2"
 	"gopkg.in/go-jose/go-jose.v2/jwt"
+
+	"cryptool/internal/exchange"
+	"cryptool/internal/ratelimit"
 )
 
-const baseURL = "https://api.coinbase.com"
+// DefaultBaseURL is the Coinbase Advanced Trade REST API.
+const DefaultBaseURL = "https://api.coinbase.com"
 
 // Client minimal Advanced Trade API client
 
@@ -33,17 +36,19 @@ type Client struct {
 	apiKey        string
 	apiSecret     string
 	passphrase    string
+	baseURL       string
+	userAgent     string
 	httpClient    *http.Client
 	jwtKeyName    string
 	jwtPrivateKey *ecdsa.PrivateKey
 	verbose       bool
-	// rate limiting and retry
-	rpm         int
-	interval    time.Duration
-	mu          sync.Mutex
-	lastReqAt   time.Time
+	// rate limiting and retry; nil limiters leave requests unpaced
+	public      ratelimit.Limiter
+	private     ratelimit.Limiter
 	maxRetries  int
 	backoffBase time.Duration
+	// closer releases what the client owns, such as the pool behind shared rate limits.
+	closer io.Closer
 }
 
 // GetCandlesOnce fetches candles for a single sub-range [start,end] with an optional limit (max 350 per API docs).
@@ -63,9 +68,7 @@ func (c *Client) GetCandlesOnce(ctx context.Context, productID string, start, en
         return nil, err
     }
     if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-        b, _ := io.ReadAll(resp.Body)
-        resp.Body.Close()
-        return nil, fmt.Errorf("coinbase http %d: %s", resp.StatusCode, string(b))
+        return nil, newAPIError(resp)
     }
     var payload struct {
         Candles []struct {
@@ -87,15 +90,15 @@ func (c *Client) GetCandlesOnce(ctx context.Context, productID string, start, en
     out := make([]Candle, 0, len(payload.Candles))
     for i := len(payload.Candles) - 1; i >= 0; i-- {
         cnd := payload.Candles[i]
-        ts := parseStartTime(cnd.Start)
-        out = append(out, Candle{
-            Time:   ts,
-            Open:   parseFloat(cnd.Open),
-            High:   parseFloat(cnd.High),
-            Low:    parseFloat(cnd.Low),
-            Close:  parseFloat(cnd.Close),
-            Volume: parseFloat(cnd.Volume),
-        })
+        start, err := parseStartTime(cnd.Start)
+        if err != nil {
+            return nil, fmt.Errorf("decode %s candles: %w", productID, err)
+        }
+        c, err := exchange.ParseCandle(start, cnd.Open, cnd.High, cnd.Low, cnd.Close, cnd.Volume)
+        if err != nil {
+            return nil, fmt.Errorf("decode %s candles: %w", productID, err)
+        }
+        out = append(out, c)
     }
     return out, nil
 }
@@ -114,9 +117,7 @@ func (c *Client) GetProducts(ctx context.Context) ([]Product, error) {
 	}
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		b, _ := io.ReadAll(resp.Body)
-		resp.Body.Close()
-		return nil, fmt.Errorf("coinbase http %d: %s", resp.StatusCode, string(b))
+		return nil, newAPIError(resp)
 	}
 
 	var payload ListProductsResponse
@@ -143,9 +144,7 @@ func (c *Client) ListAccounts(ctx context.Context) ([]Account, error) {
 		}
 
 		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-			b, _ := io.ReadAll(resp.Body)
-			resp.Body.Close()
-			return nil, fmt.Errorf("coinbase http %d: %s", resp.StatusCode, string(b))
+			return nil, newAPIError(resp)
 		}
 
 		var payload ListAccountsResponse
@@ -167,8 +166,58 @@ func (c *Client) ListAccounts(ctx context.Context) ([]Account, error) {
 	return allAccounts, nil
 }
 
+// ListFills returns all fills matching params, following the cursor across pages.
+func (c *Client) ListFills(ctx context.Context, params ListFillsParams) ([]Fill, error) {
+	var allFills []Fill
+	path := "/api/v3/brokerage/orders/historical/fills"
+	q := url.Values{}
+	q.Set("limit", "1000")
+	for _, id := range params.OrderIDs {
+		q.Add("order_ids", id)
+	}
+	for _, p := range params.ProductIDs {
+		q.Add("product_ids", p)
+	}
+	if !params.Start.IsZero() {
+		q.Set("start_sequence_timestamp", params.Start.UTC().Format(time.RFC3339Nano))
+	}
+	if !params.End.IsZero() {
+		q.Set("end_sequence_timestamp", params.End.UTC().Format(time.RFC3339Nano))
+	}
+
+	for {
+		resp, err := c.do(ctx, http.MethodGet, path, q, "")
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return nil, newAPIError(resp)
+		}
+
+		var payload ListFillsResponse
+		dec := json.NewDecoder(resp.Body)
+		if err := dec.Decode(&payload); err != nil {
+			resp.Body.Close()
+			return nil, fmt.Errorf("decode fills: %w", err)
+		}
+		resp.Body.Close()
+
+		allFills = append(allFills, payload.Fills...)
+
+		if payload.Cursor == "" || len(payload.Fills) == 0 {
+			break
+		}
+		q.Set("cursor", payload.Cursor)
+	}
+
+	return allFills, nil
+}
+
 // Configure sets rate limiting and retry/backoff settings.
-// rpm <= 0 disables rate limiting. maxRetries defaults to 3 when <= 0. backoffMs defaults to 500 when <= 0.
+// rpm > 0 paces all requests at a fixed rate with no burst, replacing any limiters; rpm <= 0
+// keeps the limiters set with WithLimiters or SetLimiters. maxRetries defaults to 3 when <= 0.
+// backoffMs defaults to 500 when <= 0.
 func (c *Client) Configure(rpm, maxRetries, backoffMs int, verbose bool) {
 	if maxRetries <= 0 {
 		maxRetries = 3
@@ -178,34 +227,36 @@ func (c *Client) Configure(rpm, maxRetries, backoffMs int, verbose bool) {
 	}
 	c.maxRetries = maxRetries
 	c.backoffBase = time.Duration(backoffMs) * time.Millisecond
-	c.rpm = rpm
 	if rpm > 0 {
-		// minimum interval between requests
-		c.interval = time.Minute / time.Duration(rpm)
-	} else {
-		c.interval = 0
+		b := ratelimit.NewBucket(rpm, 1)
+		c.public, c.private = b, b
 	}
 	c.verbose = verbose
 }
 
-func (c *Client) beforeRequest() {
-	if c.interval <= 0 {
-		return
-	}
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	now := time.Now()
-	if c.lastReqAt.IsZero() {
-		c.lastReqAt = now
-		return
+// SetLimiters sets the budgets for public market data and for authenticated endpoints.
+// Either may be nil to leave those requests unpaced, and both may be the same limiter.
+func (c *Client) SetLimiters(public, private ratelimit.Limiter) {
+	c.public, c.private = public, private
+}
+
+// Close releases the resources the client owns, such as the database pool behind shared
+// rate limits. It is safe to call on any client, and more than once.
+func (c *Client) Close() error {
+	if c.closer == nil {
+		return nil
 	}
-	elapsed := now.Sub(c.lastReqAt)
-	if elapsed < c.interval {
-		time.Sleep(c.interval - elapsed)
-		c.lastReqAt = time.Now()
-	} else {
-		c.lastReqAt = now
+	err := c.closer.Close()
+	c.closer = nil
+	return err
+}
+
+// limiter returns the budget req is charged against.
+func (c *Client) limiter(req *http.Request) ratelimit.Limiter {
+	if strings.Contains(req.URL.Path, "/brokerage/market/") {
+		return c.public
 	}
+	return c.private
 }
 
 func (c *Client) doRequest(req *http.Request) (*http.Response, error) {
@@ -214,19 +265,42 @@ func (c *Client) doRequest(req *http.Request) (*http.Response, error) {
 	if attempts <= 0 {
 		attempts = 3
 	}
+	ctx := req.Context()
+	lim := c.limiter(req)
 	var resp *http.Response
 	var err error
 	for i := 0; i < attempts; i++ {
-		c.beforeRequest()
+		if i > 0 && req.GetBody != nil {
+			// The previous attempt consumed the body; rewind it so POSTs are retried intact.
+			if req.Body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+		if lim != nil {
+			if err := lim.Wait(ctx); err != nil {
+				return nil, err
+			}
+		}
 		resp, err = c.httpClient.Do(req)
 		if err != nil {
 			// network error -> backoff and retry
-			if i < attempts-1 {
-				c.sleepBackoff(i)
+			if i < attempts-1 && ctx.Err() == nil {
+				if err := c.sleepBackoff(ctx, i); err != nil {
+					return nil, err
+				}
 				continue
 			}
 			return nil, err
 		}
+		// Honour Retry-After and exhausted rate-limit headers on any response, so every
+		// request sharing the budget holds off, not just this one.
+		delay := ratelimit.Delay(resp.Header, time.Now())
+		if delay > 0 && lim != nil {
+			if err := lim.Backoff(ctx, time.Now().Add(delay)); err != nil {
+				resp.Body.Close()
+				return nil, err
+			}
+		}
 		// If success or non-retriable
 		if resp.StatusCode < 500 && resp.StatusCode != 429 {
 			if resp.StatusCode == http.StatusUnauthorized && c.verbose {
@@ -242,7 +316,17 @@ func (c *Client) doRequest(req *http.Request) (*http.Response, error) {
 			// Drain and close body before retry to avoid leaks
 			io.Copy(io.Discard, resp.Body)
 			resp.Body.Close()
-			c.sleepBackoff(i)
+			switch {
+			case delay > 0 && lim != nil:
+				// The limiter already waits out the server's delay.
+			case delay > 0:
+				err = ratelimit.Sleep(ctx, delay)
+			default:
+				err = c.sleepBackoff(ctx, i)
+			}
+			if err != nil {
+				return nil, err
+			}
 			continue
 		}
 		// Out of retries
@@ -252,7 +336,8 @@ func (c *Client) doRequest(req *http.Request) (*http.Response, error) {
 	return resp, err
 }
 
-func (c *Client) sleepBackoff(attempt int) {
+// sleepBackoff waits out the exponential backoff for attempt, or until ctx is done.
+func (c *Client) sleepBackoff(ctx context.Context, attempt int) error {
 	base := c.backoffBase
 	if base <= 0 {
 		base = 500 * time.Millisecond
@@ -261,12 +346,12 @@ func (c *Client) sleepBackoff(attempt int) {
 	backoff := base * time.Duration(1<<uint(attempt))
 	n, _ := rand.Int(rand.Reader, big.NewInt(int64(base)))
 	jitter := time.Duration(n.Int64())
-	time.Sleep(backoff + jitter)
+	return ratelimit.Sleep(ctx, backoff+jitter)
 }
 
 // doPublic performs a request without any auth headers. Use for public endpoints.
 func (c *Client) doPublic(ctx context.Context, method, path string, query url.Values, body string) (*http.Response, error) {
-	u := baseURL + path
+	u := c.baseURL + path
 	if len(query) > 0 {
 		u += "?" + query.Encode()
 	}
@@ -276,24 +361,74 @@ func (c *Client) doPublic(ctx context.Context, method, path string, query url.Va
 	}
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Cache-Control", "no-cache")
+	if c.userAgent != "" {
+		req.Header.Set("User-Agent", c.userAgent)
+	}
 	return c.doRequest(req)
 }
 
-func NewClient(apiKey, apiSecret, passphrase string) *Client {
-	return &Client{
-		apiKey:     apiKey,
-		apiSecret:  apiSecret,
-		passphrase: passphrase,
+// Option configures a Client.
+type Option func(*Client)
+
+// WithBaseURL points the client at another API host, e.g. the sandbox, a proxy or a test
+// server. An empty url keeps DefaultBaseURL.
+func WithBaseURL(u string) Option {
+	return func(c *Client) {
+		if u != "" {
+			c.baseURL = strings.TrimRight(u, "/")
+		}
+	}
+}
+
+// WithTransport sets the http.RoundTripper used for all requests.
+func WithTransport(rt http.RoundTripper) Option {
+	return func(c *Client) { c.httpClient.Transport = rt }
+}
+
+// WithLimiters sets the budgets for public market data and for authenticated endpoints; see
+// SetLimiters.
+func WithLimiters(public, private ratelimit.Limiter) Option {
+	return func(c *Client) { c.SetLimiters(public, private) }
+}
+
+// WithUserAgent sets the User-Agent header sent with every request.
+func WithUserAgent(ua string) Option {
+	return func(c *Client) { c.userAgent = ua }
+}
+
+// WithTimeout sets the per-request timeout. The default is 30 seconds; 0 disables it.
+func WithTimeout(d time.Duration) Option {
+	return func(c *Client) { c.httpClient.Timeout = d }
+}
+
+// newClient returns a client with defaults and opts applied.
+func newClient(opts []Option) *Client {
+	c := &Client{
+		baseURL:    DefaultBaseURL,
 		httpClient: &http.Client{Timeout: 30 * time.Second},
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// NewClient creates a client that signs requests with legacy HMAC API keys.
+func NewClient(apiKey, apiSecret, passphrase string, opts ...Option) *Client {
+	c := newClient(opts)
+	c.apiKey = apiKey
+	c.apiSecret = apiSecret
+	c.passphrase = passphrase
+	return c
 }
 
 // NewClientWithJWT creates a client that uses JWT bearer tokens.
 // keyName is the COINBASE_API_KEY_NAME (e.g., organizations/.../apiKeys/...).
 // privateKeyPEM is the EC private key in PEM format. It may contain literal \n sequences; they will be converted.
-func NewClientWithJWT(keyName, privateKeyPEM string) (*Client, error) {
+func NewClientWithJWT(keyName, privateKeyPEM string, opts ...Option) (*Client, error) {
+	c := newClient(opts)
 	if privateKeyPEM == "" || keyName == "" {
-		return &Client{httpClient: &http.Client{Timeout: 30 * time.Second}}, nil
+		return c, nil
 	}
 	// Normalize escaped newlines
 	normalized := strings.ReplaceAll(privateKeyPEM, "\\n", "\n")
@@ -305,13 +440,15 @@ func NewClientWithJWT(keyName, privateKeyPEM string) (*Client, error) {
 	if err != nil {
 		return nil, fmt.Errorf("parse EC private key: %w", err)
 	}
-	return &Client{jwtKeyName: keyName, jwtPrivateKey: pk, httpClient: &http.Client{Timeout: 30 * time.Second}}, nil
+	c.jwtKeyName = keyName
+	c.jwtPrivateKey = pk
+	return c, nil
 }
 
 // APIKeyClaims defines the custom claims for Coinbase JWT.
 type APIKeyClaims struct {
 	*jwt.Claims
-	URI string `json:"uri"`
+	URI string `json:"uri,omitempty"`
 }
 
 // NonceSource is required by go-jose for generating a nonce.
@@ -327,7 +464,24 @@ func (n nonceSource) Nonce() (string, error) {
 	return r.String(), nil
 }
 
+// bearerToken signs a JWT for one request. The uri claim names the host of the base URL,
+// so tokens for the sandbox or a test server are scoped to that host.
 func (c *Client) bearerToken(method, path string) (string, error) {
+	host := "api.coinbase.com"
+	if u, err := url.Parse(c.baseURL); err == nil && u.Host != "" {
+		host = u.Host
+	}
+	return c.signJWT(fmt.Sprintf("%s %s%s", method, host, path))
+}
+
+// StreamToken returns a JWT for authenticating WebSocket subscriptions. WebSocket tokens
+// carry no uri claim. It returns an empty token when the client has no JWT credentials.
+func (c *Client) StreamToken() (string, error) {
+	return c.signJWT("")
+}
+
+// signJWT signs a short-lived CDP API key JWT. An empty uri omits the claim.
+func (c *Client) signJWT(uri string) (string, error) {
 	if c.jwtPrivateKey == nil || c.jwtKeyName == "" {
 		return "", nil
 	}
@@ -347,7 +501,7 @@ func (c *Client) bearerToken(method, path string) (string, error) {
 			NotBefore: jwt.NewNumericDate(time.Now()),
 			Expiry:    jwt.NewNumericDate(time.Now().Add(2 * time.Minute)),
 		},
-		URI: fmt.Sprintf("%s %s%s", method, "api.coinbase.com", path),
+		URI: uri,
 	}
 
 	return jwt.Signed(signer).Claims(claims).CompactSerialize()
@@ -366,7 +520,7 @@ func (c *Client) sign(ts, method, path, body string) (string, error) {
 }
 
 func (c *Client) do(ctx context.Context, method, path string, query url.Values, body string) (*http.Response, error) {
-	u := baseURL + path
+	u := c.baseURL + path
 	if len(query) > 0 {
 		u += "?" + query.Encode()
 	}
@@ -395,6 +549,9 @@ func (c *Client) do(ctx context.Context, method, path string, query url.Values,
 	}
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Cache-Control", "no-cache")
+	if c.userAgent != "" {
+		req.Header.Set("User-Agent", c.userAgent)
+	}
 	if c.verbose {
 		fmt.Printf("==> Request Headers:\n")
 		for k, v := range req.Header {
@@ -405,32 +562,36 @@ func (c *Client) do(ctx context.Context, method, path string, query url.Values,
 }
 
 
+// mapGranularity returns the API enum for g, falling back to ONE_HOUR for unknown values.
 func mapGranularity(g string) string {
-    switch strings.ToLower(g) {
-    case "1m", "1min", "one_minute":
-        return "ONE_MINUTE"
-    case "5m", "5min", "five_minute":
-        return "FIVE_MINUTE"
-    case "15m", "15min", "fifteen_minute":
-        return "FIFTEEN_MINUTE"
-    case "30m", "thirty_minute":
-        return "THIRTY_MINUTE"
-    case "1h", "60m", "one_hour":
-        return "ONE_HOUR"
-    case "2h", "two_hour":
-        return "TWO_HOUR"
-    case "6h", "six_hour":
-        return "SIX_HOUR"
-    case "1d", "one_day", "24h":
-        return "ONE_DAY"
-    default:
-        return "ONE_HOUR"
-    }
+	if enum, ok := granularityEnum(g); ok {
+		return enum
+	}
+	return "ONE_HOUR"
 }
 
-func parseFloat(s string) float64 {
-	f, _ := strconv.ParseFloat(s, 64)
-	return f
+// granularityEnum returns the API enum for g and whether Coinbase supports it.
+func granularityEnum(g string) (string, bool) {
+	switch strings.ToLower(g) {
+	case "1m", "1min", "one_minute":
+		return "ONE_MINUTE", true
+	case "5m", "5min", "five_minute":
+		return "FIVE_MINUTE", true
+	case "15m", "15min", "fifteen_minute":
+		return "FIFTEEN_MINUTE", true
+	case "30m", "thirty_minute":
+		return "THIRTY_MINUTE", true
+	case "1h", "60m", "one_hour":
+		return "ONE_HOUR", true
+	case "2h", "two_hour":
+		return "TWO_HOUR", true
+	case "6h", "six_hour":
+		return "SIX_HOUR", true
+	case "1d", "one_day", "24h":
+		return "ONE_DAY", true
+	default:
+		return "", false
+	}
 }
 
 func bucketSeconds(g string) int64 {
@@ -456,14 +617,16 @@ func bucketSeconds(g string) int64 {
 	}
 }
 
-func parseStartTime(s string) time.Time {
+// parseStartTime parses a candle start given as UNIX seconds or RFC3339. Anything else is an
+// error rather than the zero time, which would store the candle at 1970-01-01.
+func parseStartTime(s string) (time.Time, error) {
 	// Try UNIX seconds first
 	if sec, err := strconv.ParseInt(s, 10, 64); err == nil {
-		return time.Unix(sec, 0).UTC()
+		return time.Unix(sec, 0).UTC(), nil
 	}
 	// Fallback to RFC3339
 	if t, err := time.Parse(time.RFC3339, s); err == nil {
-		return t.UTC()
+		return t.UTC(), nil
 	}
-	return time.Time{}
+	return time.Time{}, fmt.Errorf("invalid start %q", s)
 }
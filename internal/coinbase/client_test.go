@@ -0,0 +1,347 @@
+package coinbase
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/go-jose/go-jose.v2/jwt"
+
+	"cryptool/internal/coinbase/cassette"
+	"cryptool/internal/config"
+	"cryptool/internal/exchange"
+)
+
+// testKeyPEM returns a fresh EC private key in the PEM format CDP API keys use.
+func testKeyPEM(t *testing.T) string {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}))
+}
+
+func TestClientOptions(t *testing.T) {
+	var got *http.Request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		w.Write([]byte(`{"accounts":[]}`))
+	}))
+	defer srv.Close()
+
+	c, err := NewClientWithJWT("organizations/o/apiKeys/k", testKeyPEM(t),
+		WithBaseURL(srv.URL+"/"), WithUserAgent("cryptool-test"), WithTimeout(5*time.Second))
+	if err != nil {
+		t.Fatalf("NewClientWithJWT: %v", err)
+	}
+	if c.httpClient.Timeout != 5*time.Second {
+		t.Errorf("timeout = %v, want 5s", c.httpClient.Timeout)
+	}
+	if _, err := c.ListAccounts(context.Background()); err != nil {
+		t.Fatalf("ListAccounts: %v", err)
+	}
+
+	if got == nil || got.URL.Path != "/api/v3/brokerage/accounts" {
+		t.Fatalf("request did not reach the test server: %+v", got)
+	}
+	if ua := got.Header.Get("User-Agent"); ua != "cryptool-test" {
+		t.Errorf("User-Agent = %q", ua)
+	}
+
+	// The JWT uri claim is scoped to the configured host rather than api.coinbase.com.
+	tok, err := jwt.ParseSigned(strings.TrimPrefix(got.Header.Get("Authorization"), "Bearer "))
+	if err != nil {
+		t.Fatalf("parse bearer token: %v", err)
+	}
+	var claims APIKeyClaims
+	if err := tok.UnsafeClaimsWithoutVerification(&claims); err != nil {
+		t.Fatal(err)
+	}
+	wantURI := "GET " + strings.TrimPrefix(srv.URL, "http://") + "/api/v3/brokerage/accounts"
+	if claims.URI != wantURI {
+		t.Errorf("uri claim = %q, want %q", claims.URI, wantURI)
+	}
+}
+
+// roundTripFunc adapts a function to http.RoundTripper.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func TestClientWithTransport(t *testing.T) {
+	var host string
+	rt := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		host = r.URL.Host
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       http.NoBody,
+			Request:    r,
+		}, nil
+	})
+
+	c := NewClient("", "", "", WithTransport(rt))
+	c.ListAccounts(context.Background())
+	if host != "api.coinbase.com" {
+		t.Errorf("request host = %q, want the default base URL through the custom transport", host)
+	}
+}
+
+func TestGetCandlesOnceReplay(t *testing.T) {
+	player, err := cassette.Load("testdata/cassettes/candles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewClient("", "", "", WithTransport(player))
+	c.Configure(0, 3, 1, false)
+	ctx := context.Background()
+	start := time.Unix(1704067200, 0).UTC()
+
+	// Candles come back newest first and are returned oldest first.
+	candles, err := c.GetCandlesOnce(ctx, "BTC-USD", start, start.Add(3*time.Minute), "1m", 0)
+	if err != nil {
+		t.Fatalf("GetCandlesOnce: %v", err)
+	}
+	if len(candles) != 3 {
+		t.Fatalf("got %d candles, want 3", len(candles))
+	}
+	want, _ := exchange.ParseCandle(start, "42288.58", "42315.87", "42263.12", "42301.77", "12.80923477")
+	if got := candles[0]; !got.Time.Equal(want.Time) || !got.Open.Equal(want.Open) || !got.High.Equal(want.High) ||
+		!got.Low.Equal(want.Low) || !got.Close.Equal(want.Close) || !got.Volume.Equal(want.Volume) {
+		t.Errorf("first candle = %+v, want %+v", candles[0], want)
+	}
+	if !candles[2].Time.Equal(start.Add(2 * time.Minute)) {
+		t.Errorf("last candle at %s", candles[2].Time)
+	}
+
+	// A recorded 429 is retried and the empty page that follows is not an error.
+	empty, err := c.GetCandlesOnce(ctx, "ETH-USD", start, start.Add(time.Hour), "ONE_HOUR", 350)
+	if err != nil || len(empty) != 0 {
+		t.Errorf("empty page after 429 = %v, %v", empty, err)
+	}
+
+	if _, err := c.GetCandlesOnce(ctx, "BTC-USD", start, start.AddDate(0, 0, 10), "1m", 350); err == nil ||
+		!strings.Contains(err.Error(), "coinbase http 400") || !strings.Contains(err.Error(), "INVALID_ARGUMENT") {
+		t.Errorf("oversized range: err = %v", err)
+	}
+	if n := player.Remaining(); n != 0 {
+		t.Errorf("%d recorded interactions were not replayed", n)
+	}
+}
+
+func TestMapGranularity(t *testing.T) {
+	cases := map[string]string{
+		"1m": "ONE_MINUTE", "1MIN": "ONE_MINUTE", "one_minute": "ONE_MINUTE",
+		"5m": "FIVE_MINUTE", "15min": "FIFTEEN_MINUTE", "30m": "THIRTY_MINUTE",
+		"1h": "ONE_HOUR", "60m": "ONE_HOUR", "1H": "ONE_HOUR",
+		"2h": "TWO_HOUR", "6h": "SIX_HOUR",
+		"1d": "ONE_DAY", "24h": "ONE_DAY", "ONE_DAY": "ONE_DAY",
+		// Unknown values fall back to one hour.
+		"": "ONE_HOUR", "4h": "ONE_HOUR", "1w": "ONE_HOUR",
+	}
+	for in, want := range cases {
+		if got := mapGranularity(in); got != want {
+			t.Errorf("mapGranularity(%q) = %s, want %s", in, got, want)
+		}
+	}
+
+	// The adapter refuses granularities Coinbase does not offer instead of falling back.
+	_, err := NewAdapter(NewClient("", "", "")).GetCandles(context.Background(), "BTC-USD", time.Now(), time.Now(), "4h")
+	if !errors.Is(err, exchange.ErrNotSupported) {
+		t.Errorf("4h: err = %v, want ErrNotSupported", err)
+	}
+	// Commands check the same set up front.
+	if err := exchange.CheckGranularity(NewAdapter(NewClient("", "", "")), "4h"); !errors.Is(err, exchange.ErrNotSupported) {
+		t.Errorf("CheckGranularity(4h) = %v, want ErrNotSupported", err)
+	}
+}
+
+func TestAPIError(t *testing.T) {
+	cases := []struct {
+		status       int
+		body         string
+		want         string
+		unauthorized bool
+		rateLimited  bool
+		notFound     bool
+	}{
+		{401, `{"error":"UNAUTHENTICATED","message":"Unauthorized","error_details":"invalid jwt"}`,
+			"coinbase http 401: UNAUTHENTICATED: Unauthorized (invalid jwt)", true, false, false},
+		{403, `{"error":"PERMISSION_DENIED","message":"Missing required scopes","error_details":"Missing required scopes"}`,
+			"coinbase http 403: PERMISSION_DENIED: Missing required scopes", true, false, false},
+		{429, `{"error":"RATE_LIMIT_EXCEEDED","message":"Too many requests"}`,
+			"coinbase http 429: RATE_LIMIT_EXCEEDED: Too many requests", false, true, false},
+		{404, `{"error":"NOT_FOUND","message":"ProductID FOO-BAR not found"}`,
+			"coinbase http 404: NOT_FOUND: ProductID FOO-BAR not found", false, false, true},
+		{400, `{"error":"INVALID_ARGUMENT","message":"bad size","preview_failure_reason":"PREVIEW_INVALID_BASE_SIZE_TOO_SMALL"}`,
+			"coinbase http 400: INVALID_ARGUMENT: bad size [PREVIEW_INVALID_BASE_SIZE_TOO_SMALL]", false, false, false},
+		{502, "<html>Bad Gateway</html>\n", "coinbase http 502: <html>Bad Gateway</html>", false, false, false},
+	}
+	for _, tc := range cases {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tc.status)
+			w.Write([]byte(tc.body))
+		}))
+		c := NewClient("", "", "", WithBaseURL(srv.URL))
+		c.Configure(0, 1, 1, false)
+		_, err := c.GetOrder(context.Background(), "order-1")
+		srv.Close()
+
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != tc.status {
+			t.Errorf("%d: err = %#v, want *APIError", tc.status, err)
+			continue
+		}
+		if err.Error() != tc.want {
+			t.Errorf("%d: Error() = %q, want %q", tc.status, err.Error(), tc.want)
+		}
+		if IsUnauthorized(err) != tc.unauthorized || errors.Is(err, exchange.ErrUnauthorized) != tc.unauthorized {
+			t.Errorf("%d: unauthorized = %v, want %v", tc.status, IsUnauthorized(err), tc.unauthorized)
+		}
+		if IsRateLimited(err) != tc.rateLimited || errors.Is(err, exchange.ErrRateLimited) != tc.rateLimited {
+			t.Errorf("%d: rate limited = %v, want %v", tc.status, IsRateLimited(err), tc.rateLimited)
+		}
+		if IsNotFound(err) != tc.notFound || errors.Is(err, exchange.ErrNotFound) != tc.notFound {
+			t.Errorf("%d: not found = %v, want %v", tc.status, IsNotFound(err), tc.notFound)
+		}
+	}
+}
+
+// recordingLimiter counts waits and records backoffs without pacing anything.
+type recordingLimiter struct {
+	waits    int
+	backoffs []time.Time
+	err      error
+}
+
+func (l *recordingLimiter) Wait(ctx context.Context) error {
+	l.waits++
+	return l.err
+}
+
+func (l *recordingLimiter) Backoff(ctx context.Context, until time.Time) error {
+	l.backoffs = append(l.backoffs, until)
+	return nil
+}
+
+func TestClientLimiters(t *testing.T) {
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.Header().Set("Retry-After", "30")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(`{"candles":[]}`))
+	}))
+	defer srv.Close()
+
+	public, private := &recordingLimiter{}, &recordingLimiter{}
+	c := NewClient("", "", "", WithBaseURL(srv.URL), WithLimiters(public, private))
+	c.Configure(0, 3, 1000, false)
+
+	start := time.Now()
+	if _, err := c.GetCandlesOnce(context.Background(), "BTC-USD", start.Add(-time.Hour), start, "1m", 0); err != nil {
+		t.Fatalf("GetCandlesOnce: %v", err)
+	}
+	// The retry is charged to the public budget, which is paused per Retry-After instead of
+	// the client sleeping its own backoff.
+	if public.waits != 2 || private.waits != 0 {
+		t.Errorf("waits public=%d private=%d, want 2 and 0", public.waits, private.waits)
+	}
+	if len(public.backoffs) != 1 || public.backoffs[0].Sub(start) < 29*time.Second {
+		t.Errorf("backoffs = %v, want one ~30s after start", public.backoffs)
+	}
+	if time.Since(start) > 900*time.Millisecond {
+		t.Errorf("request took %s; the client should leave waiting to the limiter", time.Since(start))
+	}
+
+	c.ListAccounts(context.Background())
+	if private.waits != 1 {
+		t.Errorf("private waits = %d, want 1 for an authenticated endpoint", private.waits)
+	}
+
+	// A limiter error, e.g. a cancelled context, aborts the request before it is sent.
+	private.err = context.Canceled
+	before := calls
+	if _, err := c.ListAccounts(context.Background()); !errors.Is(err, context.Canceled) {
+		t.Errorf("ListAccounts with a failing limiter: err = %v", err)
+	}
+	if calls != before {
+		t.Error("request was sent despite the limiter error")
+	}
+}
+
+func TestGetCandlesOnceRejectsMalformedValues(t *testing.T) {
+	cases := []struct{ body, want string }{
+		// A malformed close must fail the page rather than be stored as zero.
+		{`{"candles":[{"start":"1704067200","low":"1","high":"2","open":"1.5","close":"abc","volume":"3"}]}`, "close"},
+		// A malformed start must fail rather than store the candle at 1970-01-01.
+		{`{"candles":[{"start":"soon","low":"1","high":"2","open":"1.5","close":"1.5","volume":"3"}]}`, `invalid start "soon"`},
+	}
+	for _, tc := range cases {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(tc.body))
+		}))
+		c := NewClient("", "", "", WithBaseURL(srv.URL))
+		c.Configure(0, 1, 1, false)
+		start := time.Unix(1704067200, 0).UTC()
+		_, err := c.GetCandlesOnce(context.Background(), "BTC-USD", start, start.Add(time.Minute), "1m", 0)
+		srv.Close()
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("err = %v, want an error containing %q", err, tc.want)
+		}
+	}
+}
+
+func TestNewClientFromConfigLimits(t *testing.T) {
+	var cfg config.Config
+	cfg.Coinbase.RPM = 10
+	cfg.Coinbase.PublicRPM, cfg.Coinbase.PrivateRPM, cfg.Coinbase.Burst = 600, 1800, 5
+
+	c, err := NewClientFromConfig(&cfg)
+	if err != nil {
+		t.Fatalf("NewClientFromConfig: %v", err)
+	}
+	if c.public != c.private {
+		t.Error("legacy COINBASE_RPM should pace public and private requests with one bucket")
+	}
+
+	// Shared limits win over the legacy RPM, so the client needs the database.
+	cfg.Coinbase.SharedLimits = true
+	if _, err := NewClientFromConfig(&cfg); err == nil || !strings.Contains(err.Error(), "shared rate limits") {
+		t.Errorf("shared limits with COINBASE_RPM set: err = %v, want the shared budget to be used", err)
+	}
+
+	// The client owns the pool behind shared limits and releases it on Close. OpenPool does
+	// not connect, so no database is needed.
+	cfg.Database.URL = "postgres://localhost/cryptool_test?sslmode=disable"
+	c, err = NewClientFromConfig(&cfg)
+	if err != nil {
+		t.Fatalf("NewClientFromConfig with shared limits: %v", err)
+	}
+	if c.closer == nil {
+		t.Fatal("shared limits client holds no pool to close")
+	}
+	if err := NewAdapter(c).Close(); err != nil || c.closer != nil {
+		t.Errorf("Close = %v, closer = %v; want the pool released", err, c.closer)
+	}
+	if err := c.Close(); err != nil {
+		t.Errorf("second Close = %v, want nil", err)
+	}
+}
@@ -0,0 +1,101 @@
+package coinbase
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"cryptool/internal/exchange"
+)
+
+// APIError is a non-2xx response from the Coinbase API. It matches exchange.ErrUnauthorized,
+// exchange.ErrRateLimited and exchange.ErrNotFound with errors.Is, so exchange-neutral code
+// can react to it.
+type APIError struct {
+	StatusCode int
+	// Code is Coinbase's error code, e.g. UNAUTHENTICATED, NOT_FOUND or INVALID_ARGUMENT.
+	Code                 string `json:"error"`
+	Message              string `json:"message"`
+	Details              string `json:"error_details"`
+	PreviewFailureReason string `json:"preview_failure_reason"`
+	// Body is the raw response body, kept when it is not a Coinbase error object.
+	Body string `json:"-"`
+}
+
+// newAPIError reads and closes the body of a failed response.
+func newAPIError(resp *http.Response) *APIError {
+	b, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	e := &APIError{StatusCode: resp.StatusCode}
+	if err := json.Unmarshal(b, e); err != nil || (e.Code == "" && e.Message == "") {
+		e.Body = strings.TrimSpace(string(b))
+	}
+	return e
+}
+
+func (e *APIError) Error() string {
+	msg := e.Body
+	if e.Code != "" || e.Message != "" {
+		parts := make([]string, 0, 4)
+		for _, p := range []string{e.Code, e.Message} {
+			if p != "" {
+				parts = append(parts, p)
+			}
+		}
+		msg = strings.Join(parts, ": ")
+		if e.Details != "" && e.Details != e.Message {
+			msg += " (" + e.Details + ")"
+		}
+		if e.PreviewFailureReason != "" {
+			msg += " [" + e.PreviewFailureReason + "]"
+		}
+	}
+	return fmt.Sprintf("coinbase http %d: %s", e.StatusCode, msg)
+}
+
+// Is maps the error to the exchange package's sentinels.
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case exchange.ErrUnauthorized:
+		return e.unauthorized()
+	case exchange.ErrRateLimited:
+		return e.rateLimited()
+	case exchange.ErrNotFound:
+		return e.notFound()
+	}
+	return false
+}
+
+func (e *APIError) unauthorized() bool {
+	return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden ||
+		e.Code == "UNAUTHENTICATED" || e.Code == "PERMISSION_DENIED"
+}
+
+func (e *APIError) rateLimited() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.Code == "RATE_LIMIT_EXCEEDED"
+}
+
+func (e *APIError) notFound() bool {
+	return e.StatusCode == http.StatusNotFound || e.Code == "NOT_FOUND"
+}
+
+// IsUnauthorized reports whether err is a Coinbase authentication or permission failure.
+func IsUnauthorized(err error) bool {
+	var e *APIError
+	return errors.As(err, &e) && e.unauthorized()
+}
+
+// IsRateLimited reports whether err is a Coinbase rate limit response.
+func IsRateLimited(err error) bool {
+	var e *APIError
+	return errors.As(err, &e) && e.rateLimited()
+}
+
+// IsNotFound reports whether err is a Coinbase not-found response, e.g. for an unknown product or order.
+func IsNotFound(err error) bool {
+	var e *APIError
+	return errors.As(err, &e) && e.notFound()
+}
@@ -0,0 +1,300 @@
+package coinbase
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"cryptool/internal/coinbase/cassette"
+	"cryptool/internal/config"
+	"cryptool/internal/db"
+	"cryptool/internal/exchange"
+	"cryptool/internal/ratelimit"
+)
+
+// MaxCandlesPerRequest is the most candles the candles endpoint returns in one call.
+const MaxCandlesPerRequest = 350
+
+func init() {
+	exchange.Register("coinbase", func(cfg *config.Config) (exchange.Exchange, error) {
+		client, err := NewClientFromConfig(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return NewAdapter(client), nil
+	})
+}
+
+// NewClientFromConfig builds a client from config, preferring JWT auth when configured and
+// falling back to HMAC headers, with rate limiting and retries applied. opts are applied
+// after the configured base URL. Close the client when done: with shared rate limits it
+// holds a database pool.
+func NewClientFromConfig(cfg *config.Config, opts ...Option) (*Client, error) {
+	base := []Option{WithBaseURL(cfg.Coinbase.BaseURL)}
+	switch {
+	case cfg.Coinbase.RecordDir != "" && cfg.Coinbase.ReplayDir != "":
+		return nil, fmt.Errorf("record and replay cannot be used together")
+	case cfg.Coinbase.RecordDir != "":
+		rec, err := cassette.NewRecorder(cfg.Coinbase.RecordDir, nil)
+		if err != nil {
+			return nil, err
+		}
+		base = append(base, WithTransport(rec))
+	case cfg.Coinbase.ReplayDir != "":
+		player, err := cassette.Load(cfg.Coinbase.ReplayDir)
+		if err != nil {
+			return nil, err
+		}
+		base = append(base, WithTransport(player))
+	}
+	opts = append(base, opts...)
+	var client *Client
+	if cfg.Coinbase.APIKeyName != "" && cfg.Coinbase.APIPrivateKey != "" {
+		jwtClient, err := NewClientWithJWT(cfg.Coinbase.APIKeyName, cfg.Coinbase.APIPrivateKey, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("jwt client init: %w", err)
+		}
+		client = jwtClient
+	} else {
+		client = NewClient(cfg.Coinbase.APIKey, cfg.Coinbase.APISecret, cfg.Coinbase.Passphrase, opts...)
+	}
+	rpm := cfg.Coinbase.RPM
+	if cfg.Coinbase.ReplayDir != "" {
+		// Replayed responses need no pacing.
+		rpm = 0
+	} else if rpm <= 0 || cfg.Coinbase.SharedLimits {
+		// A shared budget only works if every process uses it, so it wins over the legacy RPM.
+		rpm = 0
+		public, private, conn, err := limitersFromConfig(cfg)
+		if err != nil {
+			return nil, err
+		}
+		client.SetLimiters(public, private)
+		client.closer = conn
+	}
+	client.Configure(rpm, cfg.Coinbase.MaxRetries, cfg.Coinbase.BackoffMS, cfg.App.Verbose)
+	return client, nil
+}
+
+// limitersFromConfig returns the public and private request budgets. With SharedLimits the
+// budgets live in Postgres, keyed by a hash of the API key so processes using other keys
+// keep their own, and conn is the pool they use; the caller must close it.
+func limitersFromConfig(cfg *config.Config) (public, private ratelimit.Limiter, conn io.Closer, err error) {
+	c := cfg.Coinbase
+	if !c.SharedLimits {
+		return ratelimit.NewBucket(c.PublicRPM, c.Burst), ratelimit.NewBucket(c.PrivateRPM, c.Burst), nil, nil
+	}
+	if cfg.Database.URL == "" {
+		return nil, nil, nil, fmt.Errorf("shared rate limits need a database url")
+	}
+	pool, err := db.OpenPool(cfg.Database.URL, db.PoolFromConfig(cfg))
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("open rate limit database: %w", err)
+	}
+	key := c.APIKeyName
+	if key == "" {
+		key = c.APIKey
+	}
+	sum := sha256.Sum256([]byte(key))
+	id := hex.EncodeToString(sum[:8])
+	public = ratelimit.NewPostgresBudget(pool, "coinbase:public:"+id, c.PublicRPM, c.Burst)
+	private = ratelimit.NewPostgresBudget(pool, "coinbase:private:"+id, c.PrivateRPM, c.Burst)
+	return public, private, pool, nil
+}
+
+// Adapter exposes a Client through the exchange interfaces.
+type Adapter struct {
+	client *Client
+}
+
+// NewAdapter wraps client as an exchange adapter.
+func NewAdapter(client *Client) *Adapter {
+	return &Adapter{client: client}
+}
+
+// Client returns the underlying Coinbase client for Coinbase-only features.
+func (a *Adapter) Client() *Client { return a.client }
+
+// Close closes the underlying client.
+func (a *Adapter) Close() error { return a.client.Close() }
+
+func (a *Adapter) Name() string { return "coinbase" }
+
+func (a *Adapter) MaxCandlesPerRequest() int64 { return MaxCandlesPerRequest }
+
+// SupportsGranularity reports whether Coinbase serves candles at granularity. It has no 4h candles.
+func (a *Adapter) SupportsGranularity(granularity string) bool {
+	_, ok := granularityEnum(granularity)
+	return ok
+}
+
+func (a *Adapter) GetCandles(ctx context.Context, productID string, start, end time.Time, granularity string) ([]exchange.Candle, error) {
+	if _, ok := granularityEnum(granularity); !ok {
+		return nil, fmt.Errorf("coinbase granularity %q: %w", granularity, exchange.ErrNotSupported)
+	}
+	return a.client.GetCandlesOnce(ctx, productID, start, end, granularity, MaxCandlesPerRequest)
+}
+
+func (a *Adapter) GetProducts(ctx context.Context) ([]exchange.Product, error) {
+	products, err := a.client.GetProducts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]exchange.Product, 0, len(products))
+	for _, p := range products {
+		details, err := json.Marshal(p)
+		if err != nil {
+			return nil, fmt.Errorf("encode product %s: %w", p.ProductID, err)
+		}
+		out = append(out, exchange.Product{
+			ProductID:       p.ProductID,
+			BaseCurrency:    p.BaseCurrencyID,
+			QuoteCurrency:   p.QuoteCurrencyID,
+			BaseName:        p.BaseName,
+			QuoteName:       p.QuoteName,
+			Status:          p.Status,
+			Disabled:        p.IsDisabled,
+			TradingDisabled: p.TradingDisabled,
+			Price:           p.Price,
+			Volume24h:       p.Volume24h,
+			BaseIncrement:   p.BaseIncrement,
+			QuoteIncrement:  p.QuoteIncrement,
+			PriceIncrement:  p.PriceIncrement,
+			BaseMinSize:     p.BaseMinSize,
+			BaseMaxSize:     p.BaseMaxSize,
+			QuoteMinSize:    p.QuoteMinSize,
+			QuoteMaxSize:    p.QuoteMaxSize,
+			ListedAt:        p.NewAt,
+			Details:         details,
+		})
+	}
+	return out, nil
+}
+
+func (a *Adapter) ListAccounts(ctx context.Context) ([]exchange.Account, error) {
+	accounts, err := a.client.ListAccounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]exchange.Account, 0, len(accounts))
+	for _, acc := range accounts {
+		out = append(out, exchange.Account{
+			ID:        acc.UUID,
+			Name:      acc.Name,
+			Currency:  acc.Currency,
+			Available: acc.AvailableBalance.Value.String(),
+			Hold:      acc.Hold.Value.String(),
+			Active:    acc.Active,
+			CreatedAt: parseTime(acc.CreatedAt),
+			UpdatedAt: parseTime(acc.UpdatedAt),
+			DeletedAt: parseTime(acc.DeletedAt),
+		})
+	}
+	return out, nil
+}
+
+func (a *Adapter) PlaceOrder(ctx context.Context, req exchange.OrderRequest) (*exchange.Order, error) {
+	var cr CreateOrderRequest
+	switch req.Type {
+	case exchange.OrderTypeMarket:
+		cr = NewMarketOrder(req.ProductID, req.Side, req.BaseSize, req.QuoteSize)
+	case exchange.OrderTypeLimit:
+		cr = NewLimitOrder(req.ProductID, req.Side, req.BaseSize, req.LimitPrice, req.PostOnly, req.EndTime)
+	case exchange.OrderTypeStopLimit:
+		dir := StopDirectionDown
+		if req.StopDirection == "up" {
+			dir = StopDirectionUp
+		}
+		cr = NewStopLimitOrder(req.ProductID, req.Side, req.BaseSize, req.LimitPrice, req.StopPrice, dir, req.EndTime)
+	default:
+		return nil, fmt.Errorf("order type %q: %w", req.Type, exchange.ErrNotSupported)
+	}
+	cr.ClientOrderID = req.ClientOrderID
+	if cr.ClientOrderID == "" {
+		cr.ClientOrderID = NewClientOrderID()
+	}
+	res, err := a.client.CreateOrder(ctx, cr)
+	if err != nil {
+		return nil, err
+	}
+	id := res.OrderID
+	if res.SuccessResponse != nil && res.SuccessResponse.OrderID != "" {
+		id = res.SuccessResponse.OrderID
+	}
+	return &exchange.Order{
+		ID:            id,
+		ClientOrderID: cr.ClientOrderID,
+		ProductID:     req.ProductID,
+		Side:          req.Side,
+		Type:          req.Type,
+	}, nil
+}
+
+func (a *Adapter) CancelOrders(ctx context.Context, orderIDs []string) error {
+	results, err := a.client.CancelOrders(ctx, orderIDs)
+	if err != nil {
+		return err
+	}
+	var failed []string
+	for _, r := range results {
+		if !r.Success {
+			failed = append(failed, r.OrderID+": "+r.FailureReason)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("cancel failed for %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+func (a *Adapter) GetOrder(ctx context.Context, orderID string) (*exchange.Order, error) {
+	o, err := a.client.GetOrder(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	out := toExchangeOrder(*o)
+	return &out, nil
+}
+
+func (a *Adapter) ListOpenOrders(ctx context.Context, productID string) ([]exchange.Order, error) {
+	params := ListOrdersParams{OrderStatus: []string{"OPEN"}}
+	if productID != "" {
+		params.ProductIDs = []string{productID}
+	}
+	orders, err := a.client.ListOrders(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]exchange.Order, 0, len(orders))
+	for _, o := range orders {
+		out = append(out, toExchangeOrder(o))
+	}
+	return out, nil
+}
+
+func toExchangeOrder(o Order) exchange.Order {
+	return exchange.Order{
+		ID:            o.OrderID,
+		ClientOrderID: o.ClientOrderID,
+		ProductID:     o.ProductID,
+		Side:          o.Side,
+		Type:          o.OrderType,
+		Status:        o.Status,
+		FilledSize:    o.FilledSize,
+		AveragePrice:  o.AverageFilledPrice,
+		CreatedAt:     o.CreatedTime,
+	}
+}
+
+func parseTime(s string) time.Time {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
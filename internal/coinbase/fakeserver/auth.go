@@ -0,0 +1,51 @@
+package fakeserver
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"gopkg.in/go-jose/go-jose.v2/jwt"
+
+	"cryptool/internal/coinbase"
+)
+
+// authenticate checks the request's bearer token when Options.Keys is set. Like Coinbase it
+// requires an ES256 token whose kid names a known key, issued by "cdp" for that key, valid
+// now, and whose uri claim is "METHOD host path" of this request.
+func (s *Server) authenticate(r *http.Request) error {
+	if len(s.opts.Keys) == 0 {
+		return nil
+	}
+	raw, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || raw == "" {
+		return errors.New("missing bearer token")
+	}
+	tok, err := jwt.ParseSigned(raw)
+	if err != nil {
+		return fmt.Errorf("malformed token: %w", err)
+	}
+	if len(tok.Headers) != 1 || tok.Headers[0].Algorithm != "ES256" {
+		return errors.New("token must be signed with ES256")
+	}
+	kid := tok.Headers[0].KeyID
+	key, ok := s.opts.Keys[kid]
+	if !ok {
+		return fmt.Errorf("unknown key %q", kid)
+	}
+
+	claims := coinbase.APIKeyClaims{Claims: &jwt.Claims{}}
+	if err := tok.Claims(key, &claims); err != nil {
+		return fmt.Errorf("invalid signature: %w", err)
+	}
+	expected := jwt.Expected{Issuer: "cdp", Subject: kid, Time: s.opts.Now()}
+	if err := claims.Claims.ValidateWithLeeway(expected, 5*time.Second); err != nil {
+		return fmt.Errorf("invalid claims: %w", err)
+	}
+	if want := r.Method + " " + r.Host + r.URL.Path; claims.URI != want {
+		return fmt.Errorf("uri claim %q does not match %q", claims.URI, want)
+	}
+	return nil
+}
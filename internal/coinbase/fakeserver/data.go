@@ -0,0 +1,241 @@
+package fakeserver
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+
+	"cryptool/internal/coinbase"
+)
+
+// MaxCandles is the most candles one candles request may cover, as on Coinbase.
+const MaxCandles = 350
+
+// usdPrices are the reference prices around which generated prices move.
+var usdPrices = map[string]float64{
+	"BTC":  40000,
+	"ETH":  2500,
+	"SOL":  100,
+	"USD":  1,
+	"USDC": 1,
+	"USDT": 1,
+}
+
+// granularities maps the API granularity enum to seconds.
+var granularities = map[string]int64{
+	"ONE_MINUTE":     60,
+	"FIVE_MINUTE":    5 * 60,
+	"FIFTEEN_MINUTE": 15 * 60,
+	"THIRTY_MINUTE":  30 * 60,
+	"ONE_HOUR":       60 * 60,
+	"TWO_HOUR":       2 * 60 * 60,
+	"SIX_HOUR":       6 * 60 * 60,
+	"ONE_DAY":        24 * 60 * 60,
+}
+
+func usdPrice(asset string) float64 {
+	if p, ok := usdPrices[asset]; ok {
+		return p
+	}
+	return 10
+}
+
+// priceDecimals is the number of decimals prices of product are quoted with.
+func priceDecimals(product string) int {
+	_, quote, _ := strings.Cut(product, "-")
+	if usdPrice(quote) == 1 {
+		return 2
+	}
+	return 6
+}
+
+// noise returns a deterministic value in [0, 1) for the seed, product, time and salt.
+func (s *Server) noise(product string, ts int64, salt byte) float64 {
+	h := fnv.New64a()
+	var buf [17]byte
+	binary.LittleEndian.PutUint64(buf[:8], uint64(s.opts.Seed))
+	binary.LittleEndian.PutUint64(buf[8:16], uint64(ts))
+	buf[16] = salt
+	h.Write(buf[:])
+	h.Write([]byte(product))
+	return float64(h.Sum64()>>11) / float64(1<<53)
+}
+
+// price is the generated price of product at t: a weekly and a daily wave around the
+// reference price plus a little noise. It depends only on t, so any granularity agrees.
+func (s *Server) price(product string, t time.Time) float64 {
+	base, quote, _ := strings.Cut(product, "-")
+	ref := usdPrice(base) / usdPrice(quote)
+	sec := float64(t.Unix())
+	phase := s.noise(product, 0, 0) * 2 * math.Pi
+	wave := 0.05*math.Sin(2*math.Pi*sec/(7*86400)+phase) + 0.02*math.Sin(2*math.Pi*sec/86400+phase)
+	return round(ref*(1+wave+(s.noise(product, t.Unix(), 1)-0.5)*0.004), priceDecimals(product))
+}
+
+func round(v float64, decimals int) float64 {
+	p := math.Pow10(decimals)
+	return math.Round(v*p) / p
+}
+
+func format(v float64, decimals int) string {
+	return strconv.FormatFloat(v, 'f', decimals, 64)
+}
+
+// isHole reports whether the bucket at t of product has no data.
+func (s *Server) isHole(product string, t time.Time) bool {
+	for _, h := range s.opts.Holes {
+		if (h.Product == "" || h.Product == product) && !t.Before(h.Start) && t.Before(h.End) {
+			return true
+		}
+	}
+	return s.opts.HoleRate > 0 && s.noise(product, t.Unix(), 2) < s.opts.HoleRate
+}
+
+func (s *Server) hasProduct(id string) bool {
+	for _, p := range s.opts.Products {
+		if p == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) handleProducts(w http.ResponseWriter, r *http.Request) {
+	now := s.opts.Now()
+	products := make([]coinbase.Product, 0, len(s.opts.Products))
+	for _, id := range s.opts.Products {
+		base, quote, _ := strings.Cut(id, "-")
+		dec := priceDecimals(id)
+		increment := format(math.Pow10(-dec), dec)
+		products = append(products, coinbase.Product{
+			ProductID:       id,
+			Price:           format(s.price(id, now.Truncate(time.Minute)), dec),
+			Volume24h:       "1000",
+			BaseIncrement:   "0.00000001",
+			QuoteIncrement:  increment,
+			PriceIncrement:  increment,
+			BaseMinSize:     "0.00000001",
+			BaseMaxSize:     "1000000",
+			QuoteMinSize:    "1",
+			QuoteMaxSize:    "10000000",
+			BaseName:        base,
+			QuoteName:       quote,
+			Status:          "online",
+			ProductType:     "SPOT",
+			BaseCurrencyID:  base,
+			QuoteCurrencyID: quote,
+			DisplayName:     base + "/" + quote,
+			ProductVenue:    "CBE",
+			NewAt:           s.opts.ListedAt,
+		})
+	}
+	writeJSON(w, coinbase.ListProductsResponse{Products: products, NumProducts: len(products)})
+}
+
+// candle is the wire format of one candle.
+type candle struct {
+	Start  string `json:"start"`
+	Low    string `json:"low"`
+	High   string `json:"high"`
+	Open   string `json:"open"`
+	Close  string `json:"close"`
+	Volume string `json:"volume"`
+}
+
+// handleCandles serves the buckets starting in [start, end], newest first, that are after
+// the listing date, not in the future and not holes.
+func (s *Server) handleCandles(w http.ResponseWriter, r *http.Request) {
+	product := r.PathValue("id")
+	if !s.hasProduct(product) {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "ProductID "+product+" not found")
+		return
+	}
+	q := r.URL.Query()
+	gran, ok := granularities[q.Get("granularity")]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "invalid granularity "+q.Get("granularity"))
+		return
+	}
+	startSec, err1 := strconv.ParseInt(q.Get("start"), 10, 64)
+	endSec, err2 := strconv.ParseInt(q.Get("end"), 10, 64)
+	if err1 != nil || err2 != nil || endSec < startSec {
+		writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "start and end must be unix seconds with start <= end")
+		return
+	}
+	limit := queryInt(r, "limit", MaxCandles)
+	first := (startSec + gran - 1) / gran * gran
+	if n := (endSec-first)/gran + 1; n > int64(limit) || n > MaxCandles {
+		writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT",
+			"number of candles requested should be less than or equal to "+strconv.Itoa(MaxCandles))
+		return
+	}
+
+	listed := s.opts.ListedAt.Unix()
+	now := s.opts.Now().Unix()
+	dec := priceDecimals(product)
+	out := []candle{}
+	for ts := endSec / gran * gran; ts >= first; ts -= gran {
+		t := time.Unix(ts, 0).UTC()
+		if ts < listed || ts > now || s.isHole(product, t) {
+			continue
+		}
+		open := s.price(product, t)
+		cls := s.price(product, t.Add(time.Duration(gran)*time.Second))
+		high := round(math.Max(open, cls)*(1+0.003*s.noise(product, ts, 3)), dec)
+		low := round(math.Min(open, cls)*(1-0.003*s.noise(product, ts, 4)), dec)
+		volume := float64(gran) / 60 * (0.5 + 9.5*s.noise(product, ts, 5))
+		out = append(out, candle{
+			Start:  strconv.FormatInt(ts, 10),
+			Low:    format(low, dec),
+			High:   format(high, dec),
+			Open:   format(open, dec),
+			Close:  format(cls, dec),
+			Volume: format(volume, 8),
+		})
+	}
+	writeJSON(w, map[string][]candle{"candles": out})
+}
+
+// handleAccounts pages through one account per configured balance, ordered by currency.
+func (s *Server) handleAccounts(w http.ResponseWriter, r *http.Request) {
+	currencies := make([]string, 0, len(s.opts.Balances))
+	for cur := range s.opts.Balances {
+		currencies = append(currencies, cur)
+	}
+	sort.Strings(currencies)
+
+	page, cursor := paginate(len(currencies), queryInt(r, "limit", 49), r.URL.Query().Get("cursor"))
+
+	created := s.opts.ListedAt.Format(time.RFC3339)
+	resp := coinbase.ListAccountsResponse{Accounts: []coinbase.Account{}, Size: page[1] - page[0], Cursor: cursor}
+	resp.HasNext = cursor != ""
+	for _, cur := range currencies[page[0]:page[1]] {
+		resp.Accounts = append(resp.Accounts, coinbase.Account{
+			UUID:             accountID(cur),
+			Name:             cur + " Wallet",
+			Currency:         cur,
+			AvailableBalance: coinbase.Balance{Value: s.opts.Balances[cur], Currency: cur},
+			Default:          true,
+			Active:           true,
+			CreatedAt:        created,
+			UpdatedAt:        created,
+			Type:             "ACCOUNT_TYPE_CRYPTO",
+			Ready:            true,
+			Hold:             coinbase.Balance{Value: decimal.Zero, Currency: cur},
+		})
+	}
+	writeJSON(w, resp)
+}
+
+// accountID is the stable UUID of the fake account holding currency.
+func accountID(currency string) string {
+	return uuid.NewSHA1(uuid.NameSpaceOID, []byte("fake-coinbase/account/"+currency)).String()
+}
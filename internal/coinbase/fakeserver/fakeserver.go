@@ -0,0 +1,201 @@
+// Package fakeserver is an in-memory stand-in for the Coinbase Advanced Trade REST API. It
+// serves products, candles, accounts and orders from deterministic generated data, can inject
+// 429 and 5xx responses and candle holes, and optionally verifies CDP JWTs the way Coinbase
+// does. Point a client at it with coinbase.WithBaseURL or the COINBASE_BASE_URL config key.
+package fakeserver
+
+import (
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"cryptool/internal/coinbase"
+)
+
+// DefaultProducts are served when Options.Products is empty.
+var DefaultProducts = []string{"BTC-USD", "ETH-USD", "SOL-USD", "ETH-BTC"}
+
+// Options configures a Server. The zero value serves DefaultProducts without auth or failures.
+type Options struct {
+	// Products are the product IDs to serve.
+	Products []string
+	// Seed varies the generated prices and volumes; the same seed always yields the same data.
+	Seed int64
+	// ListedAt is the new_at date of every product and the first candle served. It defaults
+	// to two days before Now, truncated to the day.
+	ListedAt time.Time
+	// Balances maps currency to the available balance of its account. Nil uses a USD, BTC and ETH wallet.
+	Balances map[string]decimal.Decimal
+
+	// HoleRate is the fraction of candle buckets, chosen deterministically, that have no data.
+	HoleRate float64
+	// Holes are ranges [Start, End) with no candles for Product ("" matches every product).
+	Holes []Hole
+
+	// ErrorEvery makes every n-th request fail with ErrorStatus (default 429). 0 disables it.
+	ErrorEvery  int
+	ErrorStatus int
+
+	// Keys maps CDP API key names to their public keys. When set, every endpoint except the
+	// public /market ones requires a valid ES256 JWT signed by one of them.
+	Keys map[string]*ecdsa.PublicKey
+
+	// Now is the server clock; candles after Now are not served. Defaults to time.Now.
+	Now func() time.Time
+}
+
+// Hole is a time range with no candles.
+type Hole struct {
+	Product    string
+	Start, End time.Time
+}
+
+// Server is an http.Handler that emulates the Coinbase Advanced Trade REST API.
+type Server struct {
+	opts Options
+	mux  *http.ServeMux
+
+	mu       sync.Mutex
+	requests int
+	failNext []int
+	orders   []*coinbase.Order
+	fills    []coinbase.Fill
+}
+
+// New returns a server for opts.
+func New(opts Options) *Server {
+	if len(opts.Products) == 0 {
+		opts.Products = DefaultProducts
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	if opts.ListedAt.IsZero() {
+		opts.ListedAt = opts.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -2)
+	}
+	if opts.Balances == nil {
+		opts.Balances = map[string]decimal.Decimal{
+			"USD": decimal.RequireFromString("10000.00"),
+			"BTC": decimal.RequireFromString("0.50000000"),
+			"ETH": decimal.RequireFromString("4.00000000"),
+		}
+	}
+	if opts.ErrorStatus == 0 {
+		opts.ErrorStatus = http.StatusTooManyRequests
+	}
+
+	s := &Server{opts: opts, mux: http.NewServeMux()}
+	const api = "/api/v3/brokerage"
+	s.mux.HandleFunc("GET "+api+"/products", s.handleProducts)
+	s.mux.HandleFunc("GET "+api+"/market/products", s.handleProducts)
+	s.mux.HandleFunc("GET "+api+"/products/{id}/candles", s.handleCandles)
+	s.mux.HandleFunc("GET "+api+"/market/products/{id}/candles", s.handleCandles)
+	s.mux.HandleFunc("GET "+api+"/accounts", s.handleAccounts)
+	s.mux.HandleFunc("POST "+api+"/orders", s.handleCreateOrder)
+	s.mux.HandleFunc("POST "+api+"/orders/preview", s.handlePreviewOrder)
+	s.mux.HandleFunc("POST "+api+"/orders/batch_cancel", s.handleCancelOrders)
+	s.mux.HandleFunc("POST "+api+"/orders/edit", s.handleEditOrder)
+	s.mux.HandleFunc("GET "+api+"/orders/historical/batch", s.handleListOrders)
+	s.mux.HandleFunc("GET "+api+"/orders/historical/fills", s.handleListFills)
+	s.mux.HandleFunc("GET "+api+"/orders/historical/{id}", s.handleGetOrder)
+	return s
+}
+
+// FailNext makes the next n requests fail with status, ahead of any ErrorEvery failures.
+func (s *Server) FailNext(status, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i < n; i++ {
+		s.failNext = append(s.failNext, status)
+	}
+}
+
+// Requests returns the number of requests received, including failed ones.
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if status := s.injectedFailure(); status != 0 {
+		if status == http.StatusTooManyRequests {
+			w.Header().Set("Retry-After", "1")
+			writeError(w, status, "RATE_LIMIT_EXCEEDED", "Too many requests")
+			return
+		}
+		writeError(w, status, "INTERNAL", "injected failure")
+		return
+	}
+	if !strings.HasPrefix(r.URL.Path, "/api/v3/brokerage/market/") {
+		if err := s.authenticate(r); err != nil {
+			writeError(w, http.StatusUnauthorized, "UNAUTHENTICATED", err.Error())
+			return
+		}
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// injectedFailure counts the request and returns the status to fail it with, or 0.
+func (s *Server) injectedFailure() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests++
+	if len(s.failNext) > 0 {
+		status := s.failNext[0]
+		s.failNext = s.failNext[1:]
+		return status
+	}
+	if s.opts.ErrorEvery > 0 && s.requests%s.opts.ErrorEvery == 0 {
+		return s.opts.ErrorStatus
+	}
+	return 0
+}
+
+// ParsePublicKey reads the public key of a CDP API private key PEM. Literal \n sequences, as
+// found in .env files, are accepted.
+func ParsePublicKey(privateKeyPEM string) (*ecdsa.PublicKey, error) {
+	block, _ := pem.Decode([]byte(strings.ReplaceAll(privateKeyPEM, "\\n", "\n")))
+	if block == nil {
+		return nil, fmt.Errorf("invalid EC private key PEM")
+	}
+	pk, err := x509.ParseECPrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse EC private key: %w", err)
+	}
+	return &pk.PublicKey, nil
+}
+
+// writeJSON writes v as a 200 JSON response.
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes an error body in the shape Coinbase uses.
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error":         code,
+		"message":       message,
+		"error_details": message,
+	})
+}
+
+// queryInt returns the integer query parameter key, or def when it is missing or invalid.
+func queryInt(r *http.Request, key string, def int) int {
+	if v, err := strconv.Atoi(r.URL.Query().Get(key)); err == nil && v > 0 {
+		return v
+	}
+	return def
+}
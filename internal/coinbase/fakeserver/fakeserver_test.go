@@ -0,0 +1,260 @@
+package fakeserver_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"cryptool/internal/backfill"
+	"cryptool/internal/coinbase"
+	"cryptool/internal/coinbase/fakeserver"
+	"cryptool/internal/exchange"
+)
+
+var now = time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)
+
+func newServer(t *testing.T, opts fakeserver.Options) (*fakeserver.Server, *coinbase.Client) {
+	t.Helper()
+	opts.Now = func() time.Time { return now }
+	if opts.ListedAt.IsZero() {
+		opts.ListedAt = now.AddDate(0, 0, -1)
+	}
+	fake := fakeserver.New(opts)
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+
+	client := coinbase.NewClient("", "", "", coinbase.WithBaseURL(srv.URL))
+	client.Configure(0, 3, 1, false)
+	return fake, client
+}
+
+func TestRetriesInjectedFailures(t *testing.T) {
+	fake, client := newServer(t, fakeserver.Options{})
+	ctx := context.Background()
+
+	fake.FailNext(http.StatusServiceUnavailable, 2)
+	products, err := client.GetProducts(ctx)
+	if err != nil {
+		t.Fatalf("GetProducts: %v", err)
+	}
+	if len(products) != len(fakeserver.DefaultProducts) {
+		t.Errorf("got %d products, want %d", len(products), len(fakeserver.DefaultProducts))
+	}
+	if n := fake.Requests(); n != 3 {
+		t.Errorf("requests = %d, want 2 failures and 1 success", n)
+	}
+
+	// A retried POST must resend its body.
+	fake.FailNext(http.StatusTooManyRequests, 1)
+	res, err := client.CreateOrder(ctx, coinbase.NewMarketOrder("BTC-USD", coinbase.SideBuy, "", "100"))
+	if err != nil {
+		t.Fatalf("CreateOrder after 429: %v", err)
+	}
+	if !res.Success {
+		t.Errorf("order not created: %+v", res)
+	}
+
+	// Retries are exhausted after three attempts.
+	fake.FailNext(http.StatusInternalServerError, 3)
+	if _, err := client.ListAccounts(ctx); err == nil || !strings.Contains(err.Error(), "coinbase http 500") {
+		t.Errorf("ListAccounts with exhausted retries: err = %v", err)
+	}
+}
+
+func TestCandlesAreDeterministicWithHoles(t *testing.T) {
+	hole := fakeserver.Hole{Product: "BTC-USD", Start: now.Add(-30 * time.Minute), End: now.Add(-20 * time.Minute)}
+	_, client := newServer(t, fakeserver.Options{Seed: 7, Holes: []fakeserver.Hole{hole}})
+	_, other := newServer(t, fakeserver.Options{Seed: 7})
+	ctx := context.Background()
+
+	start, end := now.Add(-time.Hour), now.Add(-time.Minute)
+	candles, err := client.GetCandlesOnce(ctx, "BTC-USD", start, end, "1m", 350)
+	if err != nil {
+		t.Fatalf("GetCandlesOnce: %v", err)
+	}
+	if len(candles) != 50 {
+		t.Fatalf("got %d candles, want 60 minus a 10 minute hole", len(candles))
+	}
+	for _, c := range candles {
+		if !c.Time.Before(hole.Start) && c.Time.Before(hole.End) {
+			t.Errorf("candle at %s is inside the hole", c.Time)
+		}
+		if c.Low.GreaterThan(c.Open) || c.Low.GreaterThan(c.Close) || c.High.LessThan(c.Open) ||
+			c.High.LessThan(c.Close) || !c.Volume.IsPositive() {
+			t.Errorf("inconsistent candle %+v", c)
+		}
+	}
+
+	again, err := other.GetCandlesOnce(ctx, "BTC-USD", start, end, "1m", 350)
+	if err != nil {
+		t.Fatalf("GetCandlesOnce: %v", err)
+	}
+	if a, b := again[0], candles[0]; !a.Time.Equal(b.Time) || !a.Open.Equal(b.Open) || !a.Close.Equal(b.Close) || !a.Volume.Equal(b.Volume) {
+		t.Errorf("same seed served %+v and %+v", candles[0], again[0])
+	}
+
+	// Nothing is served before the listing date or beyond 350 buckets.
+	if before, err := client.GetCandlesOnce(ctx, "BTC-USD", now.AddDate(0, 0, -3), now.AddDate(0, 0, -3).Add(time.Hour), "1m", 350); err != nil || len(before) != 0 {
+		t.Errorf("candles before listing = %d, %v", len(before), err)
+	}
+	if _, err := client.GetCandlesOnce(ctx, "BTC-USD", now.Add(-24*time.Hour), now, "1m", 350); err == nil {
+		t.Error("expected an error for a range of more than 350 candles")
+	}
+}
+
+// memStore is an in-memory backfill.Store.
+type memStore struct {
+	mu      sync.Mutex
+	candles map[time.Time]exchange.Candle
+	gaps    map[time.Time]bool
+}
+
+func (s *memStore) CountGapsToFill(ctx context.Context, x, product string, start, end time.Time, sec int) (int, error) {
+	ts, err := s.GetMissingCandleTimestamps(ctx, x, product, start, end, sec)
+	return len(ts), err
+}
+
+func (s *memStore) GetMissingCandleTimestamps(ctx context.Context, x, product string, start, end time.Time, sec int) ([]time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []time.Time
+	for t := start; t.Before(end); t = t.Add(time.Duration(sec) * time.Second) {
+		if _, ok := s.candles[t]; !ok && !s.gaps[t] {
+			out = append(out, t)
+		}
+	}
+	return out, nil
+}
+
+func (s *memStore) InsertCandles(ctx context.Context, x, product string, sec int, candles []exchange.Candle) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range candles {
+		s.candles[c.Time] = c
+	}
+	return len(candles), nil
+}
+
+func (s *memStore) MarkGaps(ctx context.Context, x, product string, sec int, times []time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range times {
+		s.gaps[t] = true
+	}
+	return len(times), nil
+}
+
+func TestBackfillMarksHoles(t *testing.T) {
+	_, client := newServer(t, fakeserver.Options{HoleRate: 0.1})
+	store := &memStore{candles: map[time.Time]exchange.Candle{}, gaps: map[time.Time]bool{}}
+	b := backfill.New(coinbase.NewAdapter(client), store)
+	b.Now = func() time.Time { return now }
+
+	start := now.Add(-12 * time.Hour)
+	if _, err := b.Fill(context.Background(), "ETH-USD", "1m", start, now); err != nil {
+		t.Fatalf("Fill: %v", err)
+	}
+	if n := len(store.candles) + len(store.gaps); n != 12*60 {
+		t.Fatalf("stored %d buckets, want %d", n, 12*60)
+	}
+	if marked := len(store.gaps); marked == 0 || marked > 12*60/5 {
+		t.Errorf("%d buckets marked as gaps, want roughly 10%%", marked)
+	}
+}
+
+func TestJWTVerification(t *testing.T) {
+	good, goodPEM := newKey(t)
+	_, otherPEM := newKey(t)
+	const keyName = "organizations/o/apiKeys/k"
+
+	fake := fakeserver.New(fakeserver.Options{Keys: map[string]*ecdsa.PublicKey{keyName: good}})
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	ctx := context.Background()
+
+	client, err := coinbase.NewClientWithJWT(keyName, goodPEM, coinbase.WithBaseURL(srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.ListAccounts(ctx); err != nil {
+		t.Errorf("ListAccounts with a valid token: %v", err)
+	}
+
+	forged, err := coinbase.NewClientWithJWT(keyName, otherPEM, coinbase.WithBaseURL(srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := forged.ListAccounts(ctx); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("ListAccounts with a forged token: err = %v", err)
+	}
+
+	anonymous := coinbase.NewClient("", "", "", coinbase.WithBaseURL(srv.URL))
+	if _, err := anonymous.ListAccounts(ctx); err == nil {
+		t.Error("ListAccounts without a token should fail")
+	}
+	// Public market data needs no token.
+	if _, err := anonymous.GetCandlesOnce(ctx, "BTC-USD", time.Now().Add(-time.Hour), time.Now(), "1h", 350); err != nil {
+		t.Errorf("public candles: %v", err)
+	}
+}
+
+func TestOrderLifecycle(t *testing.T) {
+	_, client := newServer(t, fakeserver.Options{})
+	ctx := context.Background()
+
+	market, err := client.CreateOrder(ctx, coinbase.NewMarketOrder("ETH-USD", coinbase.SideBuy, "0.5", ""))
+	if err != nil {
+		t.Fatalf("market order: %v", err)
+	}
+	fills, err := client.ListFills(ctx, coinbase.ListFillsParams{OrderIDs: []string{market.OrderID}})
+	if err != nil || len(fills) != 1 || fills[0].Size.String() != "0.5" {
+		t.Fatalf("fills of market order = %+v, %v", fills, err)
+	}
+
+	limit, err := client.CreateOrder(ctx, coinbase.NewLimitOrder("ETH-USD", coinbase.SideSell, "1", "9999", false, time.Time{}))
+	if err != nil {
+		t.Fatalf("limit order: %v", err)
+	}
+	if _, err := client.EditOrder(ctx, coinbase.EditOrderRequest{OrderID: limit.OrderID, Price: "9000", Size: "1"}); err != nil {
+		t.Fatalf("EditOrder: %v", err)
+	}
+	open, err := client.ListOrders(ctx, coinbase.ListOrdersParams{OrderStatus: []string{"OPEN"}})
+	if err != nil || len(open) != 1 || open[0].OrderConfiguration.LimitLimitGTC.LimitPrice != "9000" {
+		t.Fatalf("open orders = %+v, %v", open, err)
+	}
+
+	results, err := client.CancelOrders(ctx, []string{limit.OrderID, market.OrderID})
+	if err != nil || len(results) != 2 || !results[0].Success || results[1].Success {
+		t.Fatalf("CancelOrders = %+v, %v", results, err)
+	}
+	order, err := client.GetOrder(ctx, limit.OrderID)
+	if err != nil || order.Status != "CANCELLED" {
+		t.Errorf("GetOrder = %+v, %v", order, err)
+	}
+
+	if _, err := client.CreateOrder(ctx, coinbase.NewMarketOrder("NOPE-USD", coinbase.SideBuy, "1", "")); err == nil {
+		t.Error("order for an unknown product should be rejected")
+	}
+}
+
+func newKey(t *testing.T) (*ecdsa.PublicKey, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &key.PublicKey, string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}))
+}
@@ -0,0 +1,366 @@
+package fakeserver
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+
+	"cryptool/internal/coinbase"
+)
+
+// feeRate is the taker fee charged on fills.
+const feeRate = 0.006
+
+// Order statuses used by the fake server.
+const (
+	statusOpen      = "OPEN"
+	statusFilled    = "FILLED"
+	statusCancelled = "CANCELLED"
+)
+
+func (s *Server) handleCreateOrder(w http.ResponseWriter, r *http.Request) {
+	var req coinbase.CreateOrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "invalid request body: "+err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Client order IDs make order creation idempotent.
+	for _, o := range s.orders {
+		if req.ClientOrderID != "" && o.ClientOrderID == req.ClientOrderID {
+			writeJSON(w, orderCreated(o))
+			return
+		}
+	}
+	if reason := s.validateOrder(req.ProductID, req.Side, req.OrderConfiguration); reason != "" {
+		writeJSON(w, coinbase.CreateOrderResponse{
+			FailureReason: "UNKNOWN_FAILURE_REASON",
+			ErrorResponse: &coinbase.OrderErrorResponse{
+				Error:                 reason,
+				Message:               strings.ToLower(strings.ReplaceAll(reason, "_", " ")),
+				NewOrderFailureReason: reason,
+			},
+			OrderConfiguration: &req.OrderConfiguration,
+		})
+		return
+	}
+
+	now := s.opts.Now().UTC()
+	o := &coinbase.Order{
+		OrderID:              uuid.NewSHA1(uuid.NameSpaceOID, []byte("fake-coinbase/order/"+strconv.Itoa(len(s.orders)))).String(),
+		ProductID:            req.ProductID,
+		UserID:               "fake-user",
+		OrderConfiguration:   req.OrderConfiguration,
+		Side:                 req.Side,
+		ClientOrderID:        req.ClientOrderID,
+		Status:               statusOpen,
+		TimeInForce:          "GOOD_UNTIL_CANCELLED",
+		CreatedTime:          now,
+		CompletionPercentage: "0",
+		FilledSize:           "0",
+		AverageFilledPrice:   "0",
+		NumberOfFills:        "0",
+		FilledValue:          "0",
+		TotalFees:            "0",
+		OrderType:            "LIMIT",
+		ProductType:          "SPOT",
+		OrderPlacementSource: "RETAIL_ADVANCED",
+	}
+	switch cfg := req.OrderConfiguration; {
+	case cfg.MarketMarketIOC != nil:
+		o.OrderType = "MARKET"
+		o.TimeInForce = "IMMEDIATE_OR_CANCEL"
+		s.fillMarketOrder(o, now)
+	case cfg.LimitLimitGTD != nil:
+		o.TimeInForce = "GOOD_UNTIL_DATE_TIME"
+	case cfg.StopLimitStopLimitGTC != nil:
+		o.OrderType = "STOP_LIMIT"
+	case cfg.StopLimitStopLimitGTD != nil:
+		o.OrderType = "STOP_LIMIT"
+		o.TimeInForce = "GOOD_UNTIL_DATE_TIME"
+	}
+	s.orders = append(s.orders, o)
+	writeJSON(w, orderCreated(o))
+}
+
+func orderCreated(o *coinbase.Order) coinbase.CreateOrderResponse {
+	return coinbase.CreateOrderResponse{
+		Success: true,
+		OrderID: o.OrderID,
+		SuccessResponse: &coinbase.OrderSuccess{
+			OrderID:       o.OrderID,
+			ProductID:     o.ProductID,
+			Side:          o.Side,
+			ClientOrderID: o.ClientOrderID,
+		},
+		OrderConfiguration: &o.OrderConfiguration,
+	}
+}
+
+// validateOrder returns a Coinbase failure reason, or "" when the order can be placed.
+func (s *Server) validateOrder(product, side string, cfg coinbase.OrderConfiguration) string {
+	if !s.hasProduct(product) {
+		return "INVALID_PRODUCT_ID"
+	}
+	if side != coinbase.SideBuy && side != coinbase.SideSell {
+		return "INVALID_SIDE"
+	}
+	n := 0
+	for _, set := range []bool{cfg.MarketMarketIOC != nil, cfg.LimitLimitGTC != nil, cfg.LimitLimitGTD != nil,
+		cfg.StopLimitStopLimitGTC != nil, cfg.StopLimitStopLimitGTD != nil} {
+		if set {
+			n++
+		}
+	}
+	if n != 1 {
+		return "INVALID_ORDER_CONFIGURATION"
+	}
+	if m := cfg.MarketMarketIOC; m != nil && parse(m.BaseSize) <= 0 && parse(m.QuoteSize) <= 0 {
+		return "INVALID_SIZE"
+	}
+	return ""
+}
+
+// fillMarketOrder fills o completely at the current generated price and records the fill.
+func (s *Server) fillMarketOrder(o *coinbase.Order, now time.Time) {
+	dec := priceDecimals(o.ProductID)
+	price := s.price(o.ProductID, now.Truncate(time.Minute))
+	size := parse(o.OrderConfiguration.MarketMarketIOC.BaseSize)
+	if size <= 0 {
+		o.SizeInQuote = true
+		size = round(parse(o.OrderConfiguration.MarketMarketIOC.QuoteSize)/price, 8)
+	}
+	value := size * price
+	fee := round(value*feeRate, dec)
+
+	o.Status = statusFilled
+	o.CompletionPercentage = "100"
+	o.FilledSize = format(size, 8)
+	o.AverageFilledPrice = format(price, dec)
+	o.NumberOfFills = "1"
+	o.FilledValue = format(value, dec)
+	o.TotalFees = format(fee, dec)
+	o.TotalValueAfterFees = format(value+fee, dec)
+	o.Settled = true
+	o.LastFillTime = &now
+
+	s.fills = append(s.fills, coinbase.Fill{
+		EntryID:            uuid.NewSHA1(uuid.NameSpaceOID, []byte("fake-coinbase/fill/"+o.OrderID)).String(),
+		TradeID:            strconv.Itoa(len(s.fills) + 1),
+		OrderID:            o.OrderID,
+		TradeTime:          now,
+		TradeType:          "FILL",
+		Price:              decimal.RequireFromString(o.AverageFilledPrice),
+		Size:               decimal.RequireFromString(o.FilledSize),
+		Commission:         decimal.RequireFromString(o.TotalFees),
+		ProductID:          o.ProductID,
+		SequenceTimestamp:  now,
+		LiquidityIndicator: "TAKER",
+		SizeInQuote:        o.SizeInQuote,
+		UserID:             o.UserID,
+		Side:               o.Side,
+	})
+}
+
+func (s *Server) handlePreviewOrder(w http.ResponseWriter, r *http.Request) {
+	var req coinbase.PreviewOrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "invalid request body: "+err.Error())
+		return
+	}
+	if reason := s.validateOrder(req.ProductID, req.Side, req.OrderConfiguration); reason != "" {
+		writeJSON(w, coinbase.PreviewOrderResponse{Errs: []string{"PREVIEW_" + reason}})
+		return
+	}
+
+	dec := priceDecimals(req.ProductID)
+	price := s.price(req.ProductID, s.opts.Now().Truncate(time.Minute))
+	tick := math.Pow10(-dec)
+	cfg := req.OrderConfiguration
+	var size float64
+	switch {
+	case cfg.MarketMarketIOC != nil:
+		if size = parse(cfg.MarketMarketIOC.BaseSize); size <= 0 {
+			size = parse(cfg.MarketMarketIOC.QuoteSize) / price
+		}
+	case cfg.LimitLimitGTC != nil:
+		size, price = parse(cfg.LimitLimitGTC.BaseSize), parse(cfg.LimitLimitGTC.LimitPrice)
+	case cfg.LimitLimitGTD != nil:
+		size, price = parse(cfg.LimitLimitGTD.BaseSize), parse(cfg.LimitLimitGTD.LimitPrice)
+	case cfg.StopLimitStopLimitGTC != nil:
+		size, price = parse(cfg.StopLimitStopLimitGTC.BaseSize), parse(cfg.StopLimitStopLimitGTC.LimitPrice)
+	case cfg.StopLimitStopLimitGTD != nil:
+		size, price = parse(cfg.StopLimitStopLimitGTD.BaseSize), parse(cfg.StopLimitStopLimitGTD.LimitPrice)
+	}
+	value := size * price
+	fee := value * feeRate
+	writeJSON(w, coinbase.PreviewOrderResponse{
+		OrderTotal:      format(value+fee, dec),
+		CommissionTotal: format(fee, dec),
+		Errs:            []string{},
+		Warning:         []string{},
+		QuoteSize:       format(value, dec),
+		BaseSize:        format(size, 8),
+		BestBid:         format(price-tick, dec),
+		BestAsk:         format(price+tick, dec),
+		Slippage:        "0",
+		PreviewID:       uuid.NewSHA1(uuid.NameSpaceOID, []byte("fake-coinbase/preview/"+req.ProductID)).String(),
+	})
+}
+
+func (s *Server) handleCancelOrders(w http.ResponseWriter, r *http.Request) {
+	var req coinbase.CancelOrdersRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "invalid request body: "+err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	results := make([]coinbase.CancelOrderResult, 0, len(req.OrderIDs))
+	for _, id := range req.OrderIDs {
+		res := coinbase.CancelOrderResult{OrderID: id}
+		switch o := s.findOrder(id); {
+		case o == nil:
+			res.FailureReason = "UNKNOWN_CANCEL_ORDER"
+		case o.Status != statusOpen:
+			res.FailureReason = "INVALID_CANCEL_REQUEST"
+		default:
+			o.Status = statusCancelled
+			res.Success = true
+		}
+		results = append(results, res)
+	}
+	writeJSON(w, coinbase.CancelOrdersResponse{Results: results})
+}
+
+func (s *Server) handleEditOrder(w http.ResponseWriter, r *http.Request) {
+	var req coinbase.EditOrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "invalid request body: "+err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o := s.findOrder(req.OrderID)
+	var reason string
+	switch {
+	case o == nil:
+		reason = "ORDER_NOT_FOUND"
+	case o.Status != statusOpen || o.OrderConfiguration.LimitLimitGTC == nil:
+		reason = "CANNOT_EDIT_ORDER"
+	}
+	if reason != "" {
+		writeJSON(w, coinbase.EditOrderResponse{Errors: []coinbase.EditOrderError{{EditFailureReason: reason}}})
+		return
+	}
+	if req.Price != "" {
+		o.OrderConfiguration.LimitLimitGTC.LimitPrice = req.Price
+	}
+	if req.Size != "" {
+		o.OrderConfiguration.LimitLimitGTC.BaseSize = req.Size
+	}
+	writeJSON(w, coinbase.EditOrderResponse{Success: true, Errors: []coinbase.EditOrderError{}})
+}
+
+// handleListOrders pages through the orders matching the filters, newest first.
+func (s *Server) handleListOrders(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	s.mu.Lock()
+	var matched []coinbase.Order
+	for i := len(s.orders) - 1; i >= 0; i-- {
+		o := s.orders[i]
+		if matches(q["product_ids"], o.ProductID) && matches(q["order_status"], o.Status) &&
+			matches(q["order_side"], o.Side) {
+			matched = append(matched, *o)
+		}
+	}
+	s.mu.Unlock()
+
+	page, cursor := paginate(len(matched), queryInt(r, "limit", 1000), q.Get("cursor"))
+	resp := coinbase.ListOrdersResponse{Orders: append([]coinbase.Order{}, matched[page[0]:page[1]]...), Cursor: cursor}
+	resp.HasNext = cursor != ""
+	writeJSON(w, resp)
+}
+
+func (s *Server) handleGetOrder(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	o := s.findOrder(r.PathValue("id"))
+	var out coinbase.Order
+	if o != nil {
+		out = *o
+	}
+	s.mu.Unlock()
+	if o == nil {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "order "+r.PathValue("id")+" not found")
+		return
+	}
+	writeJSON(w, coinbase.GetOrderResponse{Order: out})
+}
+
+// handleListFills pages through the fills matching the filters, oldest first.
+func (s *Server) handleListFills(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	s.mu.Lock()
+	var matched []coinbase.Fill
+	for _, f := range s.fills {
+		if matches(q["order_ids"], f.OrderID) && matches(q["product_ids"], f.ProductID) {
+			matched = append(matched, f)
+		}
+	}
+	s.mu.Unlock()
+
+	page, cursor := paginate(len(matched), queryInt(r, "limit", 1000), q.Get("cursor"))
+	writeJSON(w, coinbase.ListFillsResponse{Fills: append([]coinbase.Fill{}, matched[page[0]:page[1]]...), Cursor: cursor})
+}
+
+// findOrder returns the order with id. The caller must hold s.mu.
+func (s *Server) findOrder(id string) *coinbase.Order {
+	for _, o := range s.orders {
+		if o.OrderID == id {
+			return o
+		}
+	}
+	return nil
+}
+
+// matches reports whether v is in filter; an empty filter matches everything.
+func matches(filter []string, v string) bool {
+	if len(filter) == 0 {
+		return true
+	}
+	for _, f := range filter {
+		if f == v {
+			return true
+		}
+	}
+	return false
+}
+
+// paginate returns the [from, to) slice bounds of the page at cursor and the next cursor.
+func paginate(n, limit int, cursor string) ([2]int, string) {
+	from, _ := strconv.Atoi(cursor)
+	if from < 0 || from > n {
+		from = n
+	}
+	to := from + limit
+	if to >= n {
+		return [2]int{from, n}, ""
+	}
+	return [2]int{from, to}, strconv.Itoa(to)
+}
+
+func parse(s string) float64 {
+	f, _ := strconv.ParseFloat(s, 64)
+	return f
+}
@@ -1,15 +1,15 @@
 package coinbase
 
-import "time"
+import (
+	"time"
 
-type Candle struct {
-	Time   time.Time
-	Open   float64
-	High   float64
-	Low    float64
-	Close  float64
-	Volume float64
-}
+	"github.com/shopspring/decimal"
+
+	"cryptool/internal/exchange"
+)
+
+// Candle is the exchange-neutral candle type.
+type Candle = exchange.Candle
 
 type ListAccountsResponse struct {
 	Accounts []Account `json:"accounts"`
@@ -128,7 +128,234 @@ type Account struct {
 	Hold             Balance `json:"hold"`
 }
 
+// Balance is an amount of Currency. Value is exact; a malformed value fails decoding.
 type Balance struct {
-	Value    string `json:"value"`
-	Currency string `json:"currency"`
+	Value    decimal.Decimal `json:"value"`
+	Currency string          `json:"currency"`
+}
+
+// Order sides
+const (
+	SideBuy  = "BUY"
+	SideSell = "SELL"
+)
+
+// Stop directions for stop-limit orders
+const (
+	StopDirectionUp   = "STOP_DIRECTION_STOP_UP"
+	StopDirectionDown = "STOP_DIRECTION_STOP_DOWN"
+)
+
+// OrderConfiguration holds exactly one of the supported order types.
+type OrderConfiguration struct {
+	MarketMarketIOC       *MarketMarketIOC       `json:"market_market_ioc,omitempty"`
+	LimitLimitGTC         *LimitLimitGTC         `json:"limit_limit_gtc,omitempty"`
+	LimitLimitGTD         *LimitLimitGTD         `json:"limit_limit_gtd,omitempty"`
+	StopLimitStopLimitGTC *StopLimitStopLimitGTC `json:"stop_limit_stop_limit_gtc,omitempty"`
+	StopLimitStopLimitGTD *StopLimitStopLimitGTD `json:"stop_limit_stop_limit_gtd,omitempty"`
+}
+
+// MarketMarketIOC is a market order sized in either quote or base currency.
+type MarketMarketIOC struct {
+	QuoteSize string `json:"quote_size,omitempty"`
+	BaseSize  string `json:"base_size,omitempty"`
+}
+
+type LimitLimitGTC struct {
+	BaseSize   string `json:"base_size"`
+	LimitPrice string `json:"limit_price"`
+	PostOnly   bool   `json:"post_only"`
+}
+
+type LimitLimitGTD struct {
+	BaseSize   string    `json:"base_size"`
+	LimitPrice string    `json:"limit_price"`
+	EndTime    time.Time `json:"end_time"`
+	PostOnly   bool      `json:"post_only"`
+}
+
+type StopLimitStopLimitGTC struct {
+	BaseSize      string `json:"base_size"`
+	LimitPrice    string `json:"limit_price"`
+	StopPrice     string `json:"stop_price"`
+	StopDirection string `json:"stop_direction"`
+}
+
+type StopLimitStopLimitGTD struct {
+	BaseSize      string    `json:"base_size"`
+	LimitPrice    string    `json:"limit_price"`
+	StopPrice     string    `json:"stop_price"`
+	EndTime       time.Time `json:"end_time"`
+	StopDirection string    `json:"stop_direction"`
+}
+
+// CreateOrderRequest is the body of POST /orders. ClientOrderID makes the request idempotent.
+type CreateOrderRequest struct {
+	ClientOrderID      string             `json:"client_order_id"`
+	ProductID          string             `json:"product_id"`
+	Side               string             `json:"side"`
+	OrderConfiguration OrderConfiguration `json:"order_configuration"`
+}
+
+type CreateOrderResponse struct {
+	Success            bool                `json:"success"`
+	FailureReason      string              `json:"failure_reason,omitempty"`
+	OrderID            string              `json:"order_id,omitempty"`
+	SuccessResponse    *OrderSuccess       `json:"success_response,omitempty"`
+	ErrorResponse      *OrderErrorResponse `json:"error_response,omitempty"`
+	OrderConfiguration *OrderConfiguration `json:"order_configuration,omitempty"`
+}
+
+type OrderSuccess struct {
+	OrderID       string `json:"order_id"`
+	ProductID     string `json:"product_id"`
+	Side          string `json:"side"`
+	ClientOrderID string `json:"client_order_id"`
+}
+
+type OrderErrorResponse struct {
+	Error                 string `json:"error"`
+	Message               string `json:"message"`
+	ErrorDetails          string `json:"error_details"`
+	PreviewFailureReason  string `json:"preview_failure_reason"`
+	NewOrderFailureReason string `json:"new_order_failure_reason"`
+}
+
+// PreviewOrderRequest is the body of POST /orders/preview.
+type PreviewOrderRequest struct {
+	ProductID          string             `json:"product_id"`
+	Side               string             `json:"side"`
+	OrderConfiguration OrderConfiguration `json:"order_configuration"`
+}
+
+type PreviewOrderResponse struct {
+	OrderTotal      string   `json:"order_total"`
+	CommissionTotal string   `json:"commission_total"`
+	Errs            []string `json:"errs"`
+	Warning         []string `json:"warning"`
+	QuoteSize       string   `json:"quote_size"`
+	BaseSize        string   `json:"base_size"`
+	BestBid         string   `json:"best_bid"`
+	BestAsk         string   `json:"best_ask"`
+	IsMax           bool     `json:"is_max"`
+	Slippage        string   `json:"slippage"`
+	PreviewID       string   `json:"preview_id"`
+}
+
+// CancelOrdersRequest is the body of POST /orders/batch_cancel.
+type CancelOrdersRequest struct {
+	OrderIDs []string `json:"order_ids"`
+}
+
+type CancelOrdersResponse struct {
+	Results []CancelOrderResult `json:"results"`
+}
+
+type CancelOrderResult struct {
+	Success       bool   `json:"success"`
+	FailureReason string `json:"failure_reason"`
+	OrderID       string `json:"order_id"`
+}
+
+// EditOrderRequest is the body of POST /orders/edit. Only GTC limit orders can be edited.
+type EditOrderRequest struct {
+	OrderID string `json:"order_id"`
+	Price   string `json:"price"`
+	Size    string `json:"size"`
+}
+
+type EditOrderResponse struct {
+	Success bool             `json:"success"`
+	Errors  []EditOrderError `json:"errors"`
+}
+
+type EditOrderError struct {
+	EditFailureReason    string `json:"edit_failure_reason"`
+	PreviewFailureReason string `json:"preview_failure_reason"`
+}
+
+// ListOrdersParams filters GET /orders/historical/batch. Zero values are omitted.
+type ListOrdersParams struct {
+	ProductIDs  []string
+	OrderStatus []string
+	OrderSide   string
+	StartDate   time.Time
+	EndDate     time.Time
+	Limit       int
+}
+
+type ListOrdersResponse struct {
+	Orders   []Order `json:"orders"`
+	Sequence string  `json:"sequence"`
+	HasNext  bool    `json:"has_next"`
+	Cursor   string  `json:"cursor"`
+}
+
+type GetOrderResponse struct {
+	Order Order `json:"order"`
+}
+
+// Order is a historical or open order.
+type Order struct {
+	OrderID               string             `json:"order_id"`
+	ProductID             string             `json:"product_id"`
+	UserID                string             `json:"user_id"`
+	OrderConfiguration    OrderConfiguration `json:"order_configuration"`
+	Side                  string             `json:"side"`
+	ClientOrderID         string             `json:"client_order_id"`
+	Status                string             `json:"status"`
+	TimeInForce           string             `json:"time_in_force"`
+	CreatedTime           time.Time          `json:"created_time"`
+	CompletionPercentage  string             `json:"completion_percentage"`
+	FilledSize            string             `json:"filled_size"`
+	AverageFilledPrice    string             `json:"average_filled_price"`
+	NumberOfFills         string             `json:"number_of_fills"`
+	FilledValue           string             `json:"filled_value"`
+	PendingCancel         bool               `json:"pending_cancel"`
+	SizeInQuote           bool               `json:"size_in_quote"`
+	TotalFees             string             `json:"total_fees"`
+	SizeInclusiveOfFees   bool               `json:"size_inclusive_of_fees"`
+	TotalValueAfterFees   string             `json:"total_value_after_fees"`
+	TriggerStatus         string             `json:"trigger_status"`
+	OrderType             string             `json:"order_type"`
+	RejectReason          string             `json:"reject_reason"`
+	Settled               bool               `json:"settled"`
+	ProductType           string             `json:"product_type"`
+	RejectMessage         string             `json:"reject_message"`
+	CancelMessage         string             `json:"cancel_message"`
+	OrderPlacementSource  string             `json:"order_placement_source"`
+	OutstandingHoldAmount string             `json:"outstanding_hold_amount"`
+	LastFillTime          *time.Time         `json:"last_fill_time,omitempty"`
+}
+
+// Fill is a single execution against an order. Price, Size and Commission are exact.
+type Fill struct {
+	EntryID            string          `json:"entry_id"`
+	TradeID            string          `json:"trade_id"`
+	OrderID            string          `json:"order_id"`
+	TradeTime          time.Time       `json:"trade_time"`
+	TradeType          string          `json:"trade_type"`
+	Price              decimal.Decimal `json:"price"`
+	Size               decimal.Decimal `json:"size"`
+	Commission         decimal.Decimal `json:"commission"`
+	ProductID          string          `json:"product_id"`
+	SequenceTimestamp  time.Time       `json:"sequence_timestamp"`
+	LiquidityIndicator string          `json:"liquidity_indicator"`
+	SizeInQuote        bool            `json:"size_in_quote"`
+	UserID             string          `json:"user_id"`
+	Side               string          `json:"side"`
+	RetailPortfolioID  string          `json:"retail_portfolio_id"`
+}
+
+// ListFillsParams filters GET /orders/historical/fills. Zero values are omitted.
+type ListFillsParams struct {
+	OrderIDs   []string
+	ProductIDs []string
+	Start      time.Time // start_sequence_timestamp, inclusive
+	End        time.Time // end_sequence_timestamp, exclusive
+}
+
+type ListFillsResponse struct {
+	Fills  []Fill `json:"fills"`
+	Cursor string `json:"cursor"`
 }
@@ -0,0 +1,204 @@
+package coinbase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// NewClientOrderID returns a random client order ID. Reusing the same ID for a retried
+// CreateOrder call lets Coinbase deduplicate the order.
+func NewClientOrderID() string {
+	return uuid.NewString()
+}
+
+// NewMarketOrder builds a market IOC order. Exactly one of baseSize or quoteSize should be set.
+func NewMarketOrder(productID, side, baseSize, quoteSize string) CreateOrderRequest {
+	return CreateOrderRequest{
+		ProductID: productID,
+		Side:      side,
+		OrderConfiguration: OrderConfiguration{
+			MarketMarketIOC: &MarketMarketIOC{BaseSize: baseSize, QuoteSize: quoteSize},
+		},
+	}
+}
+
+// NewLimitOrder builds a limit order. A zero endTime creates a GTC order, otherwise GTD.
+func NewLimitOrder(productID, side, baseSize, limitPrice string, postOnly bool, endTime time.Time) CreateOrderRequest {
+	req := CreateOrderRequest{ProductID: productID, Side: side}
+	if endTime.IsZero() {
+		req.OrderConfiguration.LimitLimitGTC = &LimitLimitGTC{BaseSize: baseSize, LimitPrice: limitPrice, PostOnly: postOnly}
+	} else {
+		req.OrderConfiguration.LimitLimitGTD = &LimitLimitGTD{BaseSize: baseSize, LimitPrice: limitPrice, EndTime: endTime.UTC(), PostOnly: postOnly}
+	}
+	return req
+}
+
+// NewStopLimitOrder builds a stop-limit order. A zero endTime creates a GTC order, otherwise GTD.
+func NewStopLimitOrder(productID, side, baseSize, limitPrice, stopPrice, stopDirection string, endTime time.Time) CreateOrderRequest {
+	req := CreateOrderRequest{ProductID: productID, Side: side}
+	if endTime.IsZero() {
+		req.OrderConfiguration.StopLimitStopLimitGTC = &StopLimitStopLimitGTC{
+			BaseSize: baseSize, LimitPrice: limitPrice, StopPrice: stopPrice, StopDirection: stopDirection,
+		}
+	} else {
+		req.OrderConfiguration.StopLimitStopLimitGTD = &StopLimitStopLimitGTD{
+			BaseSize: baseSize, LimitPrice: limitPrice, StopPrice: stopPrice, StopDirection: stopDirection, EndTime: endTime.UTC(),
+		}
+	}
+	return req
+}
+
+// CreateOrder places an order. A client order ID is generated when req.ClientOrderID is empty.
+// When Coinbase rejects the order the response is returned together with an error.
+func (c *Client) CreateOrder(ctx context.Context, req CreateOrderRequest) (*CreateOrderResponse, error) {
+	if req.ClientOrderID == "" {
+		req.ClientOrderID = NewClientOrderID()
+	}
+	var out CreateOrderResponse
+	if err := c.doJSON(ctx, http.MethodPost, "/api/v3/brokerage/orders", nil, req, &out); err != nil {
+		return nil, err
+	}
+	if !out.Success {
+		return &out, fmt.Errorf("create order rejected: %s", orderFailure(&out))
+	}
+	return &out, nil
+}
+
+// PreviewOrder simulates an order and returns the expected totals, fees and any validation errors.
+func (c *Client) PreviewOrder(ctx context.Context, req PreviewOrderRequest) (*PreviewOrderResponse, error) {
+	var out PreviewOrderResponse
+	if err := c.doJSON(ctx, http.MethodPost, "/api/v3/brokerage/orders/preview", nil, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CancelOrders cancels the given orders in one batch and returns a result per order.
+func (c *Client) CancelOrders(ctx context.Context, orderIDs []string) ([]CancelOrderResult, error) {
+	var out CancelOrdersResponse
+	if err := c.doJSON(ctx, http.MethodPost, "/api/v3/brokerage/orders/batch_cancel", nil, CancelOrdersRequest{OrderIDs: orderIDs}, &out); err != nil {
+		return nil, err
+	}
+	return out.Results, nil
+}
+
+// EditOrder changes the price and size of an open GTC limit order.
+func (c *Client) EditOrder(ctx context.Context, req EditOrderRequest) (*EditOrderResponse, error) {
+	var out EditOrderResponse
+	if err := c.doJSON(ctx, http.MethodPost, "/api/v3/brokerage/orders/edit", nil, req, &out); err != nil {
+		return nil, err
+	}
+	if !out.Success {
+		reasons := make([]string, 0, len(out.Errors))
+		for _, e := range out.Errors {
+			reasons = append(reasons, strings.Trim(e.EditFailureReason+" "+e.PreviewFailureReason, " "))
+		}
+		return &out, fmt.Errorf("edit order rejected: %s", strings.Join(reasons, "; "))
+	}
+	return &out, nil
+}
+
+// ListOrders returns all orders matching params, following the cursor across pages.
+func (c *Client) ListOrders(ctx context.Context, params ListOrdersParams) ([]Order, error) {
+	q := url.Values{}
+	for _, p := range params.ProductIDs {
+		q.Add("product_ids", p)
+	}
+	for _, s := range params.OrderStatus {
+		q.Add("order_status", s)
+	}
+	if params.OrderSide != "" {
+		q.Set("order_side", params.OrderSide)
+	}
+	if !params.StartDate.IsZero() {
+		q.Set("start_date", params.StartDate.UTC().Format(time.RFC3339))
+	}
+	if !params.EndDate.IsZero() {
+		q.Set("end_date", params.EndDate.UTC().Format(time.RFC3339))
+	}
+	limit := params.Limit
+	if limit <= 0 {
+		limit = 1000
+	}
+	q.Set("limit", strconv.Itoa(limit))
+
+	var all []Order
+	for {
+		var page ListOrdersResponse
+		if err := c.doJSON(ctx, http.MethodGet, "/api/v3/brokerage/orders/historical/batch", q, nil, &page); err != nil {
+			return nil, err
+		}
+		all = append(all, page.Orders...)
+		if params.Limit > 0 && len(all) >= params.Limit {
+			return all[:params.Limit], nil
+		}
+		if !page.HasNext || page.Cursor == "" {
+			break
+		}
+		q.Set("cursor", page.Cursor)
+	}
+	return all, nil
+}
+
+// GetOrder returns a single order by its exchange order ID.
+func (c *Client) GetOrder(ctx context.Context, orderID string) (*Order, error) {
+	var out GetOrderResponse
+	path := "/api/v3/brokerage/orders/historical/" + url.PathEscape(orderID)
+	if err := c.doJSON(ctx, http.MethodGet, path, nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out.Order, nil
+}
+
+// doJSON sends an authenticated request with an optional JSON body and decodes the JSON response into out.
+func (c *Client) doJSON(ctx context.Context, method, path string, query url.Values, in, out interface{}) error {
+	body := ""
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("encode request: %w", err)
+		}
+		body = string(b)
+	}
+	resp, err := c.do(ctx, method, path, query, body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return newAPIError(resp)
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode %s: %w", path, err)
+	}
+	return nil
+}
+
+func orderFailure(r *CreateOrderResponse) string {
+	if e := r.ErrorResponse; e != nil {
+		parts := []string{}
+		for _, s := range []string{e.Error, e.Message, e.ErrorDetails, e.PreviewFailureReason, e.NewOrderFailureReason} {
+			if s != "" && s != "UNKNOWN_FAILURE_REASON" {
+				parts = append(parts, s)
+			}
+		}
+		if len(parts) > 0 {
+			return strings.Join(parts, ": ")
+		}
+	}
+	if r.FailureReason != "" {
+		return r.FailureReason
+	}
+	return "unknown failure"
+}
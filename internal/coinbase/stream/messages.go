@@ -0,0 +1,304 @@
+package stream
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"cryptool/internal/coinbase"
+	"cryptool/internal/exchange"
+)
+
+// Ticker is a price update from the ticker channel.
+type Ticker struct {
+	ProductID             string
+	Time                  time.Time
+	Price                 decimal.Decimal
+	Volume24h             decimal.Decimal
+	Low24h                decimal.Decimal
+	High24h               decimal.Decimal
+	PricePercentChange24h decimal.Decimal
+	BestBid               decimal.Decimal
+	BestBidQuantity       decimal.Decimal
+	BestAsk               decimal.Decimal
+	BestAskQuantity       decimal.Decimal
+}
+
+// Trade is an executed trade from the market_trades channel.
+type Trade struct {
+	TradeID   string
+	ProductID string
+	Side      string
+	Price     decimal.Decimal
+	Size      decimal.Decimal
+	Time      time.Time
+}
+
+// Level2 sides as reported by Coinbase.
+const (
+	SideBid   = "bid"
+	SideOffer = "offer"
+)
+
+// PriceLevel is the new total quantity at one price. A zero quantity removes the level.
+type PriceLevel struct {
+	Side     string
+	Price    decimal.Decimal
+	Quantity decimal.Decimal
+	Time     time.Time
+}
+
+// Level2Update is an order book snapshot or delta from the level2 channel.
+// Sequence is the connection-wide sequence number of the message that carried it.
+type Level2Update struct {
+	ProductID string
+	Snapshot  bool
+	Sequence  int64
+	Time      time.Time
+	Changes   []PriceLevel
+}
+
+// CandleSeconds is the granularity of the candles channel.
+const CandleSeconds = 5 * 60
+
+// Candle is a 5-minute candle update from the candles channel. The most recent candle
+// is updated repeatedly until its bucket closes.
+type Candle struct {
+	ProductID string
+	coinbase.Candle
+}
+
+// Heartbeat is a keep-alive message from the heartbeats channel.
+type Heartbeat struct {
+	Counter int64
+	Time    time.Time
+}
+
+// envelope is the common wrapper around every message.
+type envelope struct {
+	Channel     string          `json:"channel"`
+	Timestamp   time.Time       `json:"timestamp"`
+	SequenceNum int64           `json:"sequence_num"`
+	Events      json.RawMessage `json:"events"`
+	// Set on error messages instead of the fields above.
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+type tickerEvent struct {
+	Type    string `json:"type"`
+	Tickers []struct {
+		ProductID             string `json:"product_id"`
+		Price                 string `json:"price"`
+		Volume24h             string `json:"volume_24_h"`
+		Low24h                string `json:"low_24_h"`
+		High24h               string `json:"high_24_h"`
+		PricePercentChange24h string `json:"price_percent_chg_24_h"`
+		BestBid               string `json:"best_bid"`
+		BestBidQuantity       string `json:"best_bid_quantity"`
+		BestAsk               string `json:"best_ask"`
+		BestAskQuantity       string `json:"best_ask_quantity"`
+	} `json:"tickers"`
+}
+
+type tradesEvent struct {
+	Type   string `json:"type"`
+	Trades []struct {
+		TradeID   string    `json:"trade_id"`
+		ProductID string    `json:"product_id"`
+		Price     string    `json:"price"`
+		Size      string    `json:"size"`
+		Side      string    `json:"side"`
+		Time      time.Time `json:"time"`
+	} `json:"trades"`
+}
+
+type level2Event struct {
+	Type      string `json:"type"`
+	ProductID string `json:"product_id"`
+	Updates   []struct {
+		Side        string    `json:"side"`
+		EventTime   time.Time `json:"event_time"`
+		PriceLevel  string    `json:"price_level"`
+		NewQuantity string    `json:"new_quantity"`
+	} `json:"updates"`
+}
+
+type candlesEvent struct {
+	Type    string `json:"type"`
+	Candles []struct {
+		Start     string `json:"start"`
+		Open      string `json:"open"`
+		High      string `json:"high"`
+		Low       string `json:"low"`
+		Close     string `json:"close"`
+		Volume    string `json:"volume"`
+		ProductID string `json:"product_id"`
+	} `json:"candles"`
+}
+
+type heartbeatEvent struct {
+	CurrentTime      string `json:"current_time"`
+	HeartbeatCounter int64  `json:"heartbeat_counter"`
+}
+
+// dispatch decodes the events of one message and sends them to the subscriber's channels.
+func (s *Subscriber) dispatch(done <-chan struct{}, env *envelope) error {
+	switch env.Channel {
+	case "ticker", "ticker_batch":
+		var events []tickerEvent
+		if err := json.Unmarshal(env.Events, &events); err != nil {
+			return fmt.Errorf("decode ticker: %w", err)
+		}
+		for _, e := range events {
+			for _, t := range e.Tickers {
+				tk := Ticker{ProductID: t.ProductID, Time: env.Timestamp}
+				err := parseDecimals(t.ProductID+" ticker", []decimalField{
+					{"price", t.Price, &tk.Price},
+					{"volume_24_h", t.Volume24h, &tk.Volume24h},
+					{"low_24_h", t.Low24h, &tk.Low24h},
+					{"high_24_h", t.High24h, &tk.High24h},
+					{"price_percent_chg_24_h", t.PricePercentChange24h, &tk.PricePercentChange24h},
+					{"best_bid", t.BestBid, &tk.BestBid},
+					{"best_bid_quantity", t.BestBidQuantity, &tk.BestBidQuantity},
+					{"best_ask", t.BestAsk, &tk.BestAsk},
+					{"best_ask_quantity", t.BestAskQuantity, &tk.BestAskQuantity},
+				})
+				if err != nil {
+					return err
+				}
+				select {
+				case s.Tickers <- tk:
+				case <-done:
+					return nil
+				}
+			}
+		}
+	case "market_trades":
+		var events []tradesEvent
+		if err := json.Unmarshal(env.Events, &events); err != nil {
+			return fmt.Errorf("decode market_trades: %w", err)
+		}
+		for _, e := range events {
+			for _, t := range e.Trades {
+				tr := Trade{TradeID: t.TradeID, ProductID: t.ProductID, Side: t.Side, Time: t.Time}
+				err := parseDecimals(t.ProductID+" trade "+t.TradeID, []decimalField{
+					{"price", t.Price, &tr.Price},
+					{"size", t.Size, &tr.Size},
+				})
+				if err != nil {
+					return err
+				}
+				select {
+				case s.Trades <- tr:
+				case <-done:
+					return nil
+				}
+			}
+		}
+	case "l2_data":
+		var events []level2Event
+		if err := json.Unmarshal(env.Events, &events); err != nil {
+			return fmt.Errorf("decode l2_data: %w", err)
+		}
+		for _, e := range events {
+			u := Level2Update{
+				ProductID: e.ProductID,
+				Snapshot:  e.Type == "snapshot",
+				Sequence:  env.SequenceNum,
+				Time:      env.Timestamp,
+				Changes:   make([]PriceLevel, 0, len(e.Updates)),
+			}
+			for _, c := range e.Updates {
+				l := PriceLevel{Side: c.Side, Time: c.EventTime}
+				err := parseDecimals(e.ProductID+" level2 update", []decimalField{
+					{"price_level", c.PriceLevel, &l.Price},
+					{"new_quantity", c.NewQuantity, &l.Quantity},
+				})
+				if err != nil {
+					return err
+				}
+				u.Changes = append(u.Changes, l)
+			}
+			select {
+			case s.Level2 <- u:
+			case <-done:
+				return nil
+			}
+		}
+	case "candles":
+		var events []candlesEvent
+		if err := json.Unmarshal(env.Events, &events); err != nil {
+			return fmt.Errorf("decode candles: %w", err)
+		}
+		for _, e := range events {
+			for _, c := range e.Candles {
+				start, err := strconv.ParseInt(c.Start, 10, 64)
+				if err != nil {
+					return fmt.Errorf("decode candle start %q: %w", c.Start, err)
+				}
+				candle, err := exchange.ParseCandle(time.Unix(start, 0).UTC(), c.Open, c.High, c.Low, c.Close, c.Volume)
+				if err != nil {
+					return fmt.Errorf("decode %s candle: %w", c.ProductID, err)
+				}
+				cd := Candle{ProductID: c.ProductID, Candle: candle}
+				select {
+				case s.Candles <- cd:
+				case <-done:
+					return nil
+				}
+			}
+		}
+	case "heartbeats":
+		var events []heartbeatEvent
+		if err := json.Unmarshal(env.Events, &events); err != nil {
+			return fmt.Errorf("decode heartbeats: %w", err)
+		}
+		for _, e := range events {
+			hb := Heartbeat{Counter: e.HeartbeatCounter, Time: parseHeartbeatTime(e.CurrentTime)}
+			select {
+			case s.Heartbeats <- hb:
+			case <-done:
+				return nil
+			}
+		}
+	}
+	// subscriptions acknowledgements and unknown channels are ignored.
+	return nil
+}
+
+// decimalField is one decimal string of a message and where to store it.
+type decimalField struct {
+	name string
+	s    string
+	dst  *decimal.Decimal
+}
+
+// parseDecimals parses fields of the message described by what, failing on the first malformed value.
+func parseDecimals(what string, fields []decimalField) error {
+	for _, f := range fields {
+		d, err := exchange.ParseDecimal(f.name, f.s)
+		if err != nil {
+			return fmt.Errorf("decode %s: %w", what, err)
+		}
+		*f.dst = d
+	}
+	return nil
+}
+
+// parseHeartbeatTime parses the Go time.String() format Coinbase uses for heartbeats,
+// e.g. "2023-06-23 20:31:56.121961769 +0000 UTC m=+91717.525857105".
+func parseHeartbeatTime(s string) time.Time {
+	if i := strings.Index(s, " m="); i >= 0 {
+		s = s[:i]
+	}
+	t, err := time.Parse("2006-01-02 15:04:05.999999999 -0700 MST", s)
+	if err != nil {
+		return time.Time{}
+	}
+	return t.UTC()
+}
@@ -0,0 +1,221 @@
+// Package stream subscribes to the Coinbase Advanced Trade WebSocket feed and delivers
+// decoded market data on typed Go channels.
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// DefaultURL is the Advanced Trade market data endpoint.
+const DefaultURL = "wss://advanced-trade-ws.coinbase.com"
+
+// Channel names accepted by Coinbase subscriptions.
+type Channel string
+
+const (
+	ChannelTicker       Channel = "ticker"
+	ChannelMarketTrades Channel = "market_trades"
+	ChannelLevel2       Channel = "level2"
+	ChannelCandles      Channel = "candles"
+	ChannelHeartbeats   Channel = "heartbeats"
+)
+
+// EventKind identifies a connection lifecycle event.
+type EventKind int
+
+const (
+	// EventConnected is emitted once all subscriptions have been sent.
+	EventConnected EventKind = iota
+	// EventDisconnected is emitted when a connection ends; Err holds the cause and RetryIn the backoff.
+	EventDisconnected
+	// EventGap is emitted when a sequence number is skipped; Expected and Got hold the numbers.
+	EventGap
+)
+
+// Event reports connection state changes to OnEvent.
+type Event struct {
+	Kind     EventKind
+	Err      error
+	RetryIn  time.Duration
+	Expected int64
+	Got      int64
+}
+
+// Subscriber maintains a WebSocket connection, resubscribing after every reconnect.
+// Data is delivered on the exported channels, which are closed when Run returns.
+// Only channels that were subscribed receive values, and each must be drained by the caller.
+type Subscriber struct {
+	URL        string
+	ProductIDs []string
+	Channels   []Channel
+	// Token returns the JWT sent with each subscribe message. It is called on every
+	// (re)connect because tokens expire after two minutes. Nil or an empty token subscribes
+	// without authentication.
+	Token func() (string, error)
+	// MinBackoff and MaxBackoff bound the delay between reconnect attempts.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// IdleTimeout drops the connection when no message arrives for this long.
+	// Subscribing to heartbeats keeps quiet connections alive.
+	IdleTimeout time.Duration
+	// ReconnectOnGap drops the connection after a sequence gap so the level2 channel
+	// starts over with a fresh snapshot.
+	ReconnectOnGap bool
+	// OnEvent, when set, receives connection lifecycle events.
+	OnEvent func(Event)
+	Dialer  *websocket.Dialer
+
+	Tickers    chan Ticker
+	Trades     chan Trade
+	Level2     chan Level2Update
+	Candles    chan Candle
+	Heartbeats chan Heartbeat
+}
+
+// New returns a Subscriber for products and channels with default settings.
+func New(productIDs []string, channels ...Channel) *Subscriber {
+	return &Subscriber{
+		URL:            DefaultURL,
+		ProductIDs:     productIDs,
+		Channels:       channels,
+		MinBackoff:     time.Second,
+		MaxBackoff:     time.Minute,
+		IdleTimeout:    30 * time.Second,
+		ReconnectOnGap: true,
+		Dialer:         websocket.DefaultDialer,
+		Tickers:        make(chan Ticker, 256),
+		Trades:         make(chan Trade, 256),
+		Level2:         make(chan Level2Update, 256),
+		Candles:        make(chan Candle, 256),
+		Heartbeats:     make(chan Heartbeat, 16),
+	}
+}
+
+// ErrGap is the disconnect cause reported when ReconnectOnGap drops a connection.
+var ErrGap = errors.New("sequence gap")
+
+type subscribeMessage struct {
+	Type       string   `json:"type"`
+	ProductIDs []string `json:"product_ids,omitempty"`
+	Channel    Channel  `json:"channel"`
+	JWT        string   `json:"jwt,omitempty"`
+}
+
+// Run connects and streams until ctx is cancelled, reconnecting with exponential backoff.
+// It always returns the context error.
+func (s *Subscriber) Run(ctx context.Context) error {
+	defer s.closeChannels()
+
+	backoff := s.MinBackoff
+	for {
+		received, err := s.runOnce(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if received {
+			backoff = s.MinBackoff
+		}
+		s.emit(Event{Kind: EventDisconnected, Err: err, RetryIn: backoff})
+
+		t := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+		if backoff *= 2; backoff > s.MaxBackoff {
+			backoff = s.MaxBackoff
+		}
+	}
+}
+
+// runOnce handles one connection. received reports whether any message arrived, which
+// resets the reconnect backoff.
+func (s *Subscriber) runOnce(ctx context.Context) (received bool, err error) {
+	conn, _, err := s.Dialer.DialContext(ctx, s.URL, nil)
+	if err != nil {
+		return false, fmt.Errorf("dial %s: %w", s.URL, err)
+	}
+	defer conn.Close()
+
+	// Unblock ReadMessage when the context is cancelled.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	for _, ch := range s.Channels {
+		msg := subscribeMessage{Type: "subscribe", ProductIDs: s.ProductIDs, Channel: ch}
+		if ch == ChannelHeartbeats {
+			msg.ProductIDs = nil
+		}
+		if s.Token != nil {
+			if msg.JWT, err = s.Token(); err != nil {
+				return false, fmt.Errorf("sign subscription: %w", err)
+			}
+		}
+		if err := conn.WriteJSON(msg); err != nil {
+			return false, fmt.Errorf("subscribe %s: %w", ch, err)
+		}
+	}
+	s.emit(Event{Kind: EventConnected})
+
+	// Sequence numbers are per connection and cover every channel.
+	var last int64 = -1
+	for {
+		if s.IdleTimeout > 0 {
+			conn.SetReadDeadline(time.Now().Add(s.IdleTimeout))
+		}
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return received, err
+		}
+		received = true
+
+		var env envelope
+		if err := json.Unmarshal(data, &env); err != nil {
+			return received, fmt.Errorf("decode message: %w", err)
+		}
+		if env.Type == "error" {
+			return received, fmt.Errorf("coinbase stream error: %s", env.Message)
+		}
+
+		if last >= 0 && env.SequenceNum != last+1 {
+			s.emit(Event{Kind: EventGap, Expected: last + 1, Got: env.SequenceNum})
+			if s.ReconnectOnGap {
+				return received, fmt.Errorf("%w: expected %d, got %d", ErrGap, last+1, env.SequenceNum)
+			}
+		}
+		last = env.SequenceNum
+
+		if err := s.dispatch(ctx.Done(), &env); err != nil {
+			return received, err
+		}
+	}
+}
+
+func (s *Subscriber) emit(e Event) {
+	if s.OnEvent != nil {
+		s.OnEvent(e)
+	}
+}
+
+func (s *Subscriber) closeChannels() {
+	close(s.Tickers)
+	close(s.Trades)
+	close(s.Level2)
+	close(s.Candles)
+	close(s.Heartbeats)
+}
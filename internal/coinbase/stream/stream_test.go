@@ -0,0 +1,158 @@
+package stream
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/shopspring/decimal"
+)
+
+// serve starts a WebSocket server that records subscribe messages and replays msgs on every connection.
+func serve(t *testing.T, msgs []string) (url string, subs func() []subscribeMessage) {
+	t.Helper()
+	var (
+		mu  sync.Mutex
+		got []subscribeMessage
+	)
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var sub subscribeMessage
+		if err := conn.ReadJSON(&sub); err != nil {
+			return
+		}
+		mu.Lock()
+		got = append(got, sub)
+		mu.Unlock()
+		for _, m := range msgs {
+			if err := conn.WriteMessage(websocket.TextMessage, []byte(m)); err != nil {
+				return
+			}
+		}
+		// Hold the connection open until the client goes away.
+		conn.ReadMessage()
+	}))
+	t.Cleanup(srv.Close)
+	return "ws" + strings.TrimPrefix(srv.URL, "http"), func() []subscribeMessage {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]subscribeMessage(nil), got...)
+	}
+}
+
+func TestCandlesAndSubscription(t *testing.T) {
+	url, subs := serve(t, []string{
+		`{"channel":"subscriptions","sequence_num":0,"events":[]}`,
+		`{"channel":"candles","timestamp":"2024-01-01T00:05:01Z","sequence_num":1,"events":[{"type":"update","candles":[{"start":"1704067200","open":"100","high":"110","low":"90","close":"105","volume":"2.5","product_id":"BTC-USD"}]}]}`,
+	})
+
+	s := New([]string{"BTC-USD"}, ChannelCandles)
+	s.URL = url
+	s.Token = func() (string, error) { return "token", nil }
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go s.Run(ctx)
+
+	select {
+	case c := <-s.Candles:
+		if c.ProductID != "BTC-USD" || !c.Time.Equal(time.Unix(1704067200, 0)) || c.Close.String() != "105" || c.Volume.String() != "2.5" {
+			t.Errorf("unexpected candle: %+v", c)
+		}
+	case <-ctx.Done():
+		t.Fatal("no candle received")
+	}
+
+	got := subs()
+	if len(got) != 1 || got[0].Type != "subscribe" || got[0].Channel != ChannelCandles || got[0].JWT != "token" || got[0].ProductIDs[0] != "BTC-USD" {
+		t.Errorf("unexpected subscription: %+v", got)
+	}
+}
+
+func TestGapTriggersReconnect(t *testing.T) {
+	url, subs := serve(t, []string{
+		`{"channel":"l2_data","sequence_num":0,"events":[{"type":"snapshot","product_id":"BTC-USD","updates":[{"side":"bid","price_level":"100","new_quantity":"1"}]}]}`,
+		`{"channel":"l2_data","sequence_num":2,"events":[{"type":"update","product_id":"BTC-USD","updates":[{"side":"bid","price_level":"100","new_quantity":"0"}]}]}`,
+	})
+
+	s := New([]string{"BTC-USD"}, ChannelLevel2)
+	s.URL = url
+	s.MinBackoff = 10 * time.Millisecond
+	var (
+		mu   sync.Mutex
+		gaps []Event
+	)
+	s.OnEvent = func(e Event) {
+		if e.Kind == EventGap {
+			mu.Lock()
+			gaps = append(gaps, e)
+			mu.Unlock()
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go s.Run(ctx)
+
+	// Each connection delivers the snapshot, then drops before the out-of-sequence update.
+	for i := 0; i < 2; i++ {
+		select {
+		case u := <-s.Level2:
+			if !u.Snapshot || len(u.Changes) != 1 || !u.Changes[0].Price.Equal(decimal.NewFromInt(100)) {
+				t.Fatalf("update %d: expected snapshot, got %+v", i, u)
+			}
+		case <-ctx.Done():
+			t.Fatalf("update %d not received", i)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(gaps) == 0 || gaps[0].Expected != 1 || gaps[0].Got != 2 {
+		t.Errorf("gaps = %+v, want expected 1 got 2", gaps)
+	}
+	if n := len(subs()); n < 2 {
+		t.Errorf("subscriptions = %d, want a resubscribe after the gap", n)
+	}
+}
+
+func TestParseHeartbeatTime(t *testing.T) {
+	got := parseHeartbeatTime("2023-06-23 20:31:56.121961769 +0000 UTC m=+91717.525857105")
+	want := time.Date(2023, 6, 23, 20, 31, 56, 121961769, time.UTC)
+	if !got.Equal(want) {
+		t.Errorf("parseHeartbeatTime = %v, want %v", got, want)
+	}
+}
+
+func TestDispatchDecimals(t *testing.T) {
+	s := New([]string{"BTC-USD"}, ChannelMarketTrades)
+	done := make(chan struct{})
+
+	env := &envelope{Channel: "market_trades", Events: []byte(`[{"type":"update","trades":[{"trade_id":"1","product_id":"BTC-USD","price":"42000.01","size":"0.00000001","side":"BUY"}]}]`)}
+	if err := s.dispatch(done, env); err != nil {
+		t.Fatalf("dispatch: %v", err)
+	}
+	tr := <-s.Trades
+	if tr.Price.String() != "42000.01" || tr.Size.String() != "0.00000001" {
+		t.Errorf("trade = %s x %s, want exact decimals", tr.Price, tr.Size)
+	}
+
+	// Malformed numbers fail the message instead of becoming zero.
+	for _, env := range []*envelope{
+		{Channel: "market_trades", Events: []byte(`[{"trades":[{"trade_id":"2","product_id":"BTC-USD","price":"","size":"1"}]}]`)},
+		{Channel: "l2_data", Events: []byte(`[{"type":"update","product_id":"BTC-USD","updates":[{"side":"bid","price_level":"100","new_quantity":"abc"}]}]`)},
+		{Channel: "ticker", Events: []byte(`[{"tickers":[{"product_id":"BTC-USD","price":"1e"}]}]`)},
+	} {
+		if err := s.dispatch(done, env); err == nil {
+			t.Errorf("%s: dispatch accepted malformed numbers", env.Channel)
+		}
+	}
+}
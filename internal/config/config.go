@@ -9,6 +9,7 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	ini "gopkg.in/ini.v1"
 	"github.com/joho/godotenv"
@@ -34,6 +35,12 @@ func FromContext(ctx context.Context) *Config {
 type Config struct {
 	Database struct {
 		URL string
+		// Connection pool bounds for the long-lived pool each command opens; zero keeps
+		// the database/sql default.
+		MaxOpenConns    int
+		MaxIdleConns    int
+		ConnMaxLifetime time.Duration
+		ConnMaxIdleTime time.Duration
 	}
 	Coinbase struct {
 		APIKey     string
@@ -41,7 +48,20 @@ type Config struct {
 		Passphrase string
 		APIKeyName    string
 		APIPrivateKey string
+		// BaseURL overrides the REST API host, e.g. for the sandbox or a local fake exchange.
+		BaseURL     string
+		// RecordDir and ReplayDir select cassette record or replay mode; see internal/coinbase/cassette.
+		RecordDir   string
+		ReplayDir   string
+		// RPM, when set, paces all requests at a fixed rate with no burst (the pre-bucket
+		// behaviour). Otherwise public and private endpoints get separate token buckets.
 		RPM         int
+		PublicRPM   int
+		PrivateRPM  int
+		Burst       int
+		// SharedLimits keeps the buckets in Postgres so every process on the same database
+		// and API key shares one budget.
+		SharedLimits bool
 		MaxRetries  int
 		BackoffMS   int
 	}
@@ -134,6 +154,23 @@ func Load(path, credsPath string) (*Config, error) {
 			}
 		}
 
+		for key, dst := range map[string]*int{
+			"DB_MAX_OPEN_CONNS": &c.Database.MaxOpenConns,
+			"DB_MAX_IDLE_CONNS": &c.Database.MaxIdleConns,
+		} {
+			if parsed, err := strconv.Atoi(envMap[key]); err == nil {
+				*dst = parsed
+			}
+		}
+		for key, dst := range map[string]*time.Duration{
+			"DB_CONN_MAX_LIFETIME":  &c.Database.ConnMaxLifetime,
+			"DB_CONN_MAX_IDLE_TIME": &c.Database.ConnMaxIdleTime,
+		} {
+			if parsed, err := time.ParseDuration(envMap[key]); err == nil {
+				*dst = parsed
+			}
+		}
+
 		// Coinbase configuration
 		c.Coinbase.APIKey = envMap["COINBASE_API_KEY"]
 		c.Coinbase.APISecret = envMap["COINBASE_API_SECRET"]
@@ -147,12 +184,24 @@ func Load(path, credsPath string) (*Config, error) {
 			c.Coinbase.APIPrivateKey = envMap["COINBASE_CLOUD_API_SECRET"]
 		}
 
+		c.Coinbase.BaseURL = envMap["COINBASE_BASE_URL"]
+
 		// Rate limiting and retries
 		if v := envMap["COINBASE_RPM"]; v != "" {
 			if parsed, err := strconv.Atoi(v); err == nil {
 				c.Coinbase.RPM = parsed
 			}
 		}
+		for key, dst := range map[string]*int{
+			"COINBASE_PUBLIC_RPM":  &c.Coinbase.PublicRPM,
+			"COINBASE_PRIVATE_RPM": &c.Coinbase.PrivateRPM,
+			"COINBASE_BURST":       &c.Coinbase.Burst,
+		} {
+			if parsed, err := strconv.Atoi(envMap[key]); err == nil {
+				*dst = parsed
+			}
+		}
+		c.Coinbase.SharedLimits, _ = strconv.ParseBool(envMap["COINBASE_SHARED_LIMITS"])
 		if v := envMap["COINBASE_MAX_RETRIES"]; v != "" {
 			if parsed, err := strconv.Atoi(v); err == nil {
 				c.Coinbase.MaxRetries = parsed
@@ -196,6 +245,34 @@ func Load(path, credsPath string) (*Config, error) {
 				c.Database.URL = u.String()
 			}
 		}
+		// Pool settings (prefer [database], fallback to [default])
+		dbSec, dbDef := cfgfile.Section("database"), cfgfile.Section("default")
+		for _, k := range []struct {
+			section, fallback string
+			dst               *int
+		}{
+			{"max_open_conns", "DB_MAX_OPEN_CONNS", &c.Database.MaxOpenConns},
+			{"max_idle_conns", "DB_MAX_IDLE_CONNS", &c.Database.MaxIdleConns},
+		} {
+			if v, err := dbSec.Key(k.section).Int(); err == nil {
+				*k.dst = v
+			} else if v, err := dbDef.Key(k.fallback).Int(); err == nil {
+				*k.dst = v
+			}
+		}
+		for _, k := range []struct {
+			section, fallback string
+			dst               *time.Duration
+		}{
+			{"conn_max_lifetime", "DB_CONN_MAX_LIFETIME", &c.Database.ConnMaxLifetime},
+			{"conn_max_idle_time", "DB_CONN_MAX_IDLE_TIME", &c.Database.ConnMaxIdleTime},
+		} {
+			if v, err := dbSec.Key(k.section).Duration(); err == nil {
+				*k.dst = v
+			} else if v, err := dbDef.Key(k.fallback).Duration(); err == nil {
+				*k.dst = v
+			}
+		}
 		coinbaseSec := cfgfile.Section("coinbase")
 		c.Coinbase.APIKey = coinbaseSec.Key("api_key").String()
 		c.Coinbase.APISecret = coinbaseSec.Key("api_secret").String()
@@ -232,6 +309,10 @@ func Load(path, credsPath string) (*Config, error) {
 				c.Coinbase.APIPrivateKey = def.Key("COINBASE_CLOUD_API_SECRET").String()
 			}
 		}
+		c.Coinbase.BaseURL = coinbaseSec.Key("base_url").String()
+		if c.Coinbase.BaseURL == "" {
+			c.Coinbase.BaseURL = cfgfile.Section("default").Key("COINBASE_BASE_URL").String()
+		}
 		// Rate limiting and retries (prefer [coinbase], fallback to [default])
 		if v, err := coinbaseSec.Key("rpm").Int(); err == nil {
 			c.Coinbase.RPM = v
@@ -248,6 +329,25 @@ func Load(path, credsPath string) (*Config, error) {
 				c.Coinbase.RPM = v
 			}
 		}
+		for _, k := range []struct {
+			section, fallback string
+			dst               *int
+		}{
+			{"public_rpm", "COINBASE_PUBLIC_RPM", &c.Coinbase.PublicRPM},
+			{"private_rpm", "COINBASE_PRIVATE_RPM", &c.Coinbase.PrivateRPM},
+			{"burst", "COINBASE_BURST", &c.Coinbase.Burst},
+		} {
+			if v, err := coinbaseSec.Key(k.section).Int(); err == nil {
+				*k.dst = v
+			} else if v, err := def.Key(k.fallback).Int(); err == nil {
+				*k.dst = v
+			}
+		}
+		if v, err := coinbaseSec.Key("shared_limits").Bool(); err == nil {
+			c.Coinbase.SharedLimits = v
+		} else if v, err := def.Key("COINBASE_SHARED_LIMITS").Bool(); err == nil {
+			c.Coinbase.SharedLimits = v
+		}
 		if c.Coinbase.MaxRetries == 0 {
 			if v, err := def.Key("COINBASE_MAX_RETRIES").Int(); err == nil {
 				c.Coinbase.MaxRetries = v
@@ -282,6 +382,29 @@ func Load(path, credsPath string) (*Config, error) {
 	if c.Coinbase.BackoffMS == 0 {
 		c.Coinbase.BackoffMS = 500
 	}
+	// Coinbase allows 10 public and 30 private requests per second per key.
+	if c.Coinbase.PublicRPM == 0 {
+		c.Coinbase.PublicRPM = 600
+	}
+	if c.Coinbase.PrivateRPM == 0 {
+		c.Coinbase.PrivateRPM = 1800
+	}
+	if c.Coinbase.Burst == 0 {
+		c.Coinbase.Burst = 5
+	}
+	// One command rarely needs more than a few connections; the headroom covers the daemon.
+	if c.Database.MaxOpenConns == 0 {
+		c.Database.MaxOpenConns = 16
+	}
+	if c.Database.MaxIdleConns == 0 {
+		c.Database.MaxIdleConns = 4
+	}
+	if c.Database.ConnMaxLifetime == 0 {
+		c.Database.ConnMaxLifetime = 30 * time.Minute
+	}
+	if c.Database.ConnMaxIdleTime == 0 {
+		c.Database.ConnMaxIdleTime = 5 * time.Minute
+	}
 	return &c, nil
 }
 
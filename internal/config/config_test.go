@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestLoad_EnvFromCurrentDirectory(t *testing.T) {
@@ -136,3 +137,104 @@ key2 = value2
 		t.Error("Expected isEnvFile to return false for .ini file")
 	}
 }
+
+func TestLoad_CoinbaseBaseURL(t *testing.T) {
+	tempDir := t.TempDir()
+
+	envFile := filepath.Join(tempDir, "test.env")
+	if err := os.WriteFile(envFile, []byte("COINBASE_BASE_URL=http://127.0.0.1:8089\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test .env file: %v", err)
+	}
+	cfg, err := Load(envFile, "")
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if cfg.Coinbase.BaseURL != "http://127.0.0.1:8089" {
+		t.Errorf("Expected COINBASE_BASE_URL from .env, got '%s'", cfg.Coinbase.BaseURL)
+	}
+
+	iniFile := filepath.Join(tempDir, "test.ini")
+	iniContent := `[default]
+COINBASE_BASE_URL = https://api-sandbox.coinbase.com
+`
+	if err := os.WriteFile(iniFile, []byte(iniContent), 0644); err != nil {
+		t.Fatalf("Failed to create test .ini file: %v", err)
+	}
+	cfg, err = Load(iniFile, "")
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if cfg.Coinbase.BaseURL != "https://api-sandbox.coinbase.com" {
+		t.Errorf("Expected COINBASE_BASE_URL from .ini, got '%s'", cfg.Coinbase.BaseURL)
+	}
+}
+
+func TestLoad_CoinbaseRateLimits(t *testing.T) {
+	tempDir := t.TempDir()
+
+	envFile := filepath.Join(tempDir, "test.env")
+	if err := os.WriteFile(envFile, []byte("COINBASE_PRIVATE_RPM=900\nCOINBASE_SHARED_LIMITS=true\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test .env file: %v", err)
+	}
+	cfg, err := Load(envFile, "")
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if cfg.Coinbase.PublicRPM != 600 || cfg.Coinbase.PrivateRPM != 900 || cfg.Coinbase.Burst != 5 || !cfg.Coinbase.SharedLimits {
+		t.Errorf("Unexpected rate limits from .env: %+v", cfg.Coinbase)
+	}
+
+	iniFile := filepath.Join(tempDir, "test.ini")
+	iniContent := `[coinbase]
+public_rpm = 300
+burst = 2
+
+[default]
+COINBASE_PRIVATE_RPM = 1200
+`
+	if err := os.WriteFile(iniFile, []byte(iniContent), 0644); err != nil {
+		t.Fatalf("Failed to create test .ini file: %v", err)
+	}
+	cfg, err = Load(iniFile, "")
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if cfg.Coinbase.PublicRPM != 300 || cfg.Coinbase.PrivateRPM != 1200 || cfg.Coinbase.Burst != 2 || cfg.Coinbase.SharedLimits {
+		t.Errorf("Unexpected rate limits from .ini: %+v", cfg.Coinbase)
+	}
+}
+
+func TestLoad_DatabasePool(t *testing.T) {
+	tempDir := t.TempDir()
+
+	envFile := filepath.Join(tempDir, "test.env")
+	if err := os.WriteFile(envFile, []byte("DB_MAX_OPEN_CONNS=32\nDB_CONN_MAX_LIFETIME=1h\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test .env file: %v", err)
+	}
+	cfg, err := Load(envFile, "")
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if cfg.Database.MaxOpenConns != 32 || cfg.Database.MaxIdleConns != 4 || cfg.Database.ConnMaxLifetime != time.Hour || cfg.Database.ConnMaxIdleTime != 5*time.Minute {
+		t.Errorf("Unexpected pool settings from .env: %+v", cfg.Database)
+	}
+
+	iniFile := filepath.Join(tempDir, "test.ini")
+	iniContent := `[database]
+max_idle_conns = 8
+conn_max_idle_time = 90s
+
+[default]
+DB_MAX_OPEN_CONNS = 20
+`
+	if err := os.WriteFile(iniFile, []byte(iniContent), 0644); err != nil {
+		t.Fatalf("Failed to create test .ini file: %v", err)
+	}
+	cfg, err = Load(iniFile, "")
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if cfg.Database.MaxOpenConns != 20 || cfg.Database.MaxIdleConns != 8 || cfg.Database.ConnMaxLifetime != 30*time.Minute || cfg.Database.ConnMaxIdleTime != 90*time.Second {
+		t.Errorf("Unexpected pool settings from .ini: %+v", cfg.Database)
+	}
+}
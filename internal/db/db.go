@@ -2,9 +2,52 @@ package db
 
 import (
 	"database/sql"
+	"time"
+
 	_ "github.com/lib/pq"
+
+	"cryptool/internal/config"
 )
 
 func Open(url string) (*sql.DB, error) {
 	return sql.Open("postgres", url)
 }
+
+// Pool bounds a connection pool. Zero fields keep the database/sql defaults.
+type Pool struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+// PoolFromConfig returns the pool settings from the [database] section of cfg.
+func PoolFromConfig(cfg *config.Config) Pool {
+	return Pool{
+		MaxOpenConns:    cfg.Database.MaxOpenConns,
+		MaxIdleConns:    cfg.Database.MaxIdleConns,
+		ConnMaxLifetime: cfg.Database.ConnMaxLifetime,
+		ConnMaxIdleTime: cfg.Database.ConnMaxIdleTime,
+	}
+}
+
+// OpenPool opens url and applies p. Like Open it does not connect; the first query or Ping does.
+func OpenPool(url string, p Pool) (*sql.DB, error) {
+	conn, err := Open(url)
+	if err != nil {
+		return nil, err
+	}
+	if p.MaxOpenConns > 0 {
+		conn.SetMaxOpenConns(p.MaxOpenConns)
+	}
+	if p.MaxIdleConns > 0 {
+		conn.SetMaxIdleConns(p.MaxIdleConns)
+	}
+	if p.ConnMaxLifetime > 0 {
+		conn.SetConnMaxLifetime(p.ConnMaxLifetime)
+	}
+	if p.ConnMaxIdleTime > 0 {
+		conn.SetConnMaxIdleTime(p.ConnMaxIdleTime)
+	}
+	return conn, nil
+}
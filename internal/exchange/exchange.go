@@ -0,0 +1,217 @@
+// Package exchange defines exchange-neutral market data, account and order types and the
+// interfaces exchange adapters implement. Adapters register themselves by name; see Register.
+package exchange
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// ErrNotSupported is returned when an exchange does not offer a capability.
+var ErrNotSupported = errors.New("not supported by this exchange")
+
+// Adapter errors match these sentinels with errors.Is when the exchange rejected a request
+// for the corresponding reason.
+var (
+	// ErrUnauthorized means the credentials are missing, invalid or lack permission.
+	// Retrying will not help.
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrRateLimited means the exchange throttled the request.
+	ErrRateLimited = errors.New("rate limited")
+	// ErrNotFound means the product, order or other resource does not exist.
+	ErrNotFound = errors.New("not found")
+)
+
+// Candle is one OHLCV bucket.
+type Candle struct {
+	Time   time.Time
+	Open   decimal.Decimal
+	High   decimal.Decimal
+	Low    decimal.Decimal
+	Close  decimal.Decimal
+	Volume decimal.Decimal
+}
+
+// ParseDecimal parses a decimal string reported by an exchange, naming field in the error.
+// Empty or malformed input is an error, never zero.
+func ParseDecimal(field, s string) (decimal.Decimal, error) {
+	d, err := decimal.NewFromString(s)
+	if err != nil {
+		return decimal.Decimal{}, fmt.Errorf("parse %s %q: %w", field, s, err)
+	}
+	return d, nil
+}
+
+// ParseCandle builds the candle at t from decimal strings, failing on any malformed value.
+func ParseCandle(t time.Time, open, high, low, close, volume string) (Candle, error) {
+	c := Candle{Time: t}
+	for _, f := range []struct {
+		name, s string
+		dst     *decimal.Decimal
+	}{
+		{"open", open, &c.Open},
+		{"high", high, &c.High},
+		{"low", low, &c.Low},
+		{"close", close, &c.Close},
+		{"volume", volume, &c.Volume},
+	} {
+		d, err := ParseDecimal(f.name, f.s)
+		if err != nil {
+			return Candle{}, fmt.Errorf("candle at %s: %w", t.Format(time.RFC3339), err)
+		}
+		*f.dst = d
+	}
+	return c, nil
+}
+
+// Product is a tradable pair. ProductID always has the form BASE-QUOTE, e.g. BTC-USD,
+// regardless of the exchange's own symbol format. Numeric fields are kept as the decimal
+// strings the exchange returned; empty means unknown.
+type Product struct {
+	ProductID       string
+	BaseCurrency    string
+	QuoteCurrency   string
+	BaseName        string
+	QuoteName       string
+	Status          string
+	Disabled        bool
+	TradingDisabled bool
+	Price           string
+	Volume24h       string
+	BaseIncrement   string
+	QuoteIncrement  string
+	PriceIncrement  string
+	BaseMinSize     string
+	BaseMaxSize     string
+	QuoteMinSize    string
+	QuoteMaxSize    string
+	// ListedAt is when the product started trading, if the exchange reports it.
+	ListedAt time.Time
+	// Details holds the exchange's raw product payload.
+	Details json.RawMessage
+}
+
+// Account is a balance held in one currency.
+type Account struct {
+	ID        string
+	Name      string
+	Currency  string
+	Available string
+	Hold      string
+	Active    bool
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt time.Time
+}
+
+// Order sides and types.
+const (
+	SideBuy  = "BUY"
+	SideSell = "SELL"
+
+	OrderTypeMarket    = "market"
+	OrderTypeLimit     = "limit"
+	OrderTypeStopLimit = "stop-limit"
+)
+
+// OrderRequest describes an order to place. Sizes and prices are decimal strings.
+type OrderRequest struct {
+	ClientOrderID string
+	ProductID     string
+	Side          string
+	Type          string
+	BaseSize      string
+	QuoteSize     string // market orders only
+	LimitPrice    string
+	StopPrice     string
+	StopDirection string // "up" or "down", stop-limit orders only
+	PostOnly      bool
+	EndTime       time.Time // zero means good-til-cancelled
+}
+
+// Order is the state of an order on the exchange.
+type Order struct {
+	ID            string
+	ClientOrderID string
+	ProductID     string
+	Side          string
+	Type          string
+	Status        string
+	FilledSize    string
+	AveragePrice  string
+	CreatedAt     time.Time
+}
+
+// MarketData is the capability every adapter provides.
+type MarketData interface {
+	// Name is the value stored in the exchange column of every table.
+	Name() string
+	// MaxCandlesPerRequest is the largest number of candles GetCandles returns in one call.
+	MaxCandlesPerRequest() int64
+	// GetCandles fetches candles in [start, end] (end inclusive) with a single request.
+	// Callers must keep the range within MaxCandlesPerRequest buckets.
+	GetCandles(ctx context.Context, productID string, start, end time.Time, granularity string) ([]Candle, error)
+	// GetProducts lists all products.
+	GetProducts(ctx context.Context) ([]Product, error)
+}
+
+// Exchange is an opened adapter. Adapters may also implement Accounts and Trading.
+type Exchange interface {
+	MarketData
+}
+
+// Accounts is implemented by adapters that can read balances.
+type Accounts interface {
+	ListAccounts(ctx context.Context) ([]Account, error)
+}
+
+// HistoryStarter is implemented by adapters whose products carry no listing date.
+// HistoryStart returns the earliest time the exchange serves candles of productID at
+// granularity, so a full history can still be backfilled.
+type HistoryStarter interface {
+	HistoryStart(ctx context.Context, productID, granularity string) (time.Time, error)
+}
+
+// GranularityChecker is implemented by adapters that serve only some granularities, so
+// commands can reject an unsupported granularity before they start fetching.
+type GranularityChecker interface {
+	SupportsGranularity(granularity string) bool
+}
+
+// CheckGranularity returns an error wrapping ErrNotSupported when x cannot serve granularity.
+// Adapters that do not implement GranularityChecker are assumed to serve every granularity.
+func CheckGranularity(x MarketData, granularity string) error {
+	if gc, ok := x.(GranularityChecker); ok && !gc.SupportsGranularity(granularity) {
+		return fmt.Errorf("%s granularity %q: %w", x.Name(), granularity, ErrNotSupported)
+	}
+	return nil
+}
+
+// Trading is implemented by adapters that can place and manage orders.
+type Trading interface {
+	PlaceOrder(ctx context.Context, req OrderRequest) (*Order, error)
+	CancelOrders(ctx context.Context, orderIDs []string) error
+	GetOrder(ctx context.Context, orderID string) (*Order, error)
+	ListOpenOrders(ctx context.Context, productID string) ([]Order, error)
+}
+
+// AccountsOf returns x's Accounts capability, or an ErrNotSupported error.
+func AccountsOf(x Exchange) (Accounts, error) {
+	if a, ok := x.(Accounts); ok {
+		return a, nil
+	}
+	return nil, fmt.Errorf("%s accounts: %w", x.Name(), ErrNotSupported)
+}
+
+// TradingOf returns x's Trading capability, or an ErrNotSupported error.
+func TradingOf(x Exchange) (Trading, error) {
+	if t, ok := x.(Trading); ok {
+		return t, nil
+	}
+	return nil, fmt.Errorf("%s trading: %w", x.Name(), ErrNotSupported)
+}
@@ -0,0 +1,63 @@
+package exchange
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"cryptool/internal/config"
+)
+
+// Factory opens an adapter from the application config.
+type Factory func(cfg *config.Config) (Exchange, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Factory{}
+)
+
+// Register makes an adapter available under name. It is meant to be called from the
+// adapter package's init function and panics on duplicate names.
+func Register(name string, f Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	name = strings.ToLower(name)
+	if _, dup := registry[name]; dup {
+		panic("exchange: Register called twice for " + name)
+	}
+	registry[name] = f
+}
+
+// Open returns the adapter registered under name. Release it with Close when done.
+func Open(name string, cfg *config.Config) (Exchange, error) {
+	registryMu.RLock()
+	f, ok := registry[strings.ToLower(name)]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown exchange %q (available: %s)", name, strings.Join(Names(), ", "))
+	}
+	return f(cfg)
+}
+
+// Close releases what an opened adapter holds, such as a rate-limit database pool, when it
+// implements io.Closer. Callers of Open should defer it.
+func Close(x MarketData) error {
+	if c, ok := x.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// Names returns the registered adapter names, sorted.
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for n := range registry {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,146 @@
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// MaxGapAttempts is the number of times a bucket is requested and found empty before the
+// backfill gives up on it.
+const MaxGapAttempts = 5
+
+// Gap is a [Start, End) range of buckets the exchange returned no data for.
+type Gap struct {
+	Start         time.Time `json:"start"`
+	End           time.Time `json:"end"`
+	Attempts      int       `json:"attempts"`
+	LastAttemptAt time.Time `json:"last_attempt_at"`
+}
+
+// gapRuns coalesces bucket timestamps step apart into ranges, each with one attempt at at.
+func gapRuns(times []time.Time, step time.Duration, at time.Time) []Gap {
+	sorted := append([]time.Time(nil), times...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Before(sorted[j]) })
+	var out []Gap
+	for _, t := range sorted {
+		if n := len(out); n > 0 && !t.After(out[n-1].End) {
+			if end := t.Add(step); end.After(out[n-1].End) {
+				out[n-1].End = end
+			}
+			continue
+		}
+		out = append(out, Gap{Start: t, End: t.Add(step), Attempts: 1, LastAttemptAt: at})
+	}
+	return out
+}
+
+// mergeGaps adds one attempt at at to every bucket covered by marked. existing and marked must
+// each be sorted and non-overlapping. Buckets only in existing keep their attempts. The result
+// is split where attempt counts differ and coalesced where adjacent ranges agree.
+func mergeGaps(existing, marked []Gap, at time.Time) []Gap {
+	var bounds []time.Time
+	for _, g := range append(append([]Gap(nil), existing...), marked...) {
+		bounds = append(bounds, g.Start, g.End)
+	}
+	sort.Slice(bounds, func(i, j int) bool { return bounds[i].Before(bounds[j]) })
+
+	var out []Gap
+	ei, mi := 0, 0
+	for i := 0; i+1 < len(bounds); i++ {
+		lo, hi := bounds[i], bounds[i+1]
+		if !lo.Before(hi) {
+			continue
+		}
+		for ei < len(existing) && !existing[ei].End.After(lo) {
+			ei++
+		}
+		for mi < len(marked) && !marked[mi].End.After(lo) {
+			mi++
+		}
+		old := ei < len(existing) && !existing[ei].Start.After(lo)
+		hit := mi < len(marked) && !marked[mi].Start.After(lo)
+		if !old && !hit {
+			continue
+		}
+		var g Gap
+		if old {
+			g = existing[ei]
+		}
+		if hit {
+			g.Attempts++
+			g.LastAttemptAt = at
+		}
+		g.Start, g.End = lo, hi
+		if n := len(out); n > 0 && out[n-1].End.Equal(lo) && out[n-1].Attempts == g.Attempts &&
+			out[n-1].LastAttemptAt.Equal(g.LastAttemptAt) {
+			out[n-1].End = hi
+			continue
+		}
+		out = append(out, g)
+	}
+	return out
+}
+
+// MarkGaps records buckets the exchange returned no data for. Consecutive buckets are stored
+// as one range in candle_gaps, and every bucket in times gets one more attempt; once a bucket
+// reaches MaxGapAttempts it no longer counts as missing. It returns the number of ranges
+// the marked buckets were merged into.
+func (s *Store) MarkGaps(ctx context.Context, exchange, product string, granularitySec int, times []time.Time) (int, error) {
+	if len(times) == 0 {
+		return 0, nil
+	}
+	now := time.Now().UTC()
+	marked := gapRuns(times, time.Duration(granularitySec)*time.Second, now)
+	lo, hi := marked[0].Start, marked[len(marked)-1].End
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// Serialize markers for one product so concurrent fills cannot interleave their merges.
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext($1 || ':' || $2 || ':' || $3::text))`,
+		exchange, product, granularitySec); err != nil {
+		return 0, fmt.Errorf("lock gaps: %w", err)
+	}
+
+	// Adjacent ranges are loaded too so they can be coalesced with the new ones.
+	rows, err := tx.QueryContext(ctx, `
+		DELETE FROM candle_gaps
+		WHERE exchange = $1 AND product_id = $2 AND granularity = $3 AND start_time <= $5 AND end_time >= $4
+		RETURNING start_time, end_time, attempts, last_attempt_at
+	`, exchange, product, granularitySec, lo, hi)
+	if err != nil {
+		return 0, fmt.Errorf("load gaps: %w", err)
+	}
+	var existing []Gap
+	for rows.Next() {
+		var g Gap
+		if err := rows.Scan(&g.Start, &g.End, &g.Attempts, &g.LastAttemptAt); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("scan gap: %w", err)
+		}
+		existing = append(existing, g)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("load gaps: %w", err)
+	}
+	sort.Slice(existing, func(i, j int) bool { return existing[i].Start.Before(existing[j].Start) })
+
+	merged := mergeGaps(existing, marked, now)
+	err = copyRows(ctx, tx, "candle_gaps",
+		[]string{"exchange", "product_id", "granularity", "start_time", "end_time", "attempts", "last_attempt_at"},
+		len(merged), func(i int) []interface{} {
+			g := merged[i]
+			return []interface{}{exchange, product, granularitySec, g.Start, g.End, g.Attempts, g.LastAttemptAt}
+		})
+	if err != nil {
+		return 0, err
+	}
+	return len(merged), tx.Commit()
+}
+
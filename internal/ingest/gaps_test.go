@@ -0,0 +1,68 @@
+package ingest
+
+import (
+	"testing"
+	"time"
+)
+
+var (
+	t0 = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	t1 = t0.Add(time.Hour)
+	t2 = t1.Add(time.Hour)
+)
+
+func minute(n int) time.Time { return t0.Add(time.Duration(n) * time.Minute) }
+
+func TestGapRunsCoalescesConsecutiveBuckets(t *testing.T) {
+	runs := gapRuns([]time.Time{minute(5), minute(0), minute(1), minute(2), minute(6), minute(2)}, time.Minute, t1)
+	want := []Gap{
+		{Start: minute(0), End: minute(3), Attempts: 1, LastAttemptAt: t1},
+		{Start: minute(5), End: minute(7), Attempts: 1, LastAttemptAt: t1},
+	}
+	assertGaps(t, runs, want)
+}
+
+func TestMergeGapsSplitsByAttempts(t *testing.T) {
+	existing := []Gap{{Start: minute(0), End: minute(10), Attempts: 2, LastAttemptAt: t1}}
+	marked := []Gap{{Start: minute(5), End: minute(15), Attempts: 1, LastAttemptAt: t2}}
+
+	// Buckets 5-9 were marked before and now; 10-14 only now; 0-4 keep their old attempts.
+	want := []Gap{
+		{Start: minute(0), End: minute(5), Attempts: 2, LastAttemptAt: t1},
+		{Start: minute(5), End: minute(10), Attempts: 3, LastAttemptAt: t2},
+		{Start: minute(10), End: minute(15), Attempts: 1, LastAttemptAt: t2},
+	}
+	assertGaps(t, mergeGaps(existing, marked, t2), want)
+}
+
+func TestMergeGapsCoalescesAdjacentRanges(t *testing.T) {
+	existing := []Gap{
+		{Start: minute(0), End: minute(3), Attempts: 1, LastAttemptAt: t1},
+		{Start: minute(6), End: minute(9), Attempts: 1, LastAttemptAt: t1},
+	}
+	marked := []Gap{{Start: minute(0), End: minute(9), Attempts: 1, LastAttemptAt: t2}}
+
+	want := []Gap{
+		{Start: minute(0), End: minute(3), Attempts: 2, LastAttemptAt: t2},
+		{Start: minute(3), End: minute(6), Attempts: 1, LastAttemptAt: t2},
+		{Start: minute(6), End: minute(9), Attempts: 2, LastAttemptAt: t2},
+	}
+	assertGaps(t, mergeGaps(existing, marked, t2), want)
+
+	// Marking the middle again brings every bucket to two attempts, which is one range.
+	again := mergeGaps(mergeGaps(existing, marked, t2), []Gap{{Start: minute(3), End: minute(6)}}, t2)
+	assertGaps(t, again, []Gap{{Start: minute(0), End: minute(9), Attempts: 2, LastAttemptAt: t2}})
+}
+
+func assertGaps(t *testing.T, got, want []Gap) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d gaps %+v, want %+v", len(got), got, want)
+	}
+	for i := range want {
+		g, w := got[i], want[i]
+		if !g.Start.Equal(w.Start) || !g.End.Equal(w.End) || g.Attempts != w.Attempts || !g.LastAttemptAt.Equal(w.LastAttemptAt) {
+			t.Errorf("gap %d = %+v, want %+v", i, g, w)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package ingest
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+
+	"cryptool/internal/coinbase"
+)
+
+func TestCoinbaseProductColumns(t *testing.T) {
+	details, err := json.Marshal(coinbase.Product{
+		ProductID:                 "BTC-USD",
+		PricePercentageChange24h:  "-1.25",
+		MidMarketPrice:            "",
+		ApproximateQuote24hVolume: "123456.78",
+		Watched:                   true,
+		ProductType:               "SPOT",
+		AliasTo:                   []string{"BTC-USDC"},
+		DisplayName:               "BTC/USD",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cols, err := coinbaseProductColumns("coinbase", details)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cols) != coinbaseProductColumnCount {
+		t.Fatalf("got %d columns, want %d", len(cols), coinbaseProductColumnCount)
+	}
+	if d := cols[0].(decimal.NullDecimal); !d.Valid || d.Decimal.String() != "-1.25" {
+		t.Errorf("price_percentage_change_24h = %v", d)
+	}
+	if d := cols[10].(decimal.NullDecimal); d.Valid {
+		t.Errorf("empty mid_market_price = %v, want NULL", d)
+	}
+	if d := cols[18].(decimal.NullDecimal); d.Decimal.String() != "123456.78" {
+		t.Errorf("approximate_quote_24h_volume = %v", d)
+	}
+	if cols[2] != true || cols[8] != "SPOT" || cols[16] != "BTC/USD" {
+		t.Errorf("watched, product_type, display_name = %v, %v, %v", cols[2], cols[8], cols[16])
+	}
+	if a, ok := cols[12].(*pq.StringArray); !ok || len(*a) != 1 || (*a)[0] != "BTC-USDC" {
+		t.Errorf("alias_to = %#v", cols[12])
+	}
+	if cols[9] != nil || cols[19] != nil {
+		t.Errorf("absent fcm and future details = %v, %v, want NULL", cols[9], cols[19])
+	}
+
+	if _, err := coinbaseProductColumns("coinbase", []byte(`{"mid_market_price":"abc"}`)); err == nil {
+		t.Error("malformed mid_market_price succeeded, want an error")
+	}
+
+	// Other exchanges leave every Coinbase column NULL.
+	cols, err = coinbaseProductColumns("kraken", details)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, c := range cols {
+		if c != nil {
+			t.Errorf("kraken column %d = %v, want NULL", i, c)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// QuarantineCandles moves the candles of granularitySec seconds at the times in reasons from
+// candles to candles_quarantine, recording why each was removed. The buckets then count as
+// missing again. It returns the number of candles moved.
+func (s *Store) QuarantineCandles(ctx context.Context, exchange, product string, granularitySec int, reasons map[time.Time]string) (int, error) {
+	if len(reasons) == 0 {
+		return 0, nil
+	}
+	times := make([]time.Time, 0, len(reasons))
+	for t := range reasons {
+		times = append(times, t)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `
+		CREATE TEMP TABLE quarantine_in (
+			time TIMESTAMPTZ NOT NULL,
+			reason TEXT NOT NULL
+		) ON COMMIT DROP`); err != nil {
+		return 0, fmt.Errorf("create quarantine staging table: %w", err)
+	}
+	err = copyRows(ctx, tx, "quarantine_in", []string{"time", "reason"}, len(times), func(i int) []interface{} {
+		return []interface{}{times[i], reasons[times[i]]}
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := tx.ExecContext(ctx, `
+		WITH moved AS (
+			DELETE FROM candles c
+			USING quarantine_in q
+			WHERE c.exchange = $1 AND c.product_id = $2 AND c.granularity = $3 AND c.time = q.time
+			RETURNING c.exchange, c.product_id, c.granularity, c.time, c.open, c.high, c.low, c.close, c.volume,
+				c.ingested_at, q.reason
+		)
+		INSERT INTO candles_quarantine (exchange, product_id, granularity, time, open, high, low, close, volume,
+			ingested_at, reason)
+		SELECT * FROM moved
+		ON CONFLICT (exchange, product_id, granularity, time) DO UPDATE
+		SET open = EXCLUDED.open, high = EXCLUDED.high, low = EXCLUDED.low, close = EXCLUDED.close,
+			volume = EXCLUDED.volume, ingested_at = EXCLUDED.ingested_at, reason = EXCLUDED.reason,
+			quarantined_at = now()
+	`, exchange, product, granularitySec)
+	if err != nil {
+		return 0, fmt.Errorf("quarantine candles: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("get rows affected: %w", err)
+	}
+	return int(n), tx.Commit()
+}
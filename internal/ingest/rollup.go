@@ -0,0 +1,483 @@
+package ingest
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"cryptool/internal/exchange"
+)
+
+// rollupOverlap is how far before the stored watermark a rollup re-reads 1-minute candles, so
+// rows written by transactions that committed after the last run started are not missed.
+const rollupOverlap = 5 * time.Minute
+
+// RollupResult reports one RollupCandles run.
+type RollupResult struct {
+	Candles   int       // target candles inserted or changed
+	Watermark time.Time // newest 1-minute candle or exhausted gap covered by the run
+}
+
+// checkRollupGranularity reports whether 1-minute candles can be rolled up into buckets of
+// granularitySec seconds: a whole number of minutes above one that divides a day, so buckets
+// start on the same boundaries as the exchanges' own candles.
+func checkRollupGranularity(granularitySec int) error {
+	if granularitySec <= 60 || granularitySec%60 != 0 || 86400%granularitySec != 0 {
+		return fmt.Errorf("cannot roll up 1m candles into %ds buckets", granularitySec)
+	}
+	return nil
+}
+
+// rollupViewName is the materialized view holding the granularitySec rollup of every product.
+func rollupViewName(granularitySec int) string {
+	return fmt.Sprintf("candles_rollup_%d", granularitySec)
+}
+
+// bucketStart is the SQL expression for the start of the granularitySec bucket holding ts.
+func bucketStart(ts string, granularitySec int) string {
+	return fmt.Sprintf("to_timestamp(floor(extract(epoch FROM %s) / %d) * %d)", ts, granularitySec, granularitySec)
+}
+
+// rollupSelect aggregates the 1-minute rows returned by minutes, which must select exchange,
+// product_id, bucket, time, open, high, low, close and volume, into granularitySec candles.
+// It applies the rules of rollupBuckets in SQL for the materialized views.
+func rollupSelect(granularitySec int, minutes string) string {
+	return fmt.Sprintf(`
+		SELECT a.exchange, a.product_id, a.bucket AS time, a.open, a.high, a.low, a.close, a.volume
+		FROM (
+			SELECT exchange, product_id, bucket,
+				(array_agg(open ORDER BY time))[1] AS open,
+				max(high) AS high,
+				min(low) AS low,
+				(array_agg(close ORDER BY time DESC))[1] AS close,
+				sum(volume) AS volume,
+				count(*) AS minutes
+			FROM (%[2]s) m
+			GROUP BY exchange, product_id, bucket
+		) a
+		WHERE a.bucket + interval '%[1]d seconds' <= now()
+			AND a.minutes + (
+				SELECT count(*)
+				FROM candle_gaps g,
+					generate_series(GREATEST(g.start_time, a.bucket),
+						LEAST(g.end_time, a.bucket + interval '%[1]d seconds') - interval '1 minute',
+						interval '1 minute') AS e(t)
+				WHERE g.exchange = a.exchange AND g.product_id = a.product_id AND g.granularity = 60
+					AND g.attempts >= %[3]d
+					AND g.start_time < a.bucket + interval '%[1]d seconds' AND g.end_time > a.bucket
+					AND NOT EXISTS (
+						SELECT 1 FROM candles c
+						WHERE c.exchange = a.exchange AND c.product_id = a.product_id AND c.granularity = 60 AND c.time = e.t
+					)
+			) >= %[1]d / 60`, granularitySec, minutes, MaxGapAttempts)
+}
+
+// rollupChunk is the span of 1-minute candles a rollup loads at once. Every rollup granularity
+// divides a day, so buckets never straddle chunks.
+const rollupChunk = 24 * time.Hour
+
+// rollupBounds returns the bounds of a run from the stored watermark and the time of the
+// previous run: buckets with 1-minute data written after since, and every bucket from the one
+// that was open at lastRun, are recomputed. Both are zero, meaning every bucket, for a full run
+// or the first one.
+func rollupBounds(watermark, lastRun sql.NullTime, full bool) (since, open time.Time) {
+	if full || !watermark.Valid {
+		return time.Time{}, time.Time{}
+	}
+	return watermark.Time.Add(-rollupOverlap), lastRun.Time
+}
+
+// rollupBuckets aggregates minutes, sorted oldest first, into granularitySec candles for the
+// buckets want accepts. Open and close come from the first and last minute of each bucket. A
+// bucket is only returned once it has closed by now and every minute in it either has a candle
+// or lies in one of gaps, the ranges the backfill gave up on.
+func rollupBuckets(minutes []exchange.Candle, gaps []Gap, granularitySec int, now time.Time, want func(time.Time) bool) []exchange.Candle {
+	step := time.Duration(granularitySec) * time.Second
+	have := make(map[time.Time]bool, len(minutes))
+	for _, m := range minutes {
+		have[m.Time] = true
+	}
+	var out []exchange.Candle
+	for i := 0; i < len(minutes); {
+		bucket := minutes[i].Time.Truncate(step)
+		end := bucket.Add(step)
+		j := i
+		for j < len(minutes) && minutes[j].Time.Before(end) {
+			j++
+		}
+		if want(bucket) && !end.After(now) && j-i+gapMinutes(gaps, have, bucket, end) >= granularitySec/60 {
+			c := exchange.Candle{Time: bucket, Open: minutes[i].Open, High: minutes[i].High, Low: minutes[i].Low,
+				Close: minutes[j-1].Close}
+			for _, m := range minutes[i:j] {
+				c.High = decimal.Max(c.High, m.High)
+				c.Low = decimal.Min(c.Low, m.Low)
+				c.Volume = c.Volume.Add(m.Volume)
+			}
+			out = append(out, c)
+		}
+		i = j
+	}
+	return out
+}
+
+// gapMinutes counts the minutes of [start, end) that lie in gaps and have no candle.
+func gapMinutes(gaps []Gap, have map[time.Time]bool, start, end time.Time) int {
+	n := 0
+	for _, g := range gaps {
+		from, to := g.Start, g.End
+		if from.Before(start) {
+			from = start
+		}
+		if to.After(end) {
+			to = end
+		}
+		for t := from; t.Before(to); t = t.Add(time.Minute) {
+			if !have[t] {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// rollupData is the 1-minute data of one product a rollup reads, and where it writes the
+// result. Gaps are only those the backfill gave up on.
+type rollupData interface {
+	// FirstMinute returns the time of the oldest 1-minute candle; ok is false when there is none.
+	FirstMinute(ctx context.Context) (t time.Time, ok bool, err error)
+	// ChangedBuckets returns the sorted starts of the granularitySec buckets holding a 1-minute
+	// candle ingested after since.
+	ChangedBuckets(ctx context.Context, granularitySec int, since time.Time) ([]time.Time, error)
+	// ExhaustedGaps returns the gaps last attempted after since that overlap [start, end), oldest
+	// first. Zero bounds are open.
+	ExhaustedGaps(ctx context.Context, since, start, end time.Time) ([]Gap, error)
+	// Minutes returns the 1-minute candles in [start, end), oldest first.
+	Minutes(ctx context.Context, start, end time.Time) ([]exchange.Candle, error)
+	// Upsert stores rolled-up candles and returns how many rows were inserted or changed.
+	Upsert(ctx context.Context, granularitySec int, candles []exchange.Candle) (int, error)
+}
+
+// rollupPlan is what one day of a run recomputes: every bucket from from on (unless from is
+// zero) plus the listed buckets.
+type rollupPlan struct {
+	from    time.Time
+	buckets map[time.Time]bool
+}
+
+func (p *rollupPlan) wants(bucket time.Time) bool {
+	return (!p.from.IsZero() && !bucket.Before(p.from)) || p.buckets[bucket]
+}
+
+// rollup recomputes the granularitySec buckets touched since since, plus every bucket from the
+// one open at lastRun, and returns how many candles were written. A zero since recomputes every
+// bucket. Minutes are loaded one rollupChunk at a time.
+func rollup(ctx context.Context, data rollupData, granularitySec int, since, lastRun, now time.Time) (int, error) {
+	step := time.Duration(granularitySec) * time.Second
+	plans := make(map[time.Time]*rollupPlan)
+	plan := func(t time.Time) *rollupPlan {
+		day := t.Truncate(rollupChunk)
+		if plans[day] == nil {
+			plans[day] = &rollupPlan{buckets: make(map[time.Time]bool)}
+		}
+		return plans[day]
+	}
+	from := func(t time.Time) {
+		t = t.Truncate(step)
+		plan(t).from = t
+		for day := t.Truncate(rollupChunk).Add(rollupChunk); day.Before(now); day = day.Add(rollupChunk) {
+			plan(day).from = day
+		}
+	}
+
+	if since.IsZero() {
+		first, ok, err := data.FirstMinute(ctx)
+		if err != nil || !ok {
+			return 0, err
+		}
+		from(first)
+	} else {
+		changed, err := data.ChangedBuckets(ctx, granularitySec, since)
+		if err != nil {
+			return 0, err
+		}
+		for _, b := range changed {
+			plan(b).buckets[b] = true
+		}
+		gaps, err := data.ExhaustedGaps(ctx, since, time.Time{}, time.Time{})
+		if err != nil {
+			return 0, err
+		}
+		for _, g := range gaps {
+			for b := g.Start.Truncate(step); b.Before(g.End); b = b.Add(step) {
+				plan(b).buckets[b] = true
+			}
+		}
+		if !lastRun.IsZero() {
+			from(lastRun)
+		}
+	}
+
+	days := make([]time.Time, 0, len(plans))
+	for day := range plans {
+		days = append(days, day)
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+
+	written := 0
+	for _, day := range days {
+		end := day.Add(rollupChunk)
+		minutes, err := data.Minutes(ctx, day, end)
+		if err != nil {
+			return written, err
+		}
+		gaps, err := data.ExhaustedGaps(ctx, time.Time{}, day, end)
+		if err != nil {
+			return written, err
+		}
+		candles := rollupBuckets(minutes, gaps, granularitySec, now, plans[day].wants)
+		if len(candles) == 0 {
+			continue
+		}
+		n, err := data.Upsert(ctx, granularitySec, candles)
+		if err != nil {
+			return written, err
+		}
+		written += n
+	}
+	return written, nil
+}
+
+// RollupCandles derives granularitySec candles for one product from its 1-minute candles and
+// stores them in candles, overwriting candles of that granularity fetched from the exchange.
+// Only buckets with 1-minute candles or exhausted gaps written since the last run, plus the
+// buckets that were still open then, are recomputed; full recomputes every bucket.
+func (s *Store) RollupCandles(ctx context.Context, exchange, product string, granularitySec int, full bool) (RollupResult, error) {
+	var res RollupResult
+	if err := checkRollupGranularity(granularitySec); err != nil {
+		return res, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return res, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext('rollup:' || $1 || ':' || $2 || ':' || $3::text))`,
+		exchange, product, granularitySec); err != nil {
+		return res, fmt.Errorf("lock rollup: %w", err)
+	}
+
+	var watermark, lastRun sql.NullTime
+	err = tx.QueryRowContext(ctx, `
+		SELECT watermark, updated_at FROM candle_rollups
+		WHERE exchange = $1 AND product_id = $2 AND granularity = $3
+	`, exchange, product, granularitySec).Scan(&watermark, &lastRun)
+	if err != nil && err != sql.ErrNoRows {
+		return res, fmt.Errorf("load rollup watermark: %w", err)
+	}
+
+	var newMark sql.NullTime
+	err = tx.QueryRowContext(ctx, `
+		SELECT GREATEST(
+			(SELECT max(ingested_at) FROM candles WHERE exchange = $1 AND product_id = $2 AND granularity = 60),
+			(SELECT max(last_attempt_at) FROM candle_gaps
+				WHERE exchange = $1 AND product_id = $2 AND granularity = 60 AND attempts >= $3)
+		)
+	`, exchange, product, MaxGapAttempts).Scan(&newMark)
+	if err != nil {
+		return res, fmt.Errorf("load rollup source watermark: %w", err)
+	}
+	if !newMark.Valid {
+		return res, nil // no 1-minute data yet
+	}
+
+	since, open := rollupBounds(watermark, lastRun, full)
+	n, err := rollup(ctx, &sqlRollupData{tx: tx, exchange: exchange, product: product}, granularitySec, since, open, time.Now())
+	if err != nil {
+		return res, fmt.Errorf("roll up candles: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO candle_rollups (exchange, product_id, granularity, watermark, updated_at)
+		VALUES ($1, $2, $3, $4, now())
+		ON CONFLICT (exchange, product_id, granularity) DO UPDATE
+		SET watermark = EXCLUDED.watermark, updated_at = EXCLUDED.updated_at
+	`, exchange, product, granularitySec, newMark.Time); err != nil {
+		return res, fmt.Errorf("save rollup watermark: %w", err)
+	}
+
+	res.Candles, res.Watermark = n, newMark.Time
+	return res, tx.Commit()
+}
+
+// sqlRollupData reads and writes one product's candles inside a rollup transaction.
+type sqlRollupData struct {
+	tx       *sql.Tx
+	exchange string
+	product  string
+	staged   bool // whether the rollup_in staging table exists
+}
+
+// nullTime maps the zero time to NULL.
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
+
+func (d *sqlRollupData) FirstMinute(ctx context.Context) (time.Time, bool, error) {
+	var first sql.NullTime
+	err := d.tx.QueryRowContext(ctx, `
+		SELECT min(time) FROM candles WHERE exchange = $1 AND product_id = $2 AND granularity = 60
+	`, d.exchange, d.product).Scan(&first)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("load first minute: %w", err)
+	}
+	return first.Time.UTC(), first.Valid, nil
+}
+
+func (d *sqlRollupData) ChangedBuckets(ctx context.Context, granularitySec int, since time.Time) ([]time.Time, error) {
+	rows, err := d.tx.QueryContext(ctx, fmt.Sprintf(`
+		SELECT DISTINCT %s AS bucket
+		FROM candles
+		WHERE exchange = $1 AND product_id = $2 AND granularity = 60 AND ingested_at > $3
+		ORDER BY bucket`, bucketStart("time", granularitySec)), d.exchange, d.product, since)
+	if err != nil {
+		return nil, fmt.Errorf("load changed buckets: %w", err)
+	}
+	defer rows.Close()
+	var out []time.Time
+	for rows.Next() {
+		var t time.Time
+		if err := rows.Scan(&t); err != nil {
+			return nil, err
+		}
+		out = append(out, t.UTC())
+	}
+	return out, rows.Err()
+}
+
+func (d *sqlRollupData) ExhaustedGaps(ctx context.Context, since, start, end time.Time) ([]Gap, error) {
+	rows, err := d.tx.QueryContext(ctx, `
+		SELECT start_time, end_time, attempts, last_attempt_at
+		FROM candle_gaps
+		WHERE exchange = $1 AND product_id = $2 AND granularity = 60 AND attempts >= $3
+			AND ($4::timestamptz IS NULL OR last_attempt_at > $4)
+			AND ($5::timestamptz IS NULL OR end_time > $5)
+			AND ($6::timestamptz IS NULL OR start_time < $6)
+		ORDER BY start_time`, d.exchange, d.product, MaxGapAttempts, nullTime(since), nullTime(start), nullTime(end))
+	if err != nil {
+		return nil, fmt.Errorf("load exhausted gaps: %w", err)
+	}
+	defer rows.Close()
+	var out []Gap
+	for rows.Next() {
+		var g Gap
+		if err := rows.Scan(&g.Start, &g.End, &g.Attempts, &g.LastAttemptAt); err != nil {
+			return nil, err
+		}
+		g.Start, g.End = g.Start.UTC(), g.End.UTC()
+		out = append(out, g)
+	}
+	return out, rows.Err()
+}
+
+func (d *sqlRollupData) Minutes(ctx context.Context, start, end time.Time) ([]exchange.Candle, error) {
+	rows, err := d.tx.QueryContext(ctx, `
+		SELECT time, open, high, low, close, volume
+		FROM candles
+		WHERE exchange = $1 AND product_id = $2 AND granularity = 60 AND time >= $3 AND time < $4
+		ORDER BY time`, d.exchange, d.product, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("load 1m candles: %w", err)
+	}
+	defer rows.Close()
+	var out []exchange.Candle
+	for rows.Next() {
+		var c exchange.Candle
+		if err := rows.Scan(&c.Time, &c.Open, &c.High, &c.Low, &c.Close, &c.Volume); err != nil {
+			return nil, err
+		}
+		c.Time = c.Time.UTC()
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+// Upsert stages candles with COPY and merges them in one statement. Unchanged rows are left
+// alone, so their ingested_at keeps its value.
+func (d *sqlRollupData) Upsert(ctx context.Context, granularitySec int, candles []exchange.Candle) (int, error) {
+	if !d.staged {
+		if _, err := d.tx.ExecContext(ctx, `
+			CREATE TEMP TABLE rollup_in (
+				time TIMESTAMPTZ NOT NULL,
+				open NUMERIC NOT NULL,
+				high NUMERIC NOT NULL,
+				low NUMERIC NOT NULL,
+				close NUMERIC NOT NULL,
+				volume NUMERIC NOT NULL
+			) ON COMMIT DROP`); err != nil {
+			return 0, fmt.Errorf("create rollup staging table: %w", err)
+		}
+		d.staged = true
+	} else if _, err := d.tx.ExecContext(ctx, `TRUNCATE rollup_in`); err != nil {
+		return 0, fmt.Errorf("clear rollup staging table: %w", err)
+	}
+	err := copyRows(ctx, d.tx, "rollup_in", []string{"time", "open", "high", "low", "close", "volume"}, len(candles), func(i int) []interface{} {
+		c := candles[i]
+		return []interface{}{c.Time, c.Open, c.High, c.Low, c.Close, c.Volume}
+	})
+	if err != nil {
+		return 0, err
+	}
+	res, err := d.tx.ExecContext(ctx, `
+		INSERT INTO candles (exchange, product_id, granularity, time, open, high, low, close, volume)
+		SELECT $1, $2, $3, time, open, high, low, close, volume FROM rollup_in
+		ON CONFLICT (exchange, product_id, granularity, time) DO UPDATE
+		SET open = EXCLUDED.open, high = EXCLUDED.high, low = EXCLUDED.low, close = EXCLUDED.close,
+			volume = EXCLUDED.volume, ingested_at = now()
+		WHERE (candles.open, candles.high, candles.low, candles.close, candles.volume)
+			IS DISTINCT FROM (EXCLUDED.open, EXCLUDED.high, EXCLUDED.low, EXCLUDED.close, EXCLUDED.volume)
+	`, d.exchange, d.product, granularitySec)
+	if err != nil {
+		return 0, fmt.Errorf("upsert rolled-up candles: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("get rows affected: %w", err)
+	}
+	return int(n), nil
+}
+
+// RefreshRollupView creates, if needed, and refreshes a materialized view with the
+// granularitySec rollup of every product's 1-minute candles, using the same completeness rules
+// as RollupCandles. It returns the view name. A refresh recomputes the whole view.
+func (s *Store) RefreshRollupView(ctx context.Context, granularitySec int) (string, error) {
+	if err := checkRollupGranularity(granularitySec); err != nil {
+		return "", err
+	}
+	view := rollupViewName(granularitySec)
+	minutes := fmt.Sprintf(`
+		SELECT exchange, product_id, %s AS bucket, time, open, high, low, close, volume
+		FROM candles
+		WHERE granularity = 60`, bucketStart("time", granularitySec))
+
+	if _, err := s.db.ExecContext(ctx, fmt.Sprintf(`
+		CREATE MATERIALIZED VIEW IF NOT EXISTS %s AS
+		SELECT r.exchange, r.product_id, %d AS granularity, r.time, r.open, r.high, r.low, r.close, r.volume
+		FROM (%s) r
+		WITH NO DATA
+	`, view, granularitySec, rollupSelect(granularitySec, minutes))); err != nil {
+		return "", fmt.Errorf("create %s: %w", view, err)
+	}
+	if _, err := s.db.ExecContext(ctx, fmt.Sprintf(`CREATE UNIQUE INDEX IF NOT EXISTS %[1]s_key ON %[1]s (exchange, product_id, time)`, view)); err != nil {
+		return "", fmt.Errorf("index %s: %w", view, err)
+	}
+	if _, err := s.db.ExecContext(ctx, `REFRESH MATERIALIZED VIEW `+view); err != nil {
+		return "", fmt.Errorf("refresh %s: %w", view, err)
+	}
+	return view, nil
+}
@@ -0,0 +1,205 @@
+package ingest
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"cryptool/internal/exchange"
+)
+
+func TestCheckRollupGranularity(t *testing.T) {
+	for _, sec := range []int{300, 900, 1800, 3600, 7200, 14400, 21600, 86400} {
+		if err := checkRollupGranularity(sec); err != nil {
+			t.Errorf("checkRollupGranularity(%d) = %v, want nil", sec, err)
+		}
+	}
+	// 1m is the source itself; the rest do not split a day into whole-minute buckets.
+	for _, sec := range []int{0, 30, 60, 90, 420, 25200, 172800} {
+		if err := checkRollupGranularity(sec); err == nil {
+			t.Errorf("checkRollupGranularity(%d) = nil, want an error", sec)
+		}
+	}
+}
+
+// fakeRollupData holds one product's 1-minute candles and gaps in memory and records what a run
+// upserts.
+type fakeRollupData struct {
+	minutes  []fakeMinute
+	gaps     []Gap
+	upserted map[time.Time]exchange.Candle
+	written  []time.Time // buckets passed to Upsert by the last run
+}
+
+type fakeMinute struct {
+	exchange.Candle
+	ingestedAt time.Time
+}
+
+func (f *fakeRollupData) FirstMinute(ctx context.Context) (time.Time, bool, error) {
+	if len(f.minutes) == 0 {
+		return time.Time{}, false, nil
+	}
+	first := f.minutes[0].Time
+	for _, m := range f.minutes {
+		if m.Time.Before(first) {
+			first = m.Time
+		}
+	}
+	return first, true, nil
+}
+
+func (f *fakeRollupData) ChangedBuckets(ctx context.Context, granularitySec int, since time.Time) ([]time.Time, error) {
+	seen := make(map[time.Time]bool)
+	var out []time.Time
+	for _, m := range f.minutes {
+		b := m.Time.Truncate(time.Duration(granularitySec) * time.Second)
+		if m.ingestedAt.After(since) && !seen[b] {
+			seen[b] = true
+			out = append(out, b)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Before(out[j]) })
+	return out, nil
+}
+
+func (f *fakeRollupData) ExhaustedGaps(ctx context.Context, since, start, end time.Time) ([]Gap, error) {
+	var out []Gap
+	for _, g := range f.gaps {
+		if g.Attempts >= MaxGapAttempts && (since.IsZero() || g.LastAttemptAt.After(since)) &&
+			(start.IsZero() || g.End.After(start)) && (end.IsZero() || g.Start.Before(end)) {
+			out = append(out, g)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeRollupData) Minutes(ctx context.Context, start, end time.Time) ([]exchange.Candle, error) {
+	var out []exchange.Candle
+	for _, m := range f.minutes {
+		if !m.Time.Before(start) && m.Time.Before(end) {
+			out = append(out, m.Candle)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Time.Before(out[j].Time) })
+	return out, nil
+}
+
+func (f *fakeRollupData) Upsert(ctx context.Context, granularitySec int, candles []exchange.Candle) (int, error) {
+	if f.upserted == nil {
+		f.upserted = make(map[time.Time]exchange.Candle)
+	}
+	n := 0
+	for _, c := range candles {
+		f.written = append(f.written, c.Time)
+		if old, ok := f.upserted[c.Time]; !ok || !reflect.DeepEqual(old, c) {
+			f.upserted[c.Time] = c
+			n++
+		}
+	}
+	return n, nil
+}
+
+// add stores 1-minute candles at the given offsets from base, ingested at ingestedAt. Each
+// candle's prices are its offset in minutes, so aggregates are easy to check.
+func (f *fakeRollupData) add(base, ingestedAt time.Time, offsets ...int) {
+	for _, o := range offsets {
+		p := decimal.NewFromInt(int64(o))
+		f.minutes = append(f.minutes, fakeMinute{
+			Candle: exchange.Candle{Time: base.Add(time.Duration(o) * time.Minute),
+				Open: p, High: p.Add(decimal.NewFromInt(10)), Low: p.Sub(decimal.NewFromInt(10)), Close: p, Volume: decimal.NewFromInt(1)},
+			ingestedAt: ingestedAt,
+		})
+	}
+}
+
+func TestRollupBuckets(t *testing.T) {
+	base := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	f := &fakeRollupData{}
+	f.add(base, base, 0, 1, 2, 3, 4)          // complete
+	f.add(base, base, 5, 6, 8, 9)             // partial: 00:07 is missing
+	f.add(base, base, 10, 11, 13)             // 00:12 and 00:14 lie in a gap written off
+	f.add(base, base, 55, 56, 57, 58, 59, 60) // the 01:00 bucket is still open
+	gaps := []Gap{{Start: base.Add(12 * time.Minute), End: base.Add(15 * time.Minute), Attempts: MaxGapAttempts}}
+	minutes, _ := f.Minutes(context.Background(), base, base.Add(rollupChunk))
+	all := func(time.Time) bool { return true }
+
+	got := rollupBuckets(minutes, gaps, 300, base.Add(time.Hour), all)
+	var times []time.Time
+	for _, c := range got {
+		times = append(times, c.Time)
+	}
+	want := []time.Time{base, base.Add(10 * time.Minute), base.Add(55 * time.Minute)}
+	if !reflect.DeepEqual(times, want) {
+		t.Fatalf("buckets = %v, want %v", times, want)
+	}
+	if c := got[0]; c.Open.String() != "0" || c.Close.String() != "4" || c.High.String() != "14" ||
+		c.Low.String() != "-10" || c.Volume.String() != "5" {
+		t.Errorf("00:00 candle = %+v", c)
+	}
+	if c := got[1]; c.Open.String() != "10" || c.Close.String() != "13" || c.Volume.String() != "3" {
+		t.Errorf("00:10 candle = %+v", c)
+	}
+
+	if got := rollupBuckets(minutes, nil, 300, base.Add(time.Hour), all); len(got) != 2 {
+		t.Errorf("without the gap got %d candles, want 2", len(got))
+	}
+	only := func(b time.Time) bool { return b.Equal(base.Add(10 * time.Minute)) }
+	if got := rollupBuckets(minutes, gaps, 300, base.Add(time.Hour), only); len(got) != 1 || !got[0].Time.Equal(base.Add(10*time.Minute)) {
+		t.Errorf("rollupBuckets limited to 00:10 = %v", got)
+	}
+}
+
+func TestRollupIncremental(t *testing.T) {
+	ctx := context.Background()
+	base := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	at := func(m int) time.Time { return base.Add(time.Duration(m) * time.Minute) }
+	f := &fakeRollupData{}
+	f.add(base, at(1), 0, 1, 2, 3, 4, 5, 6, 8, 9, 10, 11, 12, 13)
+	f.add(base, at(32), 20, 21, 22, 23, 24, 25, 26, 27, 28, 29, 30, 31)
+
+	firstRun := at(33)
+	since, open := rollupBounds(sql.NullTime{}, sql.NullTime{}, false)
+	n, err := rollup(ctx, f, 300, since, open, firstRun)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 00:05 lacks 00:07, 00:10 lacks 00:14 and 00:30 is still open.
+	if want := []time.Time{at(0), at(20), at(25)}; n != 3 || !reflect.DeepEqual(f.written, want) {
+		t.Fatalf("first run wrote %d candles %v, want %v", n, f.written, want)
+	}
+
+	// 00:14 arrives from a transaction that committed late, 00:07 is written off as a gap and
+	// 00:30 fills up. 00:00 is left alone; 00:20 and 00:25 are within the overlap and unchanged.
+	f.add(base, at(30), 14)
+	f.add(base, at(35), 32, 33, 34)
+	f.gaps = []Gap{{Start: at(7), End: at(8), Attempts: MaxGapAttempts, LastAttemptAt: at(35)}}
+	f.written = nil
+	watermark := sql.NullTime{Time: at(32), Valid: true}
+	lastRun := sql.NullTime{Time: firstRun, Valid: true}
+	since, open = rollupBounds(watermark, lastRun, false)
+	if n, err = rollup(ctx, f, 300, since, open, at(36)); err != nil {
+		t.Fatal(err)
+	}
+	if want := []time.Time{at(5), at(10), at(20), at(25), at(30)}; !reflect.DeepEqual(f.written, want) {
+		t.Errorf("second run recomputed %v, want %v", f.written, want)
+	}
+	if n != 3 {
+		t.Errorf("second run wrote %d candles, want 3", n)
+	}
+
+	// A full run recomputes every bucket and finds nothing to change.
+	f.written = nil
+	since, open = rollupBounds(watermark, lastRun, true)
+	if n, err = rollup(ctx, f, 300, since, open, at(36)); err != nil || n != 0 {
+		t.Errorf("full run = %d, %v, want 0 changes", n, err)
+	}
+	if len(f.written) != 6 {
+		t.Errorf("full run recomputed %v, want all 6 buckets", f.written)
+	}
+}
@@ -4,45 +4,72 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"strconv"
+	"sort"
 	"time"
 
 	"cryptool/internal/coinbase"
+	"cryptool/internal/db"
+	"cryptool/internal/exchange"
+	"cryptool/internal/orderbook"
 	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
 )
 
+// Store persists exchange data in Postgres. It holds one connection pool for its whole
+// lifetime and is safe for concurrent use; call Close when done.
 type Store struct {
-	url string
+	db *sql.DB
 }
 
-func NewStore(url string) *Store {
-	return &Store{url: url}
+// NewStore opens a pool for url bounded by pool. It does not connect; use Ping to check
+// that the database is reachable.
+func NewStore(url string, pool db.Pool) (*Store, error) {
+	conn, err := db.OpenPool(url, pool)
+	if err != nil {
+		return nil, fmt.Errorf("open database: %w", err)
+	}
+	return &Store{db: conn}, nil
+}
+
+// Close closes the connection pool.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Ping checks that the database is reachable.
+func (s *Store) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// Stats returns the connection pool statistics.
+func (s *Store) Stats() sql.DBStats {
+	return s.db.Stats()
 }
 
 // CountGapsToFill identifies how many candle-sized gaps exist in a given time range that are still worth filling.
-// It works by: 
+// It works by:
 // 1. Generating a series of all expected timestamps in the [start, end) range for the given granularity.
 // 2. LEFT JOINing this series with the `candles` table.
-// 3. Counting the timestamps that are either NOT in the candles table (NULL) or ARE in the table but have a `fake_fill_count` < 5.
+// 3. Counting the timestamps that are NOT in the candles table (NULL) and not inside a `candle_gaps` range that
+//    has reached MaxGapAttempts.
 // This gives us the precise number of candles we need to fetch from the API, excluding gaps we've given up on.
 func (s *Store) CountGapsToFill(ctx context.Context, exchange, product string, start, end time.Time, granularitySec int) (int, error) {
-	db, err := sql.Open("postgres", s.url)
-	if err != nil {
-		return 0, err
-	}
-	defer db.Close()
-
 	var cnt int
-	err = db.QueryRowContext(ctx, `
+	err := s.db.QueryRowContext(ctx, `
 		WITH expected_times AS (
 			SELECT generate_series($3::timestamptz, $4::timestamptz - interval '1 second', $5::interval) as t
 		)
 		SELECT COUNT(e.t)
 		FROM expected_times e
-		LEFT JOIN candles c ON e.t = c.time AND c.exchange = $1 AND c.product_id = $2
-		WHERE c.time IS NULL OR (c.volume = -1 AND c.fake_fill_count < 5)
-	`, exchange, product, start, end, fmt.Sprintf("%d seconds", granularitySec)).Scan(&cnt)
+		LEFT JOIN candles c ON e.t = c.time AND c.exchange = $1 AND c.product_id = $2 AND c.granularity = $6
+		WHERE c.time IS NULL AND NOT EXISTS (
+			SELECT 1 FROM candle_gaps g
+			WHERE g.exchange = $1 AND g.product_id = $2 AND g.granularity = $6
+				AND g.start_time <= e.t AND g.end_time > e.t AND g.attempts >= $7
+		)
+	`, exchange, product, start, end, fmt.Sprintf("%d seconds", granularitySec), granularitySec, MaxGapAttempts).Scan(&cnt)
 
 	if err != nil {
 		return 0, fmt.Errorf("counting gaps to fill: %w", err)
@@ -52,21 +79,19 @@ func (s *Store) CountGapsToFill(ctx context.Context, exchange, product string, s
 
 // GetMissingCandleTimestamps returns a slice of the exact timestamps that are missing or need to be retried within a given range.
 func (s *Store) GetMissingCandleTimestamps(ctx context.Context, exchange, product string, start, end time.Time, granularitySec int) ([]time.Time, error) {
-	db, err := sql.Open("postgres", s.url)
-	if err != nil {
-		return nil, err
-	}
-	defer db.Close()
-
-	rows, err := db.QueryContext(ctx, `
+	rows, err := s.db.QueryContext(ctx, `
 		WITH expected_times AS (
 			SELECT generate_series($3::timestamptz, $4::timestamptz - interval '1 second', $5::interval) as t
 		)
 		SELECT e.t
 		FROM expected_times e
-		LEFT JOIN candles c ON e.t = c.time AND c.exchange = $1 AND c.product_id = $2
-		WHERE c.time IS NULL OR (c.volume = -1 AND c.fake_fill_count < 5)
-	`, exchange, product, start, end, fmt.Sprintf("%d seconds", granularitySec))
+		LEFT JOIN candles c ON e.t = c.time AND c.exchange = $1 AND c.product_id = $2 AND c.granularity = $6
+		WHERE c.time IS NULL AND NOT EXISTS (
+			SELECT 1 FROM candle_gaps g
+			WHERE g.exchange = $1 AND g.product_id = $2 AND g.granularity = $6
+				AND g.start_time <= e.t AND g.end_time > e.t AND g.attempts >= $7
+		)
+	`, exchange, product, start, end, fmt.Sprintf("%d seconds", granularitySec), granularitySec, MaxGapAttempts)
 
 	if err != nil {
 		return nil, fmt.Errorf("querying for missing timestamps: %w", err)
@@ -85,81 +110,71 @@ func (s *Store) GetMissingCandleTimestamps(ctx context.Context, exchange, produc
 	return missing, rows.Err()
 }
 
-// CountCandlesInRange returns how many candles exist for an exchange/product in [start, end).
-func (s *Store) CountCandlesInRange(ctx context.Context, exchange, product string, start, end time.Time) (int, error) {
-	db, err := sql.Open("postgres", s.url)
-	if err != nil {
-		return 0, err
-	}
-	defer db.Close()
-
+// CountCandlesInRange returns how many candles exist for an exchange/product/granularity in [start, end).
+func (s *Store) CountCandlesInRange(ctx context.Context, exchange, product string, granularitySec int, start, end time.Time) (int, error) {
 	var cnt int
-	// We ignore candles with volume < 0, as these are our fake candles marking gaps.
-	err = db.QueryRowContext(ctx, `
+	err := s.db.QueryRowContext(ctx, `
         SELECT COUNT(*)
         FROM candles
-        WHERE exchange = $1 AND product_id = $2 AND time >= $3 AND time < $4 AND volume >= 0
-    `, exchange, product, start, end).Scan(&cnt)
+        WHERE exchange = $1 AND product_id = $2 AND granularity = $3 AND time >= $4 AND time < $5
+    `, exchange, product, granularitySec, start, end).Scan(&cnt)
 	if err != nil {
 		return 0, err
 	}
 	return cnt, nil
 }
 
-// GetProductNewAt returns the new_at timestamp for a given product.
-func (s *Store) GetCandleFillCount(ctx context.Context, exchange, product string, t time.Time) (int, error) {
-	db, err := sql.Open("postgres", s.url)
+// GetCandles returns the candles of granularitySec seconds for an exchange/product in [start, end), oldest first.
+func (s *Store) GetCandles(ctx context.Context, exchangeName, product string, granularitySec int, start, end time.Time) ([]exchange.Candle, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT time, open, high, low, close, volume
+		FROM candles
+		WHERE exchange = $1 AND product_id = $2 AND granularity = $3 AND time >= $4 AND time < $5
+		ORDER BY time
+	`, exchangeName, product, granularitySec, start, end)
 	if err != nil {
-		return 0, err
+		return nil, fmt.Errorf("query candles: %w", err)
 	}
-	defer db.Close()
+	defer rows.Close()
 
-	var count int
-	err = db.QueryRowContext(ctx, `
-		SELECT fake_fill_count
-		FROM candles
-		WHERE exchange = $1 AND product_id = $2 AND time = $3 AND volume = -1
-	`, exchange, product, t).Scan(&count)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return 0, nil // No fake candle exists, so count is 0
+	var out []exchange.Candle
+	for rows.Next() {
+		var c exchange.Candle
+		if err := rows.Scan(&c.Time, &c.Open, &c.High, &c.Low, &c.Close, &c.Volume); err != nil {
+			return nil, fmt.Errorf("scan candle: %w", err)
 		}
-		return 0, err
+		out = append(out, c)
 	}
-	return count, nil
+	return out, rows.Err()
 }
 
-func (s *Store) GetProductNewAt(ctx context.Context, exchange, product string) (time.Time, error) {
-	db, err := sql.Open("postgres", s.url)
-	if err != nil {
-		return time.Time{}, err
-	}
-	defer db.Close()
+// ErrNoListingDate is returned by GetProductNewAt when the exchange did not report when the
+// product was listed.
+var ErrNoListingDate = errors.New("new_at is null")
 
+// GetProductNewAt returns the new_at timestamp for a given product.
+func (s *Store) GetProductNewAt(ctx context.Context, exchange, product string) (time.Time, error) {
 	var newAt pq.NullTime
-	err = db.QueryRowContext(ctx, `
+	err := s.db.QueryRowContext(ctx, `
 		SELECT new_at
 		FROM products
 		WHERE exchange = $1 AND product_id = $2
 	`, exchange, product).Scan(&newAt)
+	if err == sql.ErrNoRows {
+		return time.Time{}, fmt.Errorf("product %s not found", product)
+	}
 	if err != nil {
 		return time.Time{}, err
 	}
 	if !newAt.Valid {
-		return time.Time{}, fmt.Errorf("product %s not found or new_at is null", product)
+		return time.Time{}, fmt.Errorf("product %s: %w", product, ErrNoListingDate)
 	}
 	return newAt.Time, nil
 }
 
 // GetAllProducts retrieves all product IDs for a given exchange.
 func (s *Store) GetAllProducts(ctx context.Context, exchange string) ([]string, error) {
-	db, err := sql.Open("postgres", s.url)
-	if err != nil {
-		return nil, err
-	}
-	defer db.Close()
-
-	rows, err := db.QueryContext(ctx, `
+	rows, err := s.db.QueryContext(ctx, `
 		SELECT product_id
 		FROM products
 		WHERE exchange = $1 AND is_disabled = false AND trading_disabled = false
@@ -182,56 +197,55 @@ func (s *Store) GetAllProducts(ctx context.Context, exchange string) ([]string,
 	return products, rows.Err()
 }
 
-func (s *Store) UpsertProducts(ctx context.Context, exchange string, products []coinbase.Product) (int, error) {
-	db, err := sql.Open("postgres", s.url)
-	if err != nil {
-		return 0, err
-	}
-	defer db.Close()
-
-	tx, err := db.BeginTx(ctx, nil)
+// UpsertProducts stores exchange-neutral products. The exchange's raw product payload is kept
+// in the details column. For Coinbase the columns added by migration 0004 are filled from that
+// payload too; other exchanges leave them NULL.
+func (s *Store) UpsertProducts(ctx context.Context, exchange string, products []exchange.Product) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return 0, err
 	}
 
 	stmt, err := tx.PrepareContext(ctx, `
 		INSERT INTO products(
-			exchange, product_id, base_name, quote_name, is_disabled, price, 
-			price_percentage_change_24h, volume_24h, volume_percentage_change_24h, 
-			base_increment, quote_increment, quote_min_size, quote_max_size, 
-			base_min_size, base_max_size, watched, is_new, status, cancel_only, 
-			limit_only, post_only, trading_disabled, auction_mode, product_type, 
-			quote_currency_id, base_currency_id, fcm_trading_session_details, 
-			mid_market_price, alias, alias_to, base_display_symbol, 
-			quote_display_symbol, view_only, price_increment, display_name, 
-			product_venue, approximate_quote_24h_volume, new_at, future_product_details
+			exchange, product_id, base_name, quote_name, is_disabled, trading_disabled, status,
+			base_currency_id, quote_currency_id, price, volume_24h, base_increment, quote_increment,
+			price_increment, base_min_size, base_max_size, quote_min_size, quote_max_size, new_at, details,
+			price_percentage_change_24h, volume_percentage_change_24h, watched, is_new, cancel_only,
+			limit_only, post_only, auction_mode, product_type, fcm_trading_session_details,
+			mid_market_price, alias, alias_to, base_display_symbol, quote_display_symbol, view_only,
+			display_name, product_venue, approximate_quote_24h_volume, future_product_details
 		)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25, $26, $27, $28, $29, $30, $31, $32, $33, $34, $35, $36, $37, $38, $39)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20,
+			$21, $22, $23, $24, $25, $26, $27, $28, $29, $30, $31, $32, $33, $34, $35, $36, $37, $38, $39, $40)
 		ON CONFLICT (exchange, product_id) DO UPDATE SET
 			base_name = EXCLUDED.base_name,
 			quote_name = EXCLUDED.quote_name,
 			is_disabled = EXCLUDED.is_disabled,
+			trading_disabled = EXCLUDED.trading_disabled,
+			status = EXCLUDED.status,
+			base_currency_id = EXCLUDED.base_currency_id,
+			quote_currency_id = EXCLUDED.quote_currency_id,
 			price = EXCLUDED.price,
-			price_percentage_change_24h = EXCLUDED.price_percentage_change_24h,
 			volume_24h = EXCLUDED.volume_24h,
-			volume_percentage_change_24h = EXCLUDED.volume_percentage_change_24h,
 			base_increment = EXCLUDED.base_increment,
 			quote_increment = EXCLUDED.quote_increment,
-			quote_min_size = EXCLUDED.quote_min_size,
-			quote_max_size = EXCLUDED.quote_max_size,
+			price_increment = EXCLUDED.price_increment,
 			base_min_size = EXCLUDED.base_min_size,
 			base_max_size = EXCLUDED.base_max_size,
+			quote_min_size = EXCLUDED.quote_min_size,
+			quote_max_size = EXCLUDED.quote_max_size,
+			new_at = EXCLUDED.new_at,
+			details = EXCLUDED.details,
+			price_percentage_change_24h = EXCLUDED.price_percentage_change_24h,
+			volume_percentage_change_24h = EXCLUDED.volume_percentage_change_24h,
 			watched = EXCLUDED.watched,
 			is_new = EXCLUDED.is_new,
-			status = EXCLUDED.status,
 			cancel_only = EXCLUDED.cancel_only,
 			limit_only = EXCLUDED.limit_only,
 			post_only = EXCLUDED.post_only,
-			trading_disabled = EXCLUDED.trading_disabled,
 			auction_mode = EXCLUDED.auction_mode,
 			product_type = EXCLUDED.product_type,
-			quote_currency_id = EXCLUDED.quote_currency_id,
-			base_currency_id = EXCLUDED.base_currency_id,
 			fcm_trading_session_details = EXCLUDED.fcm_trading_session_details,
 			mid_market_price = EXCLUDED.mid_market_price,
 			alias = EXCLUDED.alias,
@@ -239,11 +253,9 @@ func (s *Store) UpsertProducts(ctx context.Context, exchange string, products []
 			base_display_symbol = EXCLUDED.base_display_symbol,
 			quote_display_symbol = EXCLUDED.quote_display_symbol,
 			view_only = EXCLUDED.view_only,
-			price_increment = EXCLUDED.price_increment,
 			display_name = EXCLUDED.display_name,
 			product_venue = EXCLUDED.product_venue,
 			approximate_quote_24h_volume = EXCLUDED.approximate_quote_24h_volume,
-			new_at = EXCLUDED.new_at,
 			future_product_details = EXCLUDED.future_product_details
 	`)
 	if err != nil {
@@ -254,25 +266,36 @@ func (s *Store) UpsertProducts(ctx context.Context, exchange string, products []
 
 	var rowsAffectedCount int64
 	for _, p := range products {
-		fcmDetails, err := json.Marshal(p.FcmTradingSessionDetails)
-		if err != nil {
-			return 0, fmt.Errorf("marshal fcm_trading_session_details for %s: %w", p.ProductID, err)
+		args := []interface{}{exchange, p.ProductID, p.BaseName, p.QuoteName, p.Disabled,
+			p.TradingDisabled, p.Status, p.BaseCurrency, p.QuoteCurrency}
+		for _, f := range []struct{ name, value string }{
+			{"price", p.Price}, {"volume_24h", p.Volume24h}, {"base_increment", p.BaseIncrement},
+			{"quote_increment", p.QuoteIncrement}, {"price_increment", p.PriceIncrement},
+			{"base_min_size", p.BaseMinSize}, {"base_max_size", p.BaseMaxSize},
+			{"quote_min_size", p.QuoteMinSize}, {"quote_max_size", p.QuoteMaxSize},
+		} {
+			d, err := nullDecimal(f.name, f.value)
+			if err != nil {
+				tx.Rollback()
+				return 0, fmt.Errorf("product %s: %w", p.ProductID, err)
+			}
+			args = append(args, d)
 		}
-		futureDetails, err := json.Marshal(p.FutureProductDetails)
+		var newAt sql.NullTime
+		if !p.ListedAt.IsZero() {
+			newAt = sql.NullTime{Time: p.ListedAt, Valid: true}
+		}
+		var details []byte
+		if len(p.Details) > 0 {
+			details = p.Details
+		}
+		extra, err := coinbaseProductColumns(exchange, details)
 		if err != nil {
-			return 0, fmt.Errorf("marshal future_product_details for %s: %w", p.ProductID, err)
+			tx.Rollback()
+			return 0, fmt.Errorf("product %s: %w", p.ProductID, err)
 		}
 
-		res, err := stmt.ExecContext(ctx, exchange, p.ProductID, p.BaseName, p.QuoteName, p.IsDisabled, 
-			parseFloat(p.Price), parseFloat(p.PricePercentageChange24h), parseFloat(p.Volume24h), 
-			parseFloat(p.VolumePercentageChange24h), parseFloat(p.BaseIncrement), parseFloat(p.QuoteIncrement), 
-			parseFloat(p.QuoteMinSize), parseFloat(p.QuoteMaxSize), parseFloat(p.BaseMinSize), 
-			parseFloat(p.BaseMaxSize), p.Watched, p.New, p.Status, p.CancelOnly, p.LimitOnly, p.PostOnly, 
-			p.TradingDisabled, p.AuctionMode, p.ProductType, p.QuoteCurrencyID, p.BaseCurrencyID, 
-			fcmDetails, parseFloat(p.MidMarketPrice), p.Alias, pq.Array(p.AliasTo), p.BaseDisplaySymbol, 
-			p.QuoteDisplaySymbol, p.ViewOnly, parseFloat(p.PriceIncrement), p.DisplayName, p.ProductVenue, 
-			parseFloat(p.ApproximateQuote24hVolume), p.NewAt, futureDetails)
-
+		res, err := stmt.ExecContext(ctx, append(append(args, newAt, details), extra...)...)
 		if err != nil {
 			tx.Rollback()
 			return 0, fmt.Errorf("upsert product %s: %w", p.ProductID, err)
@@ -288,41 +311,147 @@ func (s *Store) UpsertProducts(ctx context.Context, exchange string, products []
 	return int(rowsAffectedCount), tx.Commit()
 }
 
-func parseFloat(s string) float64 {
-	f, err := strconv.ParseFloat(s, 64)
+// coinbaseProductColumnCount is the number of Coinbase-only columns UpsertProducts writes.
+const coinbaseProductColumnCount = 20
+
+// coinbaseProductColumns decodes the Coinbase-only product columns from the raw coinbase.Product
+// in details, in the order UpsertProducts lists them. They are all NULL for other exchanges.
+func coinbaseProductColumns(exchangeName string, details []byte) ([]interface{}, error) {
+	cols := make([]interface{}, coinbaseProductColumnCount)
+	if exchangeName != "coinbase" || len(details) == 0 {
+		return cols, nil
+	}
+	var p coinbase.Product
+	if err := json.Unmarshal(details, &p); err != nil {
+		return nil, fmt.Errorf("decode coinbase product details: %w", err)
+	}
+	priceChange, err := nullDecimal("price_percentage_change_24h", p.PricePercentageChange24h)
 	if err != nil {
-		return 0
+		return nil, err
+	}
+	volumeChange, err := nullDecimal("volume_percentage_change_24h", p.VolumePercentageChange24h)
+	if err != nil {
+		return nil, err
+	}
+	midMarket, err := nullDecimal("mid_market_price", p.MidMarketPrice)
+	if err != nil {
+		return nil, err
+	}
+	quoteVolume, err := nullDecimal("approximate_quote_24h_volume", p.ApproximateQuote24hVolume)
+	if err != nil {
+		return nil, err
 	}
-	return f
+	// Absent details stay NULL rather than the JSON literal null.
+	var fcm, future interface{}
+	if p.FcmTradingSessionDetails != nil {
+		if fcm, err = json.Marshal(p.FcmTradingSessionDetails); err != nil {
+			return nil, fmt.Errorf("encode fcm_trading_session_details: %w", err)
+		}
+	}
+	if p.FutureProductDetails != nil {
+		if future, err = json.Marshal(p.FutureProductDetails); err != nil {
+			return nil, fmt.Errorf("encode future_product_details: %w", err)
+		}
+	}
+	return []interface{}{
+		priceChange, volumeChange, p.Watched, p.New, p.CancelOnly,
+		p.LimitOnly, p.PostOnly, p.AuctionMode, p.ProductType, fcm,
+		midMarket, p.Alias, pq.Array(p.AliasTo), p.BaseDisplaySymbol, p.QuoteDisplaySymbol, p.ViewOnly,
+		p.DisplayName, p.ProductVenue, quoteVolume, future,
+	}, nil
+}
+
+// nullDecimal parses an optional numeric column. Empty input is NULL; malformed input is an
+// error rather than a silent zero.
+func nullDecimal(field, s string) (decimal.NullDecimal, error) {
+	if s == "" {
+		return decimal.NullDecimal{}, nil
+	}
+	d, err := exchange.ParseDecimal(field, s)
+	if err != nil {
+		return decimal.NullDecimal{}, err
+	}
+	return decimal.NullDecimal{Decimal: d, Valid: true}, nil
 }
 
-func (s *Store) InsertCandles(ctx context.Context, exchange, product string, candles []coinbase.Candle) (int, error) {
-	db, err := sql.Open("postgres", s.url)
+// InsertCandles stores new candles of granularitySec seconds, leaving existing rows untouched. The candles are streamed
+// into a temp table with COPY and merged in one INSERT ... SELECT, so a batch costs one round
+// trip per statement rather than one per candle.
+func (s *Store) InsertCandles(ctx context.Context, exchange, product string, granularitySec int, candles []exchange.Candle) (int, error) {
+	if len(candles) == 0 {
+		return 0, nil
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `
+		CREATE TEMP TABLE candles_in (
+			time TIMESTAMPTZ NOT NULL,
+			open NUMERIC NOT NULL,
+			high NUMERIC NOT NULL,
+			low NUMERIC NOT NULL,
+			close NUMERIC NOT NULL,
+			volume NUMERIC NOT NULL
+		) ON COMMIT DROP`); err != nil {
+		return 0, fmt.Errorf("create candle staging table: %w", err)
+	}
+	err = copyRows(ctx, tx, "candles_in", []string{"time", "open", "high", "low", "close", "volume"}, len(candles), func(i int) []interface{} {
+		c := candles[i]
+		return []interface{}{c.Time, c.Open, c.High, c.Low, c.Close, c.Volume}
+	})
 	if err != nil {
 		return 0, err
 	}
-	defer db.Close()
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO candles(exchange, product_id, granularity, time, open, high, low, close, volume)
+		SELECT $1, $2, $3, time, open, high, low, close, volume FROM candles_in
+		ON CONFLICT (exchange, product_id, granularity, time) DO NOTHING`, exchange, product, granularitySec)
+	if err != nil {
+		return 0, fmt.Errorf("insert candles: %w", err)
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("get rows affected: %w", err)
+	}
+	return int(rows), tx.Commit()
+}
 
-	// Handle the special case for a "fake" candle, used to mark gaps.
-	if len(candles) == 1 && candles[0].Volume == -1 {
-		_, err := db.ExecContext(ctx, `
-			INSERT INTO candles (exchange, product_id, time, open, high, low, close, volume, fake_fill_count)
-			VALUES ($1, $2, $3, 0, 0, 0, 0, -1, 1)
-			ON CONFLICT (exchange, product_id, time) DO UPDATE
-			SET fake_fill_count = candles.fake_fill_count + 1
-			WHERE candles.volume = -1
-		`, exchange, product, candles[0].Time)
-		return 0, err // Return 0 rows affected for fake candles
+// copyRows streams n rows into table with COPY inside tx. row returns the values of row i in
+// the order of columns.
+func copyRows(ctx context.Context, tx *sql.Tx, table string, columns []string, n int, row func(i int) []interface{}) error {
+	stmt, err := tx.PrepareContext(ctx, pq.CopyIn(table, columns...))
+	if err != nil {
+		return fmt.Errorf("copy into %s: %w", table, err)
 	}
+	for i := 0; i < n; i++ {
+		if _, err := stmt.ExecContext(ctx, row(i)...); err != nil {
+			stmt.Close()
+			return fmt.Errorf("copy into %s: %w", table, err)
+		}
+	}
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		stmt.Close()
+		return fmt.Errorf("copy into %s: %w", table, err)
+	}
+	return stmt.Close()
+}
 
-	tx, err := db.BeginTx(ctx, nil)
+// UpsertCandles inserts candles of granularitySec seconds or overwrites existing rows. It is used for
+// live candles, which are updated repeatedly until their bucket closes.
+func (s *Store) UpsertCandles(ctx context.Context, exchange, product string, granularitySec int, candles []exchange.Candle) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return 0, err
 	}
 	stmt, err := tx.PrepareContext(ctx, `
-		INSERT INTO candles(exchange, product_id, time, open, high, low, close, volume)
-		VALUES ($1,$2,$3,$4,$5,$6,$7,$8)
-		ON CONFLICT (exchange, product_id, time) DO NOTHING`)
+		INSERT INTO candles(exchange, product_id, granularity, time, open, high, low, close, volume)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9)
+		ON CONFLICT (exchange, product_id, granularity, time) DO UPDATE
+		SET open = EXCLUDED.open, high = EXCLUDED.high, low = EXCLUDED.low, close = EXCLUDED.close,
+			volume = EXCLUDED.volume, ingested_at = now()`)
 	if err != nil {
 		tx.Rollback()
 		return 0, err
@@ -331,10 +460,10 @@ func (s *Store) InsertCandles(ctx context.Context, exchange, product string, can
 
 	var rowsAffectedCount int64
 	for _, c := range candles {
-		res, err := stmt.ExecContext(ctx, exchange, product, c.Time, c.Open, c.High, c.Low, c.Close, c.Volume)
+		res, err := stmt.ExecContext(ctx, exchange, product, granularitySec, c.Time, c.Open, c.High, c.Low, c.Close, c.Volume)
 		if err != nil {
 			tx.Rollback()
-			return 0, fmt.Errorf("insert candle: %w", err)
+			return 0, fmt.Errorf("upsert candle: %w", err)
 		}
 		rows, err := res.RowsAffected()
 		if err != nil {
@@ -345,3 +474,384 @@ func (s *Store) InsertCandles(ctx context.Context, exchange, product string, can
 	}
 	return int(rowsAffectedCount), tx.Commit()
 }
+
+// UpsertWallets stores account balances in the wallets table, keyed by exchange and account UUID.
+// Soft-deleted accounts are kept and carry their deleted_at timestamp.
+func (s *Store) UpsertWallets(ctx context.Context, exchange string, accounts []coinbase.Account) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO wallets(
+			exchange, uuid, name, currency, available_balance, hold,
+			active, "default", ready, created_at, updated_at, deleted_at
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, COALESCE($10, now()), COALESCE($11, now()), $12)
+		ON CONFLICT (exchange, uuid) DO UPDATE SET
+			name = EXCLUDED.name,
+			currency = EXCLUDED.currency,
+			available_balance = EXCLUDED.available_balance,
+			hold = EXCLUDED.hold,
+			active = EXCLUDED.active,
+			"default" = EXCLUDED."default",
+			ready = EXCLUDED.ready,
+			created_at = EXCLUDED.created_at,
+			updated_at = EXCLUDED.updated_at,
+			deleted_at = EXCLUDED.deleted_at
+	`)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	defer stmt.Close()
+
+	var rowsAffectedCount int64
+	for _, a := range accounts {
+		res, err := stmt.ExecContext(ctx, exchange, a.UUID, a.Name, a.Currency,
+			a.AvailableBalance.Value, a.Hold.Value,
+			a.Active, a.Default, a.Ready,
+			parseTimestamp(a.CreatedAt), parseTimestamp(a.UpdatedAt), parseTimestamp(a.DeletedAt))
+		if err != nil {
+			tx.Rollback()
+			return 0, fmt.Errorf("upsert wallet %s: %w", a.UUID, err)
+		}
+		rows, err := res.RowsAffected()
+		if err != nil {
+			tx.Rollback()
+			return 0, fmt.Errorf("get rows affected for wallet %s: %w", a.UUID, err)
+		}
+		rowsAffectedCount += rows
+	}
+
+	return int(rowsAffectedCount), tx.Commit()
+}
+
+// parseTimestamp converts an RFC3339 API timestamp to a nullable time. Empty or invalid input maps to NULL.
+func parseTimestamp(s string) sql.NullTime {
+	if s == "" {
+		return sql.NullTime{}
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: t, Valid: true}
+}
+
+// WalletBalancePoint is the total balance of one currency at a snapshot time, summed across accounts.
+type WalletBalancePoint struct {
+	Currency  string          `json:"currency"`
+	TakenAt   time.Time       `json:"taken_at"`
+	Available decimal.Decimal `json:"available"`
+	Hold      decimal.Decimal `json:"hold"`
+}
+
+// walletSnapshot is one row of wallet_snapshots.
+type walletSnapshot struct {
+	UUID      string
+	Currency  string
+	Available decimal.Decimal
+	Hold      decimal.Decimal
+	TakenAt   time.Time
+	Deleted   bool
+}
+
+// walletSnapshotRows returns the wallet_snapshots rows for accounts at takenAt. A deleted
+// account gets a zero-balance row marked deleted instead of its last balance.
+func walletSnapshotRows(takenAt time.Time, accounts []coinbase.Account) []walletSnapshot {
+	out := make([]walletSnapshot, 0, len(accounts))
+	for _, a := range accounts {
+		r := walletSnapshot{UUID: a.UUID, Currency: a.Currency, TakenAt: takenAt, Deleted: a.DeletedAt != ""}
+		if !r.Deleted {
+			r.Available, r.Hold = a.AvailableBalance.Value, a.Hold.Value
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+// InsertWalletSnapshots records the balances of all accounts at takenAt. An account that is
+// deleted is recorded once, with a zero balance, the first time it is seen deleted.
+func (s *Store) InsertWalletSnapshots(ctx context.Context, exchange string, takenAt time.Time, accounts []coinbase.Account) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO wallet_snapshots(exchange, uuid, currency, available_balance, hold, taken_at, deleted)
+		SELECT $1::text, $2::text, $3::text, $4::numeric, $5::numeric, $6::timestamptz, $7::boolean
+		WHERE NOT $7::boolean OR NOT EXISTS (
+			SELECT 1 FROM wallet_snapshots WHERE exchange = $1 AND uuid = $2 AND deleted
+		)
+		ON CONFLICT (exchange, uuid, taken_at) DO NOTHING`)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	defer stmt.Close()
+
+	var rowsAffectedCount int64
+	for _, r := range walletSnapshotRows(takenAt, accounts) {
+		res, err := stmt.ExecContext(ctx, exchange, r.UUID, r.Currency, r.Available, r.Hold, r.TakenAt, r.Deleted)
+		if err != nil {
+			tx.Rollback()
+			return 0, fmt.Errorf("insert wallet snapshot %s: %w", r.UUID, err)
+		}
+		rows, err := res.RowsAffected()
+		if err != nil {
+			tx.Rollback()
+			return 0, fmt.Errorf("get rows affected for wallet snapshot %s: %w", r.UUID, err)
+		}
+		rowsAffectedCount += rows
+	}
+	return int(rowsAffectedCount), tx.Commit()
+}
+
+// GetWalletHistory returns per-currency balances for every snapshot in [since, until), oldest first.
+// An empty currency returns all currencies.
+func (s *Store) GetWalletHistory(ctx context.Context, exchange, currency string, since, until time.Time) ([]WalletBalancePoint, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT currency, taken_at, SUM(available_balance), SUM(hold)
+		FROM wallet_snapshots
+		WHERE exchange = $1 AND ($2 = '' OR currency = $2) AND taken_at >= $3 AND taken_at < $4
+		GROUP BY currency, taken_at
+		ORDER BY currency, taken_at
+	`, exchange, currency, since, until)
+	if err != nil {
+		return nil, fmt.Errorf("querying wallet history: %w", err)
+	}
+	defer rows.Close()
+
+	var points []WalletBalancePoint
+	for rows.Next() {
+		var p WalletBalancePoint
+		if err := rows.Scan(&p.Currency, &p.TakenAt, &p.Available, &p.Hold); err != nil {
+			return nil, fmt.Errorf("scanning wallet history: %w", err)
+		}
+		points = append(points, p)
+	}
+	return points, rows.Err()
+}
+
+// GetWalletBalances returns the current per-currency balances from the wallets table, excluding deleted accounts.
+func (s *Store) GetWalletBalances(ctx context.Context, exchange string) ([]WalletBalancePoint, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT currency, MAX(updated_at), SUM(available_balance), SUM(hold)
+		FROM wallets
+		WHERE exchange = $1 AND deleted_at IS NULL
+		GROUP BY currency
+		ORDER BY currency
+	`, exchange)
+	if err != nil {
+		return nil, fmt.Errorf("querying wallet balances: %w", err)
+	}
+	return scanBalancePoints(rows)
+}
+
+// GetWalletBalancesAt returns per-currency balances as of at, using each account's latest snapshot
+// taken at or before at. Accounts whose latest snapshot marks them deleted are left out.
+func (s *Store) GetWalletBalancesAt(ctx context.Context, exchange string, at time.Time) ([]WalletBalancePoint, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT DISTINCT ON (uuid) uuid, currency, available_balance, hold, taken_at, deleted
+		FROM wallet_snapshots
+		WHERE exchange = $1 AND taken_at <= $2
+		ORDER BY uuid, taken_at DESC
+	`, exchange, at)
+	if err != nil {
+		return nil, fmt.Errorf("querying wallet balances at %s: %w", at.Format(time.RFC3339), err)
+	}
+	defer rows.Close()
+
+	var latest []walletSnapshot
+	for rows.Next() {
+		var r walletSnapshot
+		if err := rows.Scan(&r.UUID, &r.Currency, &r.Available, &r.Hold, &r.TakenAt, &r.Deleted); err != nil {
+			return nil, fmt.Errorf("scanning wallet balance: %w", err)
+		}
+		latest = append(latest, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return sumWalletSnapshots(latest), nil
+}
+
+// sumWalletSnapshots totals the latest snapshot of each account per currency, skipping deleted
+// accounts. Points are ordered by currency and carry the newest snapshot time.
+func sumWalletSnapshots(latest []walletSnapshot) []WalletBalancePoint {
+	byCurrency := map[string]*WalletBalancePoint{}
+	var currencies []string
+	for _, r := range latest {
+		if r.Deleted {
+			continue
+		}
+		p, ok := byCurrency[r.Currency]
+		if !ok {
+			p = &WalletBalancePoint{Currency: r.Currency}
+			byCurrency[r.Currency] = p
+			currencies = append(currencies, r.Currency)
+		}
+		p.Available = p.Available.Add(r.Available)
+		p.Hold = p.Hold.Add(r.Hold)
+		if r.TakenAt.After(p.TakenAt) {
+			p.TakenAt = r.TakenAt
+		}
+	}
+	sort.Strings(currencies)
+	points := make([]WalletBalancePoint, 0, len(currencies))
+	for _, c := range currencies {
+		points = append(points, *byCurrency[c])
+	}
+	return points
+}
+
+func scanBalancePoints(rows *sql.Rows) ([]WalletBalancePoint, error) {
+	defer rows.Close()
+	var points []WalletBalancePoint
+	for rows.Next() {
+		var p WalletBalancePoint
+		if err := rows.Scan(&p.Currency, &p.TakenAt, &p.Available, &p.Hold); err != nil {
+			return nil, fmt.Errorf("scanning wallet balance: %w", err)
+		}
+		points = append(points, p)
+	}
+	return points, rows.Err()
+}
+
+// GetLatestClose returns the close of the most recent candle for product starting at or before at.
+// Of candles starting at the same time, the finest granularity wins since it closed most recently.
+// ok is false when no candle exists.
+func (s *Store) GetLatestClose(ctx context.Context, exchange, product string, at time.Time) (price decimal.Decimal, t time.Time, ok bool, err error) {
+	err = s.db.QueryRowContext(ctx, `
+		SELECT close, time
+		FROM candles
+		WHERE exchange = $1 AND product_id = $2 AND time <= $3
+		ORDER BY time DESC, granularity
+		LIMIT 1
+	`, exchange, product, at).Scan(&price, &t)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return decimal.Zero, time.Time{}, false, nil
+		}
+		return decimal.Zero, time.Time{}, false, fmt.Errorf("querying latest close for %s: %w", product, err)
+	}
+	return price, t, true, nil
+}
+
+// InsertFills stores executed trades. Fills are immutable, so already known entries are skipped.
+func (s *Store) InsertFills(ctx context.Context, exchange string, fills []coinbase.Fill) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO fills(
+			exchange, entry_id, trade_id, order_id, product_id, side, trade_time, trade_type,
+			price, size, size_in_quote, commission, liquidity_indicator, sequence_timestamp, retail_portfolio_id
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+		ON CONFLICT (exchange, entry_id) DO NOTHING`)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	defer stmt.Close()
+
+	var rowsAffectedCount int64
+	for _, f := range fills {
+		var seq sql.NullTime
+		if !f.SequenceTimestamp.IsZero() {
+			seq = sql.NullTime{Time: f.SequenceTimestamp, Valid: true}
+		}
+		res, err := stmt.ExecContext(ctx, exchange, f.EntryID, f.TradeID, f.OrderID, f.ProductID, f.Side,
+			f.TradeTime, f.TradeType, f.Price, f.Size, f.SizeInQuote,
+			f.Commission, f.LiquidityIndicator, seq, f.RetailPortfolioID)
+		if err != nil {
+			tx.Rollback()
+			return 0, fmt.Errorf("insert fill %s: %w", f.EntryID, err)
+		}
+		rows, err := res.RowsAffected()
+		if err != nil {
+			tx.Rollback()
+			return 0, fmt.Errorf("get rows affected for fill %s: %w", f.EntryID, err)
+		}
+		rowsAffectedCount += rows
+	}
+	return int(rowsAffectedCount), tx.Commit()
+}
+
+// GetLatestFillSequenceTime returns the sequence timestamp of the most recent stored fill, optionally
+// limited to products. This is the time the fills API filters on with start_sequence_timestamp. Fills
+// stored without one contribute their trade time instead. ok is false when no fills exist.
+func (s *Store) GetLatestFillSequenceTime(ctx context.Context, exchange string, products []string) (t time.Time, ok bool, err error) {
+	var latest sql.NullTime
+	err = s.db.QueryRowContext(ctx, `
+		SELECT MAX(COALESCE(sequence_timestamp, trade_time))
+		FROM fills
+		WHERE exchange = $1 AND (COALESCE(cardinality($2::text[]), 0) = 0 OR product_id = ANY($2))
+	`, exchange, pq.Array(products)).Scan(&latest)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("querying latest fill sequence time: %w", err)
+	}
+	return latest.Time, latest.Valid, nil
+}
+
+// StoredFill is an executed trade read back from the fills table.
+type StoredFill struct {
+	EntryID     string
+	OrderID     string
+	ProductID   string
+	Side        string
+	TradeTime   time.Time
+	Price       decimal.Decimal
+	Size        decimal.Decimal
+	SizeInQuote bool
+	Commission  decimal.Decimal
+}
+
+// GetFills returns all fills traded at or before until, oldest first.
+func (s *Store) GetFills(ctx context.Context, exchange string, until time.Time) ([]StoredFill, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT entry_id, order_id, product_id, side, trade_time, price, size, size_in_quote, commission
+		FROM fills
+		WHERE exchange = $1 AND trade_time <= $2
+		ORDER BY trade_time, entry_id
+	`, exchange, until)
+	if err != nil {
+		return nil, fmt.Errorf("querying fills: %w", err)
+	}
+	defer rows.Close()
+
+	var fills []StoredFill
+	for rows.Next() {
+		var f StoredFill
+		if err := rows.Scan(&f.EntryID, &f.OrderID, &f.ProductID, &f.Side, &f.TradeTime, &f.Price, &f.Size, &f.SizeInQuote, &f.Commission); err != nil {
+			return nil, fmt.Errorf("scanning fill: %w", err)
+		}
+		fills = append(fills, f)
+	}
+	return fills, rows.Err()
+}
+
+// InsertOrderBookSnapshot stores the top levels of an order book as JSON arrays of {price, size}.
+func (s *Store) InsertOrderBookSnapshot(ctx context.Context, exchange, product string, takenAt time.Time, sequence int64, bids, asks []orderbook.Level) error {
+	bidsJSON, err := json.Marshal(bids)
+	if err != nil {
+		return fmt.Errorf("encode bids: %w", err)
+	}
+	asksJSON, err := json.Marshal(asks)
+	if err != nil {
+		return fmt.Errorf("encode asks: %w", err)
+	}
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO order_book_snapshots (exchange, product_id, taken_at, sequence, bids, asks)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (exchange, product_id, taken_at) DO NOTHING
+	`, exchange, product, takenAt, sequence, bidsJSON, asksJSON)
+	if err != nil {
+		return fmt.Errorf("insert order book snapshot for %s: %w", product, err)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package ingest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"cryptool/internal/coinbase"
+)
+
+func account(uuid, currency, available, deletedAt string) coinbase.Account {
+	return coinbase.Account{
+		UUID:             uuid,
+		Currency:         currency,
+		AvailableBalance: coinbase.Balance{Value: decimal.RequireFromString(available), Currency: currency},
+		Hold:             coinbase.Balance{Value: decimal.RequireFromString("0.5"), Currency: currency},
+		DeletedAt:        deletedAt,
+	}
+}
+
+// latestAt mimics the DISTINCT ON (uuid) ... taken_at <= at query of GetWalletBalancesAt.
+func latestAt(rows []walletSnapshot, at time.Time) []walletSnapshot {
+	latest := map[string]walletSnapshot{}
+	for _, r := range rows {
+		if cur, ok := latest[r.UUID]; !r.TakenAt.After(at) && (!ok || r.TakenAt.After(cur.TakenAt)) {
+			latest[r.UUID] = r
+		}
+	}
+	out := make([]walletSnapshot, 0, len(latest))
+	for _, r := range latest {
+		out = append(out, r)
+	}
+	return out
+}
+
+func TestDeletedAccountDropsOutOfBalancesAt(t *testing.T) {
+	t1 := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	t2 := t1.Add(24 * time.Hour)
+
+	var table []walletSnapshot
+	table = append(table, walletSnapshotRows(t1, []coinbase.Account{
+		account("a", "BTC", "1.5", ""),
+		account("b", "BTC", "0.25", ""),
+		account("c", "ETH", "3", ""),
+	})...)
+	// Account a is closed and ETH account c deleted by the next syncdown.
+	table = append(table, walletSnapshotRows(t2, []coinbase.Account{
+		account("a", "BTC", "1.5", "2025-01-01T12:00:00Z"),
+		account("b", "BTC", "0.25", ""),
+		account("c", "ETH", "3", "2025-01-01T12:00:00Z"),
+	})...)
+
+	for _, r := range table {
+		if r.Deleted && (!r.Available.IsZero() || !r.Hold.IsZero()) {
+			t.Errorf("deleted snapshot of %s keeps balance %s/%s, want zero", r.UUID, r.Available, r.Hold)
+		}
+	}
+
+	before := sumWalletSnapshots(latestAt(table, t1))
+	if len(before) != 2 || !before[0].Available.Equal(decimal.RequireFromString("1.75")) || before[1].Currency != "ETH" {
+		t.Fatalf("balances at t1 = %+v, want 1.75 BTC and 3 ETH", before)
+	}
+	after := sumWalletSnapshots(latestAt(table, t2.Add(time.Hour)))
+	if len(after) != 1 || after[0].Currency != "BTC" || !after[0].Available.Equal(decimal.RequireFromString("0.25")) ||
+		!after[0].TakenAt.Equal(t2) {
+		t.Errorf("balances after deletion = %+v, want only the 0.25 BTC of account b", after)
+	}
+}
@@ -0,0 +1,297 @@
+// Package kraken implements the exchange adapter for Kraken's public OHLC and AssetPairs REST endpoints.
+package kraken
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cryptool/internal/config"
+	"cryptool/internal/exchange"
+
+	"github.com/shopspring/decimal"
+)
+
+// DefaultBaseURL is Kraken's public REST API.
+const DefaultBaseURL = "https://api.kraken.com"
+
+// MaxCandlesPerRequest is the most OHLC entries Kraken returns per call. Kraken only serves the
+// most recent 720 entries of each interval, so older ranges come back empty.
+const MaxCandlesPerRequest = 720
+
+func init() {
+	exchange.Register("kraken", func(cfg *config.Config) (exchange.Exchange, error) {
+		return NewClient(""), nil
+	})
+}
+
+// Client is a minimal Kraken public API client that implements exchange.MarketData.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	now        func() time.Time
+
+	mu    sync.Mutex
+	pairs map[string]string // product ID (BTC-USD) to Kraken pair name (XXBTZUSD)
+}
+
+// NewClient returns a client for baseURL; an empty baseURL uses DefaultBaseURL.
+func NewClient(baseURL string) *Client {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	return &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		now:        time.Now,
+	}
+}
+
+func (c *Client) Name() string { return "kraken" }
+
+func (c *Client) MaxCandlesPerRequest() int64 { return MaxCandlesPerRequest }
+
+// assetPair is one entry of the AssetPairs result.
+type assetPair struct {
+	Altname     string `json:"altname"`
+	Wsname      string `json:"wsname"`
+	Base        string `json:"base"`
+	Quote       string `json:"quote"`
+	LotDecimals int    `json:"lot_decimals"`
+	CostMin     string `json:"costmin"`
+	OrderMin    string `json:"ordermin"`
+	TickSize    string `json:"tick_size"`
+	Status      string `json:"status"`
+}
+
+// GetProducts lists all asset pairs. Kraken asset codes are normalized, so XXBT/ZUSD becomes BTC-USD.
+func (c *Client) GetProducts(ctx context.Context) ([]exchange.Product, error) {
+	var raw map[string]json.RawMessage
+	if err := c.get(ctx, "/0/public/AssetPairs", nil, &raw); err != nil {
+		return nil, err
+	}
+
+	products := make([]exchange.Product, 0, len(raw))
+	pairs := make(map[string]string, len(raw))
+	for name, data := range raw {
+		var p assetPair
+		if err := json.Unmarshal(data, &p); err != nil {
+			return nil, fmt.Errorf("decode asset pair %s: %w", name, err)
+		}
+		// Dark pool pairs (".d") have no wsname and no public OHLC data.
+		base, quote, ok := strings.Cut(p.Wsname, "/")
+		if !ok {
+			continue
+		}
+		base, quote = NormalizeAsset(base), NormalizeAsset(quote)
+		productID := base + "-" + quote
+		pairs[productID] = name
+
+		products = append(products, exchange.Product{
+			ProductID:       productID,
+			BaseCurrency:    base,
+			QuoteCurrency:   quote,
+			Status:          p.Status,
+			TradingDisabled: p.Status != "online",
+			BaseIncrement:   decimal.New(1, int32(-p.LotDecimals)).String(),
+			PriceIncrement:  p.TickSize,
+			BaseMinSize:     p.OrderMin,
+			QuoteMinSize:    p.CostMin,
+			Details:         data,
+		})
+	}
+
+	c.mu.Lock()
+	c.pairs = pairs
+	c.mu.Unlock()
+	return products, nil
+}
+
+// GetCandles returns the OHLC entries of product in [start, end]. Kraken's last entry is the
+// interval still in progress; it is dropped, as are any others that have not closed yet.
+func (c *Client) GetCandles(ctx context.Context, productID string, start, end time.Time, granularity string) ([]exchange.Candle, error) {
+	interval, ok := intervals[strings.ToLower(granularity)]
+	if !ok {
+		return nil, fmt.Errorf("kraken granularity %q: %w", granularity, exchange.ErrNotSupported)
+	}
+	pair, err := c.pair(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	q := url.Values{}
+	q.Set("pair", pair)
+	q.Set("interval", strconv.Itoa(interval))
+	// since is exclusive.
+	q.Set("since", strconv.FormatInt(start.UTC().Unix()-1, 10))
+
+	var raw map[string]json.RawMessage
+	if err := c.get(ctx, "/0/public/OHLC", q, &raw); err != nil {
+		return nil, err
+	}
+	data, ok := raw[pair]
+	if !ok {
+		return nil, fmt.Errorf("kraken OHLC response has no entries for %s", pair)
+	}
+	// Each entry is [time, open, high, low, close, vwap, volume, count].
+	var rows [][]json.RawMessage
+	if err := json.Unmarshal(data, &rows); err != nil {
+		return nil, fmt.Errorf("decode OHLC for %s: %w", pair, err)
+	}
+
+	open := c.now().UTC().Truncate(time.Duration(interval) * time.Minute)
+	candles := make([]exchange.Candle, 0, len(rows))
+	for _, r := range rows {
+		if len(r) < 7 {
+			return nil, fmt.Errorf("short OHLC entry for %s: %d fields", pair, len(r))
+		}
+		var ts int64
+		if err := json.Unmarshal(r[0], &ts); err != nil {
+			return nil, fmt.Errorf("decode OHLC time for %s: %w", pair, err)
+		}
+		t := time.Unix(ts, 0).UTC()
+		if t.Before(start) || t.After(end) || !t.Before(open) {
+			continue
+		}
+		c := exchange.Candle{Time: t}
+		for i, dst := range []*decimal.Decimal{&c.Open, &c.High, &c.Low, &c.Close, &c.Volume} {
+			d, err := jsonDecimal(r[ohlcvFields[i]])
+			if err != nil {
+				return nil, fmt.Errorf("decode OHLC entry for %s at %s: %w", pair, c.Time.Format(time.RFC3339), err)
+			}
+			*dst = d
+		}
+		candles = append(candles, c)
+	}
+	return candles, nil
+}
+
+// HistoryStart implements exchange.HistoryStarter. Kraken reports no listing dates and only
+// serves the most recent MaxCandlesPerRequest entries, so history starts that many buckets ago.
+func (c *Client) HistoryStart(ctx context.Context, productID, granularity string) (time.Time, error) {
+	interval, ok := intervals[strings.ToLower(granularity)]
+	if !ok {
+		return time.Time{}, fmt.Errorf("kraken granularity %q: %w", granularity, exchange.ErrNotSupported)
+	}
+	if _, err := c.pair(ctx, productID); err != nil {
+		return time.Time{}, err
+	}
+	bucket := time.Duration(interval) * time.Minute
+	return time.Now().UTC().Truncate(bucket).Add(-(MaxCandlesPerRequest - 1) * bucket), nil
+}
+
+// intervals maps granularities to Kraken OHLC intervals in minutes.
+var intervals = map[string]int{
+	"1m":  1,
+	"5m":  5,
+	"15m": 15,
+	"30m": 30,
+	"1h":  60,
+	"4h":  240,
+	"1d":  1440,
+}
+
+// SupportsGranularity reports whether Kraken serves OHLC data at granularity. It has no 2h or 6h interval.
+func (c *Client) SupportsGranularity(granularity string) bool {
+	_, ok := intervals[strings.ToLower(granularity)]
+	return ok
+}
+
+// pair resolves a product ID to Kraken's pair name, loading AssetPairs on first use.
+func (c *Client) pair(ctx context.Context, productID string) (string, error) {
+	c.mu.Lock()
+	loaded := c.pairs != nil
+	name, ok := c.pairs[productID]
+	c.mu.Unlock()
+	if ok {
+		return name, nil
+	}
+	if !loaded {
+		if _, err := c.GetProducts(ctx); err != nil {
+			return "", fmt.Errorf("load asset pairs: %w", err)
+		}
+		c.mu.Lock()
+		name, ok = c.pairs[productID]
+		c.mu.Unlock()
+		if ok {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("unknown kraken product %s", productID)
+}
+
+// assetAliases maps Kraken's legacy asset codes to common tickers.
+var assetAliases = map[string]string{
+	"XBT": "BTC",
+	"XDG": "DOGE",
+}
+
+// NormalizeAsset maps a Kraken asset code such as XBT or XDG to its common ticker.
+func NormalizeAsset(code string) string {
+	code = strings.ToUpper(code)
+	if alias, ok := assetAliases[code]; ok {
+		return alias
+	}
+	return code
+}
+
+// response is Kraken's common envelope.
+type response struct {
+	Error  []string        `json:"error"`
+	Result json.RawMessage `json:"result"`
+}
+
+// get performs a public GET request and decodes the result field into out.
+func (c *Client) get(ctx context.Context, path string, query url.Values, out interface{}) error {
+	u := c.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("read %s: %w", path, err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("kraken http %d: %s", resp.StatusCode, string(body))
+	}
+	var env response
+	if err := json.Unmarshal(body, &env); err != nil {
+		return fmt.Errorf("decode %s: %w", path, err)
+	}
+	if len(env.Error) > 0 {
+		return fmt.Errorf("kraken %s: %s", path, strings.Join(env.Error, "; "))
+	}
+	if err := json.Unmarshal(env.Result, out); err != nil {
+		return fmt.Errorf("decode %s result: %w", path, err)
+	}
+	return nil
+}
+
+// ohlcvFields are the indexes of open, high, low, close and volume in a OHLC entry.
+var ohlcvFields = [5]int{1, 2, 3, 4, 6}
+
+// jsonDecimal parses a JSON string or number as a decimal.
+func jsonDecimal(raw json.RawMessage) (decimal.Decimal, error) {
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		s = string(raw)
+	}
+	return decimal.NewFromString(s)
+}
@@ -0,0 +1,155 @@
+package kraken
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"cryptool/internal/exchange"
+)
+
+// fixtureServer serves testdata files by path and records the query of every OHLC request.
+func fixtureServer(t *testing.T, routes map[string]string) (*httptest.Server, *[]url.Values) {
+	t.Helper()
+	var queries []url.Values
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name, ok := routes[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if strings.HasSuffix(r.URL.Path, "/OHLC") {
+			queries = append(queries, r.URL.Query())
+		}
+		data, err := os.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			t.Errorf("read fixture %s: %v", name, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &queries
+}
+
+func TestGetProductsNormalizesAssets(t *testing.T) {
+	srv, _ := fixtureServer(t, map[string]string{"/0/public/AssetPairs": "asset_pairs.json"})
+	products, err := NewClient(srv.URL).GetProducts(context.Background())
+	if err != nil {
+		t.Fatalf("GetProducts: %v", err)
+	}
+
+	byID := map[string]exchange.Product{}
+	for _, p := range products {
+		byID[p.ProductID] = p
+	}
+	if len(byID) != 3 {
+		t.Fatalf("got products %v, want BTC-USD, ETH-EUR and DOGE-USD (dark pool pair skipped)", byID)
+	}
+	btc, ok := byID["BTC-USD"]
+	if !ok {
+		t.Fatal("XBT/USD was not normalized to BTC-USD")
+	}
+	if btc.BaseCurrency != "BTC" || btc.QuoteCurrency != "USD" || btc.BaseMinSize != "0.0001" ||
+		btc.PriceIncrement != "0.1" || btc.BaseIncrement != "0.00000001" || btc.TradingDisabled {
+		t.Errorf("unexpected BTC-USD product: %+v", btc)
+	}
+	if !strings.Contains(string(btc.Details), `"XBTUSD"`) {
+		t.Errorf("details should hold the raw pair payload, got %s", btc.Details)
+	}
+	if doge := byID["DOGE-USD"]; !doge.TradingDisabled || doge.Status != "cancel_only" {
+		t.Errorf("DOGE-USD should be trading disabled: %+v", doge)
+	}
+}
+
+func TestGetCandles(t *testing.T) {
+	srv, queries := fixtureServer(t, map[string]string{
+		"/0/public/AssetPairs": "asset_pairs.json",
+		"/0/public/OHLC":       "ohlc_xxbtzusd_60.json",
+	})
+	c := NewClient(srv.URL)
+
+	start := time.Unix(1704070800, 0).UTC()
+	end := time.Unix(1704074400, 0).UTC()
+	candles, err := c.GetCandles(context.Background(), "BTC-USD", start, end, "1h")
+	if err != nil {
+		t.Fatalf("GetCandles: %v", err)
+	}
+	// The fixture holds four hours; only the two inside [start, end] are returned.
+	if len(candles) != 2 {
+		t.Fatalf("got %d candles, want 2", len(candles))
+	}
+	first := candles[0]
+	if !first.Time.Equal(start) || first.Open.String() != "42475.2" || first.High.String() != "42769" || first.Low.String() != "42420.5" ||
+		first.Close.String() != "42656.3" || first.Volume.String() != "101.35261829" {
+		t.Errorf("unexpected first candle: %+v", first)
+	}
+
+	if len(*queries) != 1 {
+		t.Fatalf("OHLC requests = %d, want 1", len(*queries))
+	}
+	q := (*queries)[0]
+	if q.Get("pair") != "XXBTZUSD" || q.Get("interval") != "60" || q.Get("since") != "1704070799" {
+		t.Errorf("unexpected OHLC query: %v", q)
+	}
+
+	// Halfway through the fixture's last hour, that entry is still open and is dropped.
+	c.now = func() time.Time { return time.Unix(1704078000+1800, 0) }
+	candles, err = c.GetCandles(context.Background(), "BTC-USD", start, time.Unix(1704081600, 0), "1h")
+	if err != nil {
+		t.Fatalf("GetCandles: %v", err)
+	}
+	if len(candles) != 2 || !candles[1].Time.Equal(end) {
+		t.Errorf("got %+v, want the two closed hours up to %v", candles, end)
+	}
+}
+
+func TestGetCandlesErrors(t *testing.T) {
+	srv, _ := fixtureServer(t, map[string]string{
+		"/0/public/AssetPairs": "asset_pairs.json",
+		"/0/public/OHLC":       "error_unknown_pair.json",
+	})
+	c := NewClient(srv.URL)
+	ctx := context.Background()
+	now := time.Now()
+
+	if _, err := c.GetCandles(ctx, "BTC-USD", now.Add(-time.Hour), now, "2h"); !errors.Is(err, exchange.ErrNotSupported) {
+		t.Errorf("2h granularity: err = %v, want ErrNotSupported", err)
+	}
+	if _, err := c.GetCandles(ctx, "FOO-BAR", now.Add(-time.Hour), now, "1h"); err == nil || !strings.Contains(err.Error(), "unknown kraken product") {
+		t.Errorf("unknown product: err = %v", err)
+	}
+	if _, err := c.GetCandles(ctx, "BTC-USD", now.Add(-time.Hour), now, "1h"); err == nil || !strings.Contains(err.Error(), "EQuery:Unknown asset pair") {
+		t.Errorf("API error: err = %v", err)
+	}
+}
+
+func TestHistoryStart(t *testing.T) {
+	srv, _ := fixtureServer(t, map[string]string{"/0/public/AssetPairs": "asset_pairs.json"})
+	c := NewClient(srv.URL)
+	ctx := context.Background()
+
+	start, err := c.HistoryStart(ctx, "BTC-USD", "1h")
+	if err != nil {
+		t.Fatalf("HistoryStart: %v", err)
+	}
+	want := time.Now().UTC().Truncate(time.Hour).Add(-(MaxCandlesPerRequest - 1) * time.Hour)
+	if d := want.Sub(start); d < 0 || d > time.Hour {
+		t.Errorf("start = %s, want %d hours ago (%s)", start, MaxCandlesPerRequest-1, want)
+	}
+	if _, err := c.HistoryStart(ctx, "BTC-USD", "2h"); !errors.Is(err, exchange.ErrNotSupported) {
+		t.Errorf("2h granularity: err = %v, want ErrNotSupported", err)
+	}
+	if _, err := c.HistoryStart(ctx, "FOO-BAR", "1h"); err == nil {
+		t.Error("unknown product: want an error")
+	}
+}
@@ -5,72 +5,75 @@ import (
 	"database/sql"
 	"embed"
 	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
 
 	_ "github.com/lib/pq"
 	"github.com/pressly/goose/v3"
 )
 
+// mu serializes runs: goose's logger and base FS are process-wide, and two migrations
+// against one database would race anyway.
+var mu sync.Mutex
+
 func open(url string) (*sql.DB, error) {
 	return sql.Open("postgres", url)
 }
 
-func Status(ctx context.Context, url string, migrationsFS embed.FS) error {
+// run opens the database and calls fn with goose writing its progress to out. The goose
+// *Context calls in fn stop when their ctx is cancelled.
+func run(out io.Writer, url string, migrationsFS embed.FS, fn func(db *sql.DB) error) error {
 	db, err := open(url)
 	if err != nil {
 		return err
 	}
 	defer db.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
 	goose.SetBaseFS(migrationsFS)
+	goose.SetLogger(log.New(out, "", log.LstdFlags))
+	defer goose.SetLogger(log.New(os.Stderr, "", log.LstdFlags))
 	if err := goose.SetDialect("postgres"); err != nil {
 		return err
 	}
-	fmt.Println("Migration status:")
-	return goose.Status(db, "migrations")
+	return fn(db)
 }
 
-func Up(ctx context.Context, url string, migrationsFS embed.FS) error {
-	db, err := open(url)
-	if err != nil {
-		return err
-	}
-	defer db.Close()
-	goose.SetBaseFS(migrationsFS)
-	if err := goose.Up(db, "migrations"); err != nil {
-		return err
-	}
-	return nil
+func Status(ctx context.Context, out io.Writer, url string, migrationsFS embed.FS) error {
+	return run(out, url, migrationsFS, func(db *sql.DB) error {
+		fmt.Fprintln(out, "Migration status:")
+		return goose.StatusContext(ctx, db, "migrations")
+	})
 }
 
-func Down(ctx context.Context, url string, steps int, migrationsFS embed.FS) error {
-	db, err := open(url)
-	if err != nil {
-		return err
-	}
-	defer db.Close()
-	goose.SetBaseFS(migrationsFS)
+func Up(ctx context.Context, out io.Writer, url string, migrationsFS embed.FS) error {
+	return run(out, url, migrationsFS, func(db *sql.DB) error {
+		return goose.UpContext(ctx, db, "migrations")
+	})
+}
+
+func Down(ctx context.Context, out io.Writer, url string, steps int, migrationsFS embed.FS) error {
 	if steps <= 0 {
 		steps = 1
 	}
-	for i := 0; i < steps; i++ {
-		if err := goose.Down(db, "migrations"); err != nil {
-			return err
+	return run(out, url, migrationsFS, func(db *sql.DB) error {
+		for i := 0; i < steps; i++ {
+			if err := goose.DownContext(ctx, db, "migrations"); err != nil {
+				return err
+			}
 		}
-	}
-	return nil
+		return nil
+	})
 }
 
-func Reset(ctx context.Context, url string, migrationsFS embed.FS) error {
-	db, err := open(url)
-	if err != nil {
-		return err
-	}
-	defer db.Close()
-	goose.SetBaseFS(migrationsFS)
-	if err := goose.Reset(db, "migrations"); err != nil {
-		return err
-	}
-	if err := goose.Up(db, "migrations"); err != nil {
-		return err
-	}
-	return nil
+func Reset(ctx context.Context, out io.Writer, url string, migrationsFS embed.FS) error {
+	return run(out, url, migrationsFS, func(db *sql.DB) error {
+		if err := goose.ResetContext(ctx, db, "migrations"); err != nil {
+			return err
+		}
+		return goose.UpContext(ctx, db, "migrations")
+	})
 }
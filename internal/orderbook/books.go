@@ -0,0 +1,61 @@
+package orderbook
+
+import (
+	"errors"
+	"sync"
+
+	"cryptool/internal/coinbase/stream"
+)
+
+// Books keeps one OrderBook per product and routes level2 updates to them.
+type Books struct {
+	mu    sync.Mutex
+	books map[string]*OrderBook
+}
+
+// NewBooks returns an empty set of books.
+func NewBooks() *Books {
+	return &Books{books: make(map[string]*OrderBook)}
+}
+
+// Book returns the book for productID, creating an unsynced one when needed.
+func (m *Books) Book(productID string) *OrderBook {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b, ok := m.books[productID]
+	if !ok {
+		b = New(productID)
+		m.books[productID] = b
+	}
+	return b
+}
+
+// Apply routes u to its product's book. Deltas that arrive before a snapshot are dropped.
+func (m *Books) Apply(u stream.Level2Update) error {
+	err := m.Book(u.ProductID).Apply(u)
+	if errors.Is(err, ErrNotSynced) {
+		return nil
+	}
+	return err
+}
+
+// HandleEvent invalidates every book when the stream reports a sequence gap or drops the
+// connection. The stream then resubscribes, and the fresh snapshots resync the books.
+func (m *Books) HandleEvent(e stream.Event) {
+	if e.Kind != stream.EventGap && e.Kind != stream.EventDisconnected {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, b := range m.books {
+		b.Invalidate()
+	}
+}
+
+// Run applies updates from a subscriber's level2 channel until it is closed.
+// Out-of-sequence updates invalidate the affected book until its next snapshot.
+func (m *Books) Run(updates <-chan stream.Level2Update) {
+	for u := range updates {
+		m.Apply(u)
+	}
+}
@@ -0,0 +1,240 @@
+// Package orderbook maintains in-memory level2 order books from stream snapshots and deltas.
+package orderbook
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"cryptool/internal/coinbase"
+	"cryptool/internal/coinbase/stream"
+)
+
+var (
+	// ErrNotSynced is returned while a book has no snapshot, or after a gap until the next snapshot.
+	ErrNotSynced = errors.New("order book not synced")
+	// ErrOutOfSequence is returned for an update whose sequence number is not after the last applied one.
+	ErrOutOfSequence = errors.New("order book update out of sequence")
+	// ErrEmptyBook is returned when the requested side has no levels.
+	ErrEmptyBook = errors.New("order book side is empty")
+)
+
+// Level is the total size resting at one price.
+type Level struct {
+	Price decimal.Decimal `json:"price"`
+	Size  decimal.Decimal `json:"size"`
+}
+
+// OrderBook is the level2 book of one product. It is safe for concurrent use.
+type OrderBook struct {
+	ProductID string
+
+	mu       sync.RWMutex
+	bids     []Level // best (highest) first
+	asks     []Level // best (lowest) first
+	synced   bool
+	sequence int64
+	updated  time.Time
+}
+
+// New returns an empty, unsynced book for productID.
+func New(productID string) *OrderBook {
+	return &OrderBook{ProductID: productID}
+}
+
+// Apply applies a snapshot or delta. Snapshots replace the book and mark it synced.
+// Deltas are rejected with ErrNotSynced until a snapshot arrives; a delta that is not
+// newer than the last applied update invalidates the book and returns ErrOutOfSequence.
+func (b *OrderBook) Apply(u stream.Level2Update) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if u.Snapshot {
+		b.loadSnapshot(u.Changes)
+		b.synced = true
+		b.sequence = u.Sequence
+		b.updated = u.Time
+		return nil
+	}
+	if !b.synced {
+		return ErrNotSynced
+	}
+	if u.Sequence <= b.sequence {
+		b.synced = false
+		return fmt.Errorf("%w: %s got %d after %d", ErrOutOfSequence, b.ProductID, u.Sequence, b.sequence)
+	}
+
+	for _, c := range u.Changes {
+		switch c.Side {
+		case stream.SideBid:
+			b.bids = setLevel(b.bids, c.Price, c.Quantity, func(a, p decimal.Decimal) bool { return a.GreaterThan(p) })
+		case stream.SideOffer:
+			b.asks = setLevel(b.asks, c.Price, c.Quantity, func(a, p decimal.Decimal) bool { return a.LessThan(p) })
+		}
+	}
+	b.sequence = u.Sequence
+	b.updated = u.Time
+	return nil
+}
+
+// loadSnapshot replaces both sides. Snapshots can hold thousands of levels, so they are
+// collected and sorted once instead of inserted one by one.
+func (b *OrderBook) loadSnapshot(changes []stream.PriceLevel) {
+	b.bids, b.asks = b.bids[:0], b.asks[:0]
+	for _, c := range changes {
+		if !c.Quantity.IsPositive() {
+			continue
+		}
+		switch c.Side {
+		case stream.SideBid:
+			b.bids = append(b.bids, Level{Price: c.Price, Size: c.Quantity})
+		case stream.SideOffer:
+			b.asks = append(b.asks, Level{Price: c.Price, Size: c.Quantity})
+		}
+	}
+	sort.Slice(b.bids, func(i, j int) bool { return b.bids[i].Price.GreaterThan(b.bids[j].Price) })
+	sort.Slice(b.asks, func(i, j int) bool { return b.asks[i].Price.LessThan(b.asks[j].Price) })
+}
+
+// setLevel sets price to size in levels, which are ordered by better. A zero size removes the level.
+func setLevel(levels []Level, price, size decimal.Decimal, better func(a, p decimal.Decimal) bool) []Level {
+	i := sort.Search(len(levels), func(i int) bool { return !better(levels[i].Price, price) })
+	found := i < len(levels) && levels[i].Price.Equal(price)
+	switch {
+	case !size.IsPositive() && found:
+		return append(levels[:i], levels[i+1:]...)
+	case !size.IsPositive():
+		return levels
+	case found:
+		levels[i].Size = size
+		return levels
+	default:
+		levels = append(levels, Level{})
+		copy(levels[i+1:], levels[i:])
+		levels[i] = Level{Price: price, Size: size}
+		return levels
+	}
+}
+
+// Invalidate marks the book unsynced, e.g. after the stream reported a sequence gap.
+// Queries fail with ErrNotSynced until the next snapshot is applied.
+func (b *OrderBook) Invalidate() {
+	b.mu.Lock()
+	b.synced = false
+	b.mu.Unlock()
+}
+
+// Synced reports whether the book reflects a snapshot and all deltas since.
+func (b *OrderBook) Synced() bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.synced
+}
+
+// Sequence returns the sequence number and time of the last applied update.
+func (b *OrderBook) Sequence() (int64, time.Time) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.sequence, b.updated
+}
+
+// BestBid returns the highest bid.
+func (b *OrderBook) BestBid() (Level, error) {
+	return b.best(func() []Level { return b.bids })
+}
+
+// BestAsk returns the lowest ask.
+func (b *OrderBook) BestAsk() (Level, error) {
+	return b.best(func() []Level { return b.asks })
+}
+
+func (b *OrderBook) best(side func() []Level) (Level, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if !b.synced {
+		return Level{}, ErrNotSynced
+	}
+	levels := side()
+	if len(levels) == 0 {
+		return Level{}, ErrEmptyBook
+	}
+	return levels[0], nil
+}
+
+// Depth returns copies of the best n levels of each side. n <= 0 returns the whole book.
+func (b *OrderBook) Depth(n int) (bids, asks []Level, err error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if !b.synced {
+		return nil, nil, ErrNotSynced
+	}
+	top := func(levels []Level) []Level {
+		if n > 0 && n < len(levels) {
+			levels = levels[:n]
+		}
+		return append([]Level(nil), levels...)
+	}
+	return top(b.bids), top(b.asks), nil
+}
+
+// Impact describes filling a market order of a given size against the book.
+type Impact struct {
+	Side         string          `json:"side"`
+	Size         decimal.Decimal `json:"size"`          // requested base size
+	Filled       decimal.Decimal `json:"filled"`        // base size the book can fill
+	Cost         decimal.Decimal `json:"cost"`          // quote amount paid (buy) or received (sell)
+	BestPrice    decimal.Decimal `json:"best_price"`    // top of book on the consumed side
+	AveragePrice decimal.Decimal `json:"average_price"` // Cost / Filled
+	WorstPrice   decimal.Decimal `json:"worst_price"`   // last level touched
+	Slippage     decimal.Decimal `json:"slippage"`      // relative difference between average and best price
+	Levels       int             `json:"levels"`        // number of levels consumed
+}
+
+// PriceImpact walks the book to estimate filling a market order of size (in base units).
+// Buys consume asks and sells consume bids. When the book is too thin, Filled is less than Size.
+func (b *OrderBook) PriceImpact(side string, size decimal.Decimal) (Impact, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if !b.synced {
+		return Impact{}, ErrNotSynced
+	}
+
+	var levels []Level
+	switch side {
+	case coinbase.SideBuy:
+		levels = b.asks
+	case coinbase.SideSell:
+		levels = b.bids
+	default:
+		return Impact{}, fmt.Errorf("unknown side %q", side)
+	}
+	if len(levels) == 0 {
+		return Impact{}, ErrEmptyBook
+	}
+
+	imp := Impact{Side: side, Size: size, BestPrice: levels[0].Price}
+	remaining := size
+	for _, l := range levels {
+		if !remaining.IsPositive() {
+			break
+		}
+		take := decimal.Min(l.Size, remaining)
+		imp.Filled = imp.Filled.Add(take)
+		imp.Cost = imp.Cost.Add(take.Mul(l.Price))
+		imp.WorstPrice = l.Price
+		imp.Levels++
+		remaining = remaining.Sub(take)
+	}
+	if imp.Filled.IsPositive() {
+		imp.AveragePrice = imp.Cost.Div(imp.Filled)
+		imp.Slippage = imp.AveragePrice.Sub(imp.BestPrice).Div(imp.BestPrice)
+		if side == coinbase.SideSell {
+			imp.Slippage = imp.Slippage.Neg()
+		}
+	}
+	return imp, nil
+}
@@ -0,0 +1,146 @@
+package orderbook
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/shopspring/decimal"
+
+	"cryptool/internal/coinbase"
+	"cryptool/internal/coinbase/stream"
+)
+
+func dec(s string) decimal.Decimal { return decimal.RequireFromString(s) }
+
+func bid(price, qty float64) stream.PriceLevel {
+	return stream.PriceLevel{Side: stream.SideBid, Price: decimal.NewFromFloat(price), Quantity: decimal.NewFromFloat(qty)}
+}
+
+func ask(price, qty float64) stream.PriceLevel {
+	return stream.PriceLevel{Side: stream.SideOffer, Price: decimal.NewFromFloat(price), Quantity: decimal.NewFromFloat(qty)}
+}
+
+func level(price, size float64) Level {
+	return Level{Price: decimal.NewFromFloat(price), Size: decimal.NewFromFloat(size)}
+}
+
+// equal reports whether two levels hold the same price and size.
+func equal(a, b Level) bool { return a.Price.Equal(b.Price) && a.Size.Equal(b.Size) }
+
+func snapshot(seq int64, levels ...stream.PriceLevel) stream.Level2Update {
+	return stream.Level2Update{ProductID: "BTC-USD", Snapshot: true, Sequence: seq, Changes: levels}
+}
+
+func delta(seq int64, levels ...stream.PriceLevel) stream.Level2Update {
+	return stream.Level2Update{ProductID: "BTC-USD", Sequence: seq, Changes: levels}
+}
+
+func synced(t *testing.T) *OrderBook {
+	t.Helper()
+	b := New("BTC-USD")
+	err := b.Apply(snapshot(1,
+		bid(99, 1), bid(100, 2), bid(98, 3),
+		ask(102, 2), ask(101, 1), ask(103, 5),
+	))
+	if err != nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+	return b
+}
+
+func TestDeltaBeforeSnapshot(t *testing.T) {
+	b := New("BTC-USD")
+	if err := b.Apply(delta(1, bid(100, 1))); !errors.Is(err, ErrNotSynced) {
+		t.Fatalf("Apply = %v, want ErrNotSynced", err)
+	}
+	if _, err := b.BestBid(); !errors.Is(err, ErrNotSynced) {
+		t.Errorf("BestBid = %v, want ErrNotSynced", err)
+	}
+}
+
+func TestSnapshotAndDeltas(t *testing.T) {
+	b := synced(t)
+
+	if l, _ := b.BestBid(); !equal(l, level(100, 2)) {
+		t.Errorf("BestBid = %+v, want 100x2", l)
+	}
+	if l, _ := b.BestAsk(); !equal(l, level(101, 1)) {
+		t.Errorf("BestAsk = %+v, want 101x1", l)
+	}
+
+	// Remove the best bid, add a better ask, and resize an existing ask.
+	if err := b.Apply(delta(2, bid(100, 0), ask(100.5, 4), ask(102, 7))); err != nil {
+		t.Fatalf("delta: %v", err)
+	}
+	bids, asks, err := b.Depth(2)
+	if err != nil {
+		t.Fatalf("Depth: %v", err)
+	}
+	wantBids := []Level{level(99, 1), level(98, 3)}
+	wantAsks := []Level{level(100.5, 4), level(101, 1)}
+	for i := range wantBids {
+		if !equal(bids[i], wantBids[i]) || !equal(asks[i], wantAsks[i]) {
+			t.Fatalf("Depth(2) = %v / %v, want %v / %v", bids, asks, wantBids, wantAsks)
+		}
+	}
+	if _, asks, _ := b.Depth(0); len(asks) != 4 || !equal(asks[2], level(102, 7)) {
+		t.Errorf("full ask side = %v", asks)
+	}
+}
+
+func TestOutOfSequenceInvalidates(t *testing.T) {
+	b := synced(t)
+	if err := b.Apply(delta(1, bid(100, 5))); !errors.Is(err, ErrOutOfSequence) {
+		t.Fatalf("Apply = %v, want ErrOutOfSequence", err)
+	}
+	if b.Synced() {
+		t.Fatal("book should be unsynced after an out-of-sequence update")
+	}
+	if err := b.Apply(snapshot(10, bid(100, 1), ask(101, 1))); err != nil || !b.Synced() {
+		t.Fatalf("resync failed: %v", err)
+	}
+}
+
+func TestPriceImpact(t *testing.T) {
+	b := synced(t)
+
+	imp, err := b.PriceImpact(coinbase.SideBuy, dec("2"))
+	if err != nil {
+		t.Fatalf("PriceImpact: %v", err)
+	}
+	// 1 @ 101 + 1 @ 102 = 203, average 101.5.
+	if !imp.Filled.Equal(dec("2")) || !imp.Cost.Equal(dec("203")) || !imp.AveragePrice.Equal(dec("101.5")) ||
+		!imp.WorstPrice.Equal(dec("102")) || imp.Levels != 2 {
+		t.Errorf("buy impact = %+v", imp)
+	}
+	if want := dec("0.5").Div(dec("101")); !imp.Slippage.Equal(want) {
+		t.Errorf("buy slippage = %s, want %s", imp.Slippage, want)
+	}
+
+	// Sizes below float64 precision add up exactly.
+	imp, _ = b.PriceImpact(coinbase.SideBuy, dec("1.00000000000000001"))
+	if !imp.Filled.Equal(dec("1.00000000000000001")) || !imp.Cost.Equal(dec("101.00000000000000102")) {
+		t.Errorf("tiny size impact = %+v", imp)
+	}
+
+	imp, _ = b.PriceImpact(coinbase.SideSell, dec("10"))
+	// The bid side only holds 6.
+	if !imp.Filled.Equal(dec("6")) || !imp.Cost.Equal(dec("593")) || !imp.Slippage.IsPositive() {
+		t.Errorf("sell impact = %+v", imp)
+	}
+}
+
+func TestBooksInvalidateOnGap(t *testing.T) {
+	m := NewBooks()
+	if err := m.Apply(delta(1, bid(1, 1))); err != nil {
+		t.Fatalf("deltas before a snapshot should be dropped, got %v", err)
+	}
+	m.Apply(snapshot(2, bid(100, 1), ask(101, 1)))
+	if !m.Book("BTC-USD").Synced() {
+		t.Fatal("book should be synced after snapshot")
+	}
+	m.HandleEvent(stream.Event{Kind: stream.EventGap, Expected: 3, Got: 5})
+	if m.Book("BTC-USD").Synced() {
+		t.Fatal("book should be unsynced after a gap")
+	}
+}
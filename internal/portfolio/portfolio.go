@@ -0,0 +1,109 @@
+// Package portfolio values currency holdings in a quote currency from stored prices.
+package portfolio
+
+import (
+	"fmt"
+
+	"github.com/shopspring/decimal"
+)
+
+// Holding is an amount of a single currency.
+type Holding struct {
+	Currency string
+	Amount   decimal.Decimal
+}
+
+// PriceFunc returns the price of product (e.g. BTC-USD) in its quote currency.
+// ok is false when no price is known for the product.
+type PriceFunc func(product string) (price decimal.Decimal, ok bool, err error)
+
+// Valuation is the value of one holding in the quote currency.
+type Valuation struct {
+	Currency string          `json:"currency"`
+	Amount   decimal.Decimal `json:"amount"`
+	Price    decimal.Decimal `json:"price"`
+	Value    decimal.Decimal `json:"value"`
+	Route    []string        `json:"route,omitempty"` // products used to price the holding
+	Priced   bool            `json:"priced"`
+}
+
+// Value prices every holding in quote. Each currency is priced through, in order:
+// the direct pair <CUR>-<QUOTE>, the inverse pair <QUOTE>-<CUR>, and finally a two-leg route
+// <CUR>-<VIA> then <VIA>-<QUOTE> for each intermediate currency in via.
+// Holdings without a route are returned with Priced=false and excluded from the total.
+func Value(holdings []Holding, quote string, via []string, price PriceFunc) ([]Valuation, decimal.Decimal, error) {
+	out := make([]Valuation, 0, len(holdings))
+	var total decimal.Decimal
+	for _, h := range holdings {
+		v := Valuation{Currency: h.Currency, Amount: h.Amount}
+		p, route, ok, err := priceOf(h.Currency, quote, via, price)
+		if err != nil {
+			return nil, decimal.Zero, fmt.Errorf("price %s in %s: %w", h.Currency, quote, err)
+		}
+		if ok {
+			v.Price = p
+			v.Value = h.Amount.Mul(p)
+			v.Route = route
+			v.Priced = true
+			total = total.Add(v.Value)
+		}
+		out = append(out, v)
+	}
+	return out, total, nil
+}
+
+func priceOf(currency, quote string, via []string, price PriceFunc) (decimal.Decimal, []string, bool, error) {
+	if currency == quote {
+		return decimal.NewFromInt(1), nil, true, nil
+	}
+	p, product, ok, err := pairPrice(currency, quote, price)
+	if err != nil {
+		return decimal.Zero, nil, false, err
+	}
+	if ok {
+		return p, []string{product}, true, nil
+	}
+	for _, mid := range via {
+		if mid == currency || mid == quote {
+			continue
+		}
+		first, firstProduct, ok, err := pairPrice(currency, mid, price)
+		if err != nil {
+			return decimal.Zero, nil, false, err
+		}
+		if !ok {
+			continue
+		}
+		second, secondProduct, ok, err := pairPrice(mid, quote, price)
+		if err != nil {
+			return decimal.Zero, nil, false, err
+		}
+		if !ok {
+			continue
+		}
+		return first.Mul(second), []string{firstProduct, secondProduct}, true, nil
+	}
+	return decimal.Zero, nil, false, nil
+}
+
+// pairPrice prices base in quote from the direct product, falling back to the inverse product.
+// It also returns the product that was used.
+func pairPrice(base, quote string, price PriceFunc) (decimal.Decimal, string, bool, error) {
+	direct := base + "-" + quote
+	p, ok, err := price(direct)
+	if err != nil {
+		return decimal.Zero, "", false, err
+	}
+	if ok && p.IsPositive() {
+		return p, direct, true, nil
+	}
+	inverse := quote + "-" + base
+	p, ok, err = price(inverse)
+	if err != nil {
+		return decimal.Zero, "", false, err
+	}
+	if ok && p.IsPositive() {
+		return decimal.NewFromInt(1).Div(p), inverse, true, nil
+	}
+	return decimal.Zero, "", false, nil
+}
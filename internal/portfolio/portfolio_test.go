@@ -0,0 +1,70 @@
+package portfolio
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func staticPrices(m map[string]string) PriceFunc {
+	return func(product string) (decimal.Decimal, bool, error) {
+		p, ok := m[product]
+		if !ok {
+			return decimal.Zero, false, nil
+		}
+		return decimal.RequireFromString(p), true, nil
+	}
+}
+
+func TestValueRoutes(t *testing.T) {
+	prices := staticPrices(map[string]string{
+		"BTC-USD": "50000",
+		"ETH-BTC": "0.05",
+		"USD-EUR": "0.8", // only the inverse of EUR-USD exists
+	})
+	holdings := []Holding{
+		{Currency: "USD", Amount: decimal.NewFromInt(100)},
+		{Currency: "BTC", Amount: decimal.NewFromInt(2)},
+		{Currency: "ETH", Amount: decimal.NewFromInt(10)},
+		{Currency: "EUR", Amount: decimal.NewFromInt(80)},
+		{Currency: "DOGE", Amount: decimal.NewFromInt(1000)},
+	}
+
+	vals, total, err := Value(holdings, "USD", []string{"BTC"}, prices)
+	if err != nil {
+		t.Fatalf("Value: %v", err)
+	}
+
+	want := map[string]string{"USD": "100", "BTC": "100000", "ETH": "25000", "EUR": "100"}
+	for _, v := range vals {
+		if v.Currency == "DOGE" {
+			if v.Priced {
+				t.Errorf("DOGE should be unpriced")
+			}
+			continue
+		}
+		if !v.Priced || v.Value.String() != want[v.Currency] {
+			t.Errorf("%s value = %v (priced=%v), want %v", v.Currency, v.Value, v.Priced, want[v.Currency])
+		}
+	}
+	if total.String() != "125200" {
+		t.Errorf("total = %v, want 125200", total)
+	}
+
+	for _, v := range vals {
+		if v.Currency == "ETH" && (len(v.Route) != 2 || v.Route[0] != "ETH-BTC" || v.Route[1] != "BTC-USD") {
+			t.Errorf("ETH route = %v, want [ETH-BTC BTC-USD]", v.Route)
+		}
+	}
+}
+
+func TestValueUsesInverseProductInRoute(t *testing.T) {
+	prices := staticPrices(map[string]string{"USD-EUR": "0.5"})
+	vals, _, err := Value([]Holding{{Currency: "EUR", Amount: decimal.NewFromInt(1)}}, "USD", nil, prices)
+	if err != nil {
+		t.Fatalf("Value: %v", err)
+	}
+	if len(vals[0].Route) != 1 || vals[0].Route[0] != "USD-EUR" || vals[0].Price.String() != "2" {
+		t.Errorf("got route %v price %v, want [USD-EUR] 2", vals[0].Route, vals[0].Price)
+	}
+}
@@ -0,0 +1,100 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// advisoryLockClass is the first key of the two-key advisory locks guarding budget rows.
+const advisoryLockClass = 0x636f6e // "con"
+
+// PostgresBudget is a token bucket stored in the rate_limit_budgets table. Every process
+// using the same name shares the budget; a transaction-scoped advisory lock serializes the
+// read-refill-take cycle so concurrent takers never overspend it.
+type PostgresBudget struct {
+	db    *sql.DB
+	name  string
+	rate  float64
+	burst float64
+}
+
+// NewPostgresBudget returns a shared budget called name allowing perMinute requests per minute
+// with bursts of up to burst. The rate_limit_budgets table is created by the migrations.
+func NewPostgresBudget(db *sql.DB, name string, perMinute, burst int) *PostgresBudget {
+	if burst < 1 {
+		burst = 1
+	}
+	return &PostgresBudget{db: db, name: name, rate: float64(perMinute) / 60, burst: float64(burst)}
+}
+
+func (p *PostgresBudget) Wait(ctx context.Context) error {
+	for {
+		wait, err := p.take(ctx)
+		if err != nil {
+			return fmt.Errorf("rate limit budget %s: %w", p.name, err)
+		}
+		if wait <= 0 {
+			return nil
+		}
+		if err := Sleep(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+// take runs one refill-and-take cycle and returns how long to wait before trying again.
+func (p *PostgresBudget) take(ctx context.Context) (time.Duration, error) {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1, hashtext($2))`, advisoryLockClass, p.name); err != nil {
+		return 0, fmt.Errorf("lock: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO rate_limit_budgets (name, tokens, updated_at)
+		VALUES ($1, $2, clock_timestamp())
+		ON CONFLICT (name) DO NOTHING`, p.name, p.burst); err != nil {
+		return 0, fmt.Errorf("init: %w", err)
+	}
+
+	var (
+		tokens      float64
+		updatedAt   time.Time
+		pausedUntil sql.NullTime
+		now         time.Time
+	)
+	err = tx.QueryRowContext(ctx, `
+		SELECT tokens, updated_at, paused_until, clock_timestamp()
+		FROM rate_limit_budgets WHERE name = $1`, p.name).Scan(&tokens, &updatedAt, &pausedUntil, &now)
+	if err != nil {
+		return 0, fmt.Errorf("read: %w", err)
+	}
+	if pausedUntil.Valid && now.Before(pausedUntil.Time) {
+		return pausedUntil.Time.Sub(now), nil
+	}
+
+	tokens, wait := take(tokens, now.Sub(updatedAt), p.rate, p.burst)
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE rate_limit_budgets SET tokens = $2, updated_at = $3 WHERE name = $1`, p.name, tokens, now); err != nil {
+		return 0, fmt.Errorf("update: %w", err)
+	}
+	return wait, tx.Commit()
+}
+
+func (p *PostgresBudget) Backoff(ctx context.Context, until time.Time) error {
+	_, err := p.db.ExecContext(ctx, `
+		INSERT INTO rate_limit_budgets (name, tokens, updated_at, paused_until)
+		VALUES ($1, 0, clock_timestamp(), $2)
+		ON CONFLICT (name) DO UPDATE
+		SET paused_until = GREATEST(rate_limit_budgets.paused_until, EXCLUDED.paused_until)`,
+		p.name, until)
+	if err != nil {
+		return fmt.Errorf("rate limit budget %s backoff: %w", p.name, err)
+	}
+	return nil
+}
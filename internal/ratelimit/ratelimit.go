@@ -0,0 +1,143 @@
+// Package ratelimit paces API requests with token buckets. A Bucket budgets one process; a
+// PostgresBudget shares one budget between every process using the same database, so the
+// daemon, scheduled history runs and ad-hoc fetches on one API key back off together.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Limiter paces requests against a budget.
+type Limiter interface {
+	// Wait blocks until a request may be sent or ctx is done.
+	Wait(ctx context.Context) error
+	// Backoff stops all requests until the given time, e.g. after a 429 with Retry-After.
+	Backoff(ctx context.Context, until time.Time) error
+}
+
+// Bucket is an in-process token bucket. It refills at a steady rate up to burst tokens and
+// each request takes one.
+type Bucket struct {
+	rate  float64 // tokens per second
+	burst float64
+
+	mu          sync.Mutex
+	tokens      float64
+	last        time.Time
+	pausedUntil time.Time
+
+	now   func() time.Time
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// NewBucket returns a full bucket allowing perMinute requests per minute with bursts of up to
+// burst requests. burst < 1 is treated as 1.
+func NewBucket(perMinute, burst int) *Bucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &Bucket{
+		rate:   float64(perMinute) / 60,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		now:    time.Now,
+		sleep:  Sleep,
+	}
+}
+
+func (b *Bucket) Wait(ctx context.Context) error {
+	for {
+		b.mu.Lock()
+		now := b.now()
+		var wait time.Duration
+		if now.Before(b.pausedUntil) {
+			wait = b.pausedUntil.Sub(now)
+		} else {
+			if b.last.IsZero() {
+				b.last = now
+			}
+			b.tokens, wait = take(b.tokens, now.Sub(b.last), b.rate, b.burst)
+			b.last = now
+		}
+		b.mu.Unlock()
+
+		if wait <= 0 {
+			return nil
+		}
+		if err := b.sleep(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+func (b *Bucket) Backoff(ctx context.Context, until time.Time) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if until.After(b.pausedUntil) {
+		b.pausedUntil = until
+	}
+	return nil
+}
+
+// take refills tokens for elapsed time and takes one. It returns the new token count and 0,
+// or the unchanged-but-refilled count and how long until a token is available.
+func take(tokens float64, elapsed time.Duration, rate, burst float64) (float64, time.Duration) {
+	if elapsed > 0 {
+		tokens = math.Min(burst, tokens+elapsed.Seconds()*rate)
+	}
+	if tokens >= 1 {
+		return tokens - 1, 0
+	}
+	if rate <= 0 {
+		// A zero rate never refills; behave as unlimited rather than blocking forever.
+		return tokens, 0
+	}
+	return tokens, time.Duration((1 - tokens) / rate * float64(time.Second))
+}
+
+// Delay returns how long to hold off all requests after a response, from its Retry-After
+// header or, when the budget is exhausted, its X-RateLimit-Reset header. It returns 0 when the
+// response asks for no delay.
+func Delay(h http.Header, now time.Time) time.Duration {
+	if v := h.Get("Retry-After"); v != "" {
+		if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+			return time.Duration(secs) * time.Second
+		}
+		if t, err := http.ParseTime(v); err == nil && t.After(now) {
+			return t.Sub(now)
+		}
+	}
+	if remaining, err := strconv.ParseFloat(h.Get("X-Ratelimit-Remaining"), 64); err == nil && remaining < 1 {
+		if reset, err := strconv.ParseFloat(h.Get("X-Ratelimit-Reset"), 64); err == nil && reset > 0 {
+			// Reset is either a Unix timestamp or seconds from now.
+			if reset > 1e9 {
+				if t := time.Unix(0, int64(reset*float64(time.Second))); t.After(now) {
+					return t.Sub(now)
+				}
+				return 0
+			}
+			return time.Duration(reset * float64(time.Second))
+		}
+	}
+	return 0
+}
+
+// Sleep waits for d or until ctx is done.
+func Sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
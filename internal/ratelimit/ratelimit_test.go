@@ -0,0 +1,145 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// fakeClock is a manual clock whose sleeps advance time instantly.
+type fakeClock struct {
+	now    time.Time
+	slept  []time.Duration
+	cancel bool
+}
+
+func (f *fakeClock) Now() time.Time { return f.now }
+
+func (f *fakeClock) Sleep(ctx context.Context, d time.Duration) error {
+	if f.cancel {
+		return context.Canceled
+	}
+	f.slept = append(f.slept, d)
+	f.now = f.now.Add(d)
+	return nil
+}
+
+func newTestBucket(perMinute, burst int) (*Bucket, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	b := NewBucket(perMinute, burst)
+	b.now, b.sleep = clock.Now, clock.Sleep
+	return b, clock
+}
+
+func TestBucketBurstThenRate(t *testing.T) {
+	b, clock := newTestBucket(60, 3) // one token per second
+	ctx := context.Background()
+	for i := 0; i < 4; i++ {
+		if err := b.Wait(ctx); err != nil {
+			t.Fatalf("Wait %d: %v", i, err)
+		}
+	}
+	// The burst of three is free; the fourth request waits one refill.
+	if len(clock.slept) != 1 || clock.slept[0] != time.Second {
+		t.Fatalf("slept %v, want [1s]", clock.slept)
+	}
+
+	// Idle time refills up to the burst, never beyond.
+	clock.now = clock.now.Add(time.Hour)
+	clock.slept = nil
+	for i := 0; i < 3; i++ {
+		b.Wait(ctx)
+	}
+	if len(clock.slept) != 0 {
+		t.Errorf("slept %v after idling, want no waits", clock.slept)
+	}
+}
+
+func TestBucketBackoff(t *testing.T) {
+	b, clock := newTestBucket(600, 5)
+	ctx := context.Background()
+	b.Backoff(ctx, clock.now.Add(30*time.Second))
+	b.Backoff(ctx, clock.now.Add(10*time.Second)) // an earlier pause does not shorten it
+
+	if err := b.Wait(ctx); err != nil {
+		t.Fatalf("Wait: %v", err)
+	}
+	if len(clock.slept) != 1 || clock.slept[0] != 30*time.Second {
+		t.Errorf("slept %v, want [30s]", clock.slept)
+	}
+}
+
+func TestBucketWaitCancelled(t *testing.T) {
+	b, clock := newTestBucket(60, 1)
+	clock.cancel = true
+	ctx := context.Background()
+	if err := b.Wait(ctx); err != nil {
+		t.Fatalf("first Wait: %v", err)
+	}
+	if err := b.Wait(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Wait on an empty bucket = %v, want context.Canceled", err)
+	}
+}
+
+func TestSleepHonoursContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start := time.Now()
+	if err := Sleep(ctx, time.Hour); !errors.Is(err, context.Canceled) {
+		t.Errorf("Sleep = %v, want context.Canceled", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Error("Sleep did not return on cancellation")
+	}
+}
+
+func TestTake(t *testing.T) {
+	tests := []struct {
+		name       string
+		tokens     float64
+		elapsed    time.Duration
+		rate       float64
+		wantTokens float64
+		wantWait   time.Duration
+	}{
+		{"full", 2, 0, 1, 1, 0},
+		{"empty", 0, 0, 2, 0, 500 * time.Millisecond},
+		{"partial refill", 0, 250 * time.Millisecond, 2, 0.5, 250 * time.Millisecond},
+		{"capped at burst", 0, time.Hour, 1, 2, 0}, // refilled to 3, minus the one taken
+		{"zero rate", 0, 0, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		tokens, wait := take(tt.tokens, tt.elapsed, tt.rate, 3)
+		if tokens != tt.wantTokens || wait != tt.wantWait {
+			t.Errorf("%s: take = (%v, %v), want (%v, %v)", tt.name, tokens, wait, tt.wantTokens, tt.wantWait)
+		}
+	}
+}
+
+func TestDelay(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    time.Duration
+	}{
+		{"none", nil, 0},
+		{"retry-after seconds", map[string]string{"Retry-After": "7"}, 7 * time.Second},
+		{"retry-after date", map[string]string{"Retry-After": now.Add(90 * time.Second).Format(http.TimeFormat)}, 90 * time.Second},
+		{"retry-after past date", map[string]string{"Retry-After": now.Add(-time.Minute).Format(http.TimeFormat)}, 0},
+		{"budget left", map[string]string{"X-Ratelimit-Remaining": "3", "X-Ratelimit-Reset": "5"}, 0},
+		{"reset delta", map[string]string{"X-Ratelimit-Remaining": "0", "X-Ratelimit-Reset": "1.5"}, 1500 * time.Millisecond},
+		{"reset epoch", map[string]string{"X-Ratelimit-Remaining": "0", "X-Ratelimit-Reset": "1704067212"}, 12 * time.Second},
+	}
+	for _, tt := range tests {
+		h := http.Header{}
+		for k, v := range tt.headers {
+			h.Set(k, v)
+		}
+		if got := Delay(h, now); got != tt.want {
+			t.Errorf("%s: Delay = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,15 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS wallet_snapshots (
+    exchange TEXT NOT NULL,
+    uuid TEXT NOT NULL,
+    currency TEXT NOT NULL,
+    available_balance DOUBLE PRECISION NOT NULL,
+    hold DOUBLE PRECISION NOT NULL,
+    taken_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (exchange, uuid, taken_at)
+);
+
+CREATE INDEX IF NOT EXISTS idx_wallet_snapshots_currency_time ON wallet_snapshots(exchange, currency, taken_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS wallet_snapshots;
@@ -0,0 +1,25 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS fills (
+    exchange TEXT NOT NULL,
+    entry_id TEXT NOT NULL,
+    trade_id TEXT NOT NULL,
+    order_id TEXT NOT NULL,
+    product_id TEXT NOT NULL,
+    side TEXT NOT NULL,
+    trade_time TIMESTAMPTZ NOT NULL,
+    trade_type TEXT NOT NULL,
+    price DOUBLE PRECISION NOT NULL,
+    size DOUBLE PRECISION NOT NULL,
+    size_in_quote BOOLEAN NOT NULL,
+    commission DOUBLE PRECISION NOT NULL,
+    liquidity_indicator TEXT NOT NULL,
+    sequence_timestamp TIMESTAMPTZ,
+    retail_portfolio_id TEXT,
+    PRIMARY KEY (exchange, entry_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_fills_exchange_trade_time ON fills(exchange, trade_time);
+CREATE INDEX IF NOT EXISTS idx_fills_exchange_order ON fills(exchange, order_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS fills;
@@ -0,0 +1,13 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS order_book_snapshots (
+    exchange TEXT NOT NULL,
+    product_id TEXT NOT NULL,
+    taken_at TIMESTAMPTZ NOT NULL,
+    sequence BIGINT NOT NULL,
+    bids JSONB NOT NULL,
+    asks JSONB NOT NULL,
+    PRIMARY KEY (exchange, product_id, taken_at)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS order_book_snapshots;
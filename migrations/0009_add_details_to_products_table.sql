@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE products ADD COLUMN details JSONB;
+
+-- +goose Down
+ALTER TABLE products DROP COLUMN details;
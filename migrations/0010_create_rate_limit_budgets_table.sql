@@ -0,0 +1,10 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS rate_limit_budgets (
+    name TEXT PRIMARY KEY,
+    tokens DOUBLE PRECISION NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL,
+    paused_until TIMESTAMPTZ
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS rate_limit_budgets;
@@ -0,0 +1,67 @@
+-- +goose Up
+ALTER TABLE candles
+    ALTER COLUMN open TYPE NUMERIC USING open::numeric,
+    ALTER COLUMN high TYPE NUMERIC USING high::numeric,
+    ALTER COLUMN low TYPE NUMERIC USING low::numeric,
+    ALTER COLUMN close TYPE NUMERIC USING close::numeric,
+    ALTER COLUMN volume TYPE NUMERIC USING volume::numeric;
+
+ALTER TABLE products
+    ALTER COLUMN price TYPE NUMERIC USING price::numeric,
+    ALTER COLUMN volume_24h TYPE NUMERIC USING volume_24h::numeric,
+    ALTER COLUMN base_increment TYPE NUMERIC USING base_increment::numeric,
+    ALTER COLUMN quote_increment TYPE NUMERIC USING quote_increment::numeric,
+    ALTER COLUMN price_increment TYPE NUMERIC USING price_increment::numeric,
+    ALTER COLUMN base_min_size TYPE NUMERIC USING base_min_size::numeric,
+    ALTER COLUMN base_max_size TYPE NUMERIC USING base_max_size::numeric,
+    ALTER COLUMN quote_min_size TYPE NUMERIC USING quote_min_size::numeric,
+    ALTER COLUMN quote_max_size TYPE NUMERIC USING quote_max_size::numeric,
+    ALTER COLUMN mid_market_price TYPE NUMERIC USING mid_market_price::numeric,
+    ALTER COLUMN approximate_quote_24h_volume TYPE NUMERIC USING approximate_quote_24h_volume::numeric;
+
+ALTER TABLE wallets
+    ALTER COLUMN available_balance TYPE NUMERIC USING available_balance::numeric,
+    ALTER COLUMN hold TYPE NUMERIC USING hold::numeric;
+
+ALTER TABLE wallet_snapshots
+    ALTER COLUMN available_balance TYPE NUMERIC USING available_balance::numeric,
+    ALTER COLUMN hold TYPE NUMERIC USING hold::numeric;
+
+ALTER TABLE fills
+    ALTER COLUMN price TYPE NUMERIC USING price::numeric,
+    ALTER COLUMN size TYPE NUMERIC USING size::numeric,
+    ALTER COLUMN commission TYPE NUMERIC USING commission::numeric;
+
+-- +goose Down
+ALTER TABLE fills
+    ALTER COLUMN price TYPE DOUBLE PRECISION,
+    ALTER COLUMN size TYPE DOUBLE PRECISION,
+    ALTER COLUMN commission TYPE DOUBLE PRECISION;
+
+ALTER TABLE wallet_snapshots
+    ALTER COLUMN available_balance TYPE DOUBLE PRECISION,
+    ALTER COLUMN hold TYPE DOUBLE PRECISION;
+
+ALTER TABLE wallets
+    ALTER COLUMN available_balance TYPE DOUBLE PRECISION,
+    ALTER COLUMN hold TYPE DOUBLE PRECISION;
+
+ALTER TABLE products
+    ALTER COLUMN price TYPE DOUBLE PRECISION,
+    ALTER COLUMN volume_24h TYPE DOUBLE PRECISION,
+    ALTER COLUMN base_increment TYPE DOUBLE PRECISION,
+    ALTER COLUMN quote_increment TYPE DOUBLE PRECISION,
+    ALTER COLUMN price_increment TYPE DOUBLE PRECISION,
+    ALTER COLUMN base_min_size TYPE DOUBLE PRECISION,
+    ALTER COLUMN base_max_size TYPE DOUBLE PRECISION,
+    ALTER COLUMN quote_min_size TYPE DOUBLE PRECISION,
+    ALTER COLUMN quote_max_size TYPE DOUBLE PRECISION,
+    ALTER COLUMN mid_market_price TYPE DOUBLE PRECISION,
+    ALTER COLUMN approximate_quote_24h_volume TYPE DOUBLE PRECISION;
+
+ALTER TABLE candles
+    ALTER COLUMN open TYPE DOUBLE PRECISION,
+    ALTER COLUMN high TYPE DOUBLE PRECISION,
+    ALTER COLUMN low TYPE DOUBLE PRECISION,
+    ALTER COLUMN close TYPE DOUBLE PRECISION,
+    ALTER COLUMN volume TYPE DOUBLE PRECISION;
@@ -0,0 +1,56 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS candle_gaps (
+    exchange        TEXT        NOT NULL,
+    product_id      TEXT        NOT NULL,
+    granularity     INT         NOT NULL, -- seconds per bucket
+    start_time      TIMESTAMPTZ NOT NULL,
+    end_time        TIMESTAMPTZ NOT NULL, -- exclusive
+    attempts        INT         NOT NULL DEFAULT 1,
+    last_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (exchange, product_id, granularity, start_time),
+    CHECK (end_time > start_time)
+);
+
+-- Fake candles (volume = -1) do not record their granularity. Use the most common spacing
+-- between the stored candles of each product, or one minute for a product with a single row,
+-- and collapse runs of consecutive fake candles with the same fake_fill_count into one range.
+WITH spacing AS (
+    SELECT exchange, product_id,
+        EXTRACT(EPOCH FROM lead(time) OVER (PARTITION BY exchange, product_id ORDER BY time) - time)::int AS step
+    FROM candles
+), steps AS (
+    SELECT exchange, product_id, mode() WITHIN GROUP (ORDER BY step) AS step
+    FROM spacing
+    WHERE step > 0
+    GROUP BY exchange, product_id
+), fakes AS (
+    SELECT c.exchange, c.product_id, c.time, GREATEST(c.fake_fill_count, 1) AS attempts, COALESCE(s.step, 60) AS step
+    FROM candles c
+    LEFT JOIN steps s USING (exchange, product_id)
+    WHERE c.volume = -1
+), islands AS (
+    SELECT exchange, product_id, step, attempts, time,
+        EXTRACT(EPOCH FROM time)::bigint / step
+            - row_number() OVER (PARTITION BY exchange, product_id, attempts ORDER BY time) AS island
+    FROM fakes
+)
+INSERT INTO candle_gaps (exchange, product_id, granularity, start_time, end_time, attempts, last_attempt_at)
+SELECT exchange, product_id, step, min(time), max(time) + make_interval(secs => step), attempts, now()
+FROM islands
+GROUP BY exchange, product_id, step, attempts, island
+ON CONFLICT DO NOTHING;
+
+DELETE FROM candles WHERE volume = -1;
+
+ALTER TABLE candles DROP COLUMN IF EXISTS fake_fill_count;
+
+-- +goose Down
+ALTER TABLE candles ADD COLUMN IF NOT EXISTS fake_fill_count INT NOT NULL DEFAULT 0;
+
+INSERT INTO candles (exchange, product_id, time, open, high, low, close, volume, fake_fill_count)
+SELECT g.exchange, g.product_id, t, 0, 0, 0, 0, -1, g.attempts
+FROM candle_gaps g,
+    generate_series(g.start_time, g.end_time - make_interval(secs => g.granularity), make_interval(secs => g.granularity)) AS t
+ON CONFLICT (exchange, product_id, time) DO NOTHING;
+
+DROP TABLE IF EXISTS candle_gaps;
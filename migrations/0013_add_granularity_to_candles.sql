@@ -0,0 +1,42 @@
+-- +goose Up
+ALTER TABLE candles ADD COLUMN IF NOT EXISTS granularity INT; -- seconds per bucket
+
+-- Infer the granularity of existing rows: the largest supported granularity that fits within
+-- the spacing to the nearest neighbouring candle and that the bucket start is aligned to.
+-- Isolated rows default to one minute.
+WITH neighbors AS (
+    SELECT exchange, product_id, time,
+        LEAST(
+            EXTRACT(EPOCH FROM time - lag(time) OVER w),
+            EXTRACT(EPOCH FROM lead(time) OVER w - time)
+        ) AS spacing
+    FROM candles
+    WHERE granularity IS NULL
+    WINDOW w AS (PARTITION BY exchange, product_id ORDER BY time)
+), inferred AS (
+    SELECT n.exchange, n.product_id, n.time,
+        COALESCE((
+            SELECT max(g)
+            FROM unnest(ARRAY[60, 300, 900, 1800, 3600, 7200, 14400, 21600, 86400]) AS g
+            WHERE g <= n.spacing AND EXTRACT(EPOCH FROM n.time)::bigint % g = 0
+        ), 60) AS granularity
+    FROM neighbors n
+)
+UPDATE candles c
+SET granularity = i.granularity
+FROM inferred i
+WHERE c.exchange = i.exchange AND c.product_id = i.product_id AND c.time = i.time;
+
+ALTER TABLE candles ALTER COLUMN granularity SET NOT NULL;
+ALTER TABLE candles DROP CONSTRAINT IF EXISTS candles_pkey;
+ALTER TABLE candles ADD PRIMARY KEY (exchange, product_id, granularity, time);
+
+-- +goose Down
+-- Keep the finest candle for each bucket start so the old key stays unique.
+DELETE FROM candles c
+USING candles f
+WHERE c.exchange = f.exchange AND c.product_id = f.product_id AND c.time = f.time AND c.granularity > f.granularity;
+
+ALTER TABLE candles DROP CONSTRAINT IF EXISTS candles_pkey;
+ALTER TABLE candles DROP COLUMN IF EXISTS granularity;
+ALTER TABLE candles ADD PRIMARY KEY (exchange, product_id, time);
@@ -0,0 +1,18 @@
+-- +goose Up
+-- When each candle was last written, so rollups can recompute only the buckets touched since their last run.
+ALTER TABLE candles ADD COLUMN IF NOT EXISTS ingested_at TIMESTAMPTZ NOT NULL DEFAULT now();
+CREATE INDEX IF NOT EXISTS idx_candles_ingested_at ON candles (exchange, product_id, granularity, ingested_at);
+
+CREATE TABLE IF NOT EXISTS candle_rollups (
+    exchange    TEXT        NOT NULL,
+    product_id  TEXT        NOT NULL,
+    granularity INT         NOT NULL, -- target seconds per bucket
+    watermark   TIMESTAMPTZ NOT NULL, -- newest 1-minute candle or gap change already rolled up
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (exchange, product_id, granularity)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS candle_rollups;
+DROP INDEX IF EXISTS idx_candles_ingested_at;
+ALTER TABLE candles DROP COLUMN IF EXISTS ingested_at;
@@ -0,0 +1,20 @@
+-- +goose Up
+-- Candles removed by `data audit --quarantine`, kept for inspection.
+CREATE TABLE IF NOT EXISTS candles_quarantine (
+    exchange       TEXT        NOT NULL,
+    product_id     TEXT        NOT NULL,
+    granularity    INT         NOT NULL, -- seconds per bucket
+    time           TIMESTAMPTZ NOT NULL,
+    open           NUMERIC     NOT NULL,
+    high           NUMERIC     NOT NULL,
+    low            NUMERIC     NOT NULL,
+    close          NUMERIC     NOT NULL,
+    volume         NUMERIC     NOT NULL,
+    ingested_at    TIMESTAMPTZ NOT NULL,
+    reason         TEXT        NOT NULL, -- comma-separated audit finding kinds
+    quarantined_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (exchange, product_id, granularity, time)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS candles_quarantine;
@@ -0,0 +1,16 @@
+-- +goose Up
+-- A deleted account gets one zero-balance snapshot with deleted = true, so as-of balances stop
+-- counting its last balance.
+ALTER TABLE wallet_snapshots ADD COLUMN IF NOT EXISTS deleted BOOLEAN NOT NULL DEFAULT false;
+
+-- Mark accounts the wallets table already knows were deleted.
+INSERT INTO wallet_snapshots (exchange, uuid, currency, available_balance, hold, taken_at, deleted)
+SELECT w.exchange, w.uuid, w.currency, 0, 0, w.deleted_at, true
+FROM wallets w
+WHERE w.deleted_at IS NOT NULL
+  AND EXISTS (SELECT 1 FROM wallet_snapshots s WHERE s.exchange = w.exchange AND s.uuid = w.uuid)
+ON CONFLICT (exchange, uuid, taken_at) DO NOTHING;
+
+-- +goose Down
+DELETE FROM wallet_snapshots WHERE deleted;
+ALTER TABLE wallet_snapshots DROP COLUMN IF EXISTS deleted;